@@ -1,9 +1,19 @@
 package api
 
 import (
+	"database/sql"
+	"io"
+	"log/slog"
+
 	v1 "github.com/samandar2605/post/api/v1"
 	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/pkg/blob"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/samandar2605/post/pkg/health"
+	"github.com/samandar2605/post/pkg/metrics"
+	"github.com/samandar2605/post/pkg/ratelimit"
 	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/worker"
 
 	"github.com/gin-gonic/gin"
 
@@ -15,6 +25,16 @@ import (
 type RouterOptions struct {
 	Cfg     *config.Config
 	Storage storage.StorageI
+	Blob    blob.Store
+	Images  *worker.ImageProcessor
+	Sitemap *worker.Sitemap
+	Filter  *contentfilter.Engine
+	Logger  *slog.Logger
+	Metrics *metrics.Metrics
+	Health  *health.Checker
+	Pools   map[string]*sql.DB
+	// RateLimits is nil when rate limiting is disabled.
+	RateLimits ratelimit.Store
 }
 
 // @title           Swagger for blog api
@@ -23,21 +43,47 @@ type RouterOptions struct {
 // @host      		localhost:8000
 // @BasePath  		/v1
 func New(opt *RouterOptions) *gin.Engine {
-	router := gin.Default()
+	router := gin.New()
+	// gin trusts X-Forwarded-For from everyone by default, which would let
+	// clients choose the ip they are rate limited by
+	if err := router.SetTrustedProxies(opt.Cfg.Server.TrustedProxies); err != nil {
+		// the proxies were validated with the config
+		panic(err)
+	}
 
 	handlerV1 := v1.New(&v1.HandlerV1Options{
 		Cfg:     opt.Cfg,
 		Storage: opt.Storage,
+		Blob:    opt.Blob,
+		Images:  opt.Images,
+		Sitemap: opt.Sitemap,
+		Filter:  opt.Filter,
+		Logger:  opt.Logger,
+		Metrics: opt.Metrics,
+		Health:  opt.Health,
+		Pools:   opt.Pools,
+
+		RateLimits: opt.RateLimits,
 	})
 
-	apiV1 := router.Group("/v1")
+	router.Use(
+		handlerV1.RequestId,
+		handlerV1.Trace,
+		handlerV1.AccessLog,
+		handlerV1.Instrument,
+		gin.CustomRecoveryWithWriter(io.Discard, handlerV1.Recover),
+		handlerV1.SecurityHeaders,
+		handlerV1.Cors,
+	)
 
+	apiV1 := router.Group("/v1", handlerV1.Authenticate, handlerV1.RateLimit, handlerV1.LimitBody)
 
 	// Category
 	apiV1.GET("/categories/:id",handlerV1.GetCategory)
 	apiV1.GET("/categories",handlerV1.GetCategoryAll)
 	apiV1.POST("/categories",handlerV1.CreateCategory)
 	apiV1.PUT("/categories/:id",handlerV1.UpdateCategory)
+	apiV1.PATCH("/categories/:id",handlerV1.PatchCategory)
 	apiV1.DELETE("/categories/:id",handlerV1.DeleteCategory)
 
 	// Like
@@ -53,6 +99,7 @@ func New(opt *RouterOptions) *gin.Engine {
 	apiV1.GET("/users/:id",handlerV1.GetUser)
 	apiV1.POST("/users",handlerV1.CreateUser)
 	apiV1.PUT("/users/:id",handlerV1.UpdateUser)
+	apiV1.PATCH("/users/:id",handlerV1.PatchUser)
 	apiV1.DELETE("/users/:id",handlerV1.DeleteUser)
 
 	// Comment
@@ -60,16 +107,65 @@ func New(opt *RouterOptions) *gin.Engine {
 	apiV1.GET("/comments/:id",handlerV1.GetComment)
 	apiV1.POST("/comments",handlerV1.CreateComment)
 	apiV1.PUT("/comments/:id",handlerV1.UpdateComment)
+	apiV1.PATCH("/comments/:id",handlerV1.PatchComment)
 	apiV1.DELETE("/comments/:id",handlerV1.DeleteComment)
 
+	// Report
+	apiV1.POST("/reports",handlerV1.AuthRequired,handlerV1.CreateReport)
+
+	// Admin
+	admin := apiV1.Group("/admin", handlerV1.AdminOnly)
+	admin.GET("/comments/moderation",handlerV1.GetModerationQueue)
+	admin.POST("/comments/moderation",handlerV1.ModerateComments)
+	admin.GET("/posts/moderation",handlerV1.GetPostModerationQueue)
+	admin.POST("/posts/moderation",handlerV1.ModeratePosts)
+	admin.GET("/content-filter",handlerV1.GetContentFilterRules)
+	admin.PUT("/content-filter",handlerV1.UpdateContentFilterRules)
+	admin.GET("/reports",handlerV1.GetReports)
+	admin.GET("/reports/:id",handlerV1.GetReport)
+	admin.POST("/reports/:id/resolve",handlerV1.ResolveReport)
+	admin.POST("/users/:id/status",handlerV1.SetUserStatus)
+	admin.GET("/users/:id/status/history",handlerV1.GetUserStatusHistory)
+	admin.GET("/trash",handlerV1.GetTrash)
+	admin.POST("/trash/:type/:id/restore",handlerV1.RestoreTrashItem)
+	admin.GET("/audit",handlerV1.GetAuditLog)
+
 	// Post
 	apiV1.GET("/post",handlerV1.GetPostAll)
 	apiV1.GET("/post/:id",handlerV1.GetPost)
 	apiV1.POST("/post",handlerV1.CreatePost)
 	apiV1.PUT("/post/:id",handlerV1.UpdatePost)
+	apiV1.PATCH("/post/:id",handlerV1.PatchPost)
 	apiV1.DELETE("/post/:id",handlerV1.DeletePost)
 
+	// Feeds
+	router.GET("/feeds/rss.xml",handlerV1.GetFeed)
+	router.GET("/feeds/atom.xml",handlerV1.GetFeed)
+	router.GET("/feeds/categories/:id/rss.xml",handlerV1.GetFeed)
+	router.GET("/feeds/categories/:id/atom.xml",handlerV1.GetFeed)
+	router.GET("/feeds/authors/:id/rss.xml",handlerV1.GetFeed)
+	router.GET("/feeds/authors/:id/atom.xml",handlerV1.GetFeed)
+
+	// Sitemap
+	router.GET("/sitemap.xml",handlerV1.GetSitemapIndex)
+	router.GET("/sitemaps/:name",handlerV1.GetSitemap)
+	router.GET("/robots.txt",handlerV1.GetRobots)
+
+	// Media
+	apiV1.GET("/media/:id",handlerV1.GetMedia)
+	apiV1.POST("/media",handlerV1.AuthRequired,handlerV1.UploadMedia)
+	router.GET("/media/*key",handlerV1.ServeMedia)
+
+	// Health
+	router.GET("/healthz",handlerV1.Healthz)
+	router.GET("/readyz",handlerV1.Readyz)
+	router.GET("/debug/status",handlerV1.Authenticate,handlerV1.AdminOnly,handlerV1.GetStatus)
+
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	if opt.Metrics != nil {
+		router.GET("/metrics", gin.WrapH(opt.Metrics.Handler()))
+	}
+
 	return router
 }
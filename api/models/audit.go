@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry is one recorded change. Before is missing for creations and
+// After for deletions.
+type AuditEntry struct {
+	Id         int64           `json:"id"`
+	ActorId    int             `json:"actor_id,omitempty"`
+	Action     string          `json:"action" example:"update"`
+	EntityType string          `json:"entity_type" example:"post"`
+	EntityId   string          `json:"entity_id" example:"1"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	RequestId  string          `json:"request_id,omitempty"`
+	Ip         string          `json:"ip,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type GetAllAuditResponse struct {
+	Entries []*AuditEntry `json:"entries"`
+	Count   int           `json:"count"`
+}
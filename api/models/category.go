@@ -5,9 +5,15 @@ import "time"
 type Category struct {
 	Id        int       `json:"id"`
 	Title     string    `json:"title"`
+	Version   int       `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type CreateCategory struct {
-	Title string `json:"title"`
+	Title string `json:"title" binding:"required,notblank,max=255"`
+}
+
+// PatchCategory is a JSON merge patch (RFC 7396) of a category.
+type PatchCategory struct {
+	Title *string `json:"title" binding:"omitempty,notblank,max=255"`
 }
@@ -3,16 +3,49 @@ package models
 import "time"
 
 type Comment struct {
-	Id          int       `json:"id" db:"id"`
-	PostId      int       `json:"post_id" db:"post_id"`
-	UserId      int       `json:"user_id" db:"user_id"`
-	Description string    `json:"description" db:"description"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	Id          int        `json:"id" db:"id"`
+	PostId      int        `json:"post_id" db:"post_id"`
+	UserId      int        `json:"user_id" db:"user_id"`
+	Description string     `json:"description" db:"description"`
+	Status      string     `json:"status" db:"status"`
+	ModeratedBy int        `json:"moderated_by,omitempty" db:"moderated_by"`
+	ModeratedAt *time.Time `json:"moderated_at,omitempty" db:"moderated_at"`
+	Version     int        `json:"version" db:"version"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type CreateComment struct {
-	PostId      int    `json:"post_id" db:"post_id"`
-	UserId      int    `json:"user_id" db:"user_id"`
-	Description string `json:"description" db:"description"`
+	PostId      int    `json:"post_id" db:"post_id" binding:"required,min=1"`
+	UserId      int    `json:"user_id" db:"user_id" binding:"omitempty,min=1"`
+	Description string `json:"description" db:"description" binding:"required,notblank,max=10000"`
+}
+
+type GetAllCommentsResponse struct {
+	Comments []*Comment `json:"comments"`
+	Count    int        `json:"count"`
+}
+
+// CommentFilter narrows the list of comments, like PostFilter.
+type CommentFilter struct {
+	PostId string `form:"post_id" binding:"omitempty,number"`
+	UserId string `form:"user_id" binding:"omitempty,number"`
+	Status string `form:"status" binding:"omitempty,oneof=pending approved rejected spam"`
+}
+
+// ModerateRequest applies Action (approve, reject or spam) to all comments
+// or posts in Ids.
+type ModerateRequest struct {
+	Ids    []int  `json:"ids" binding:"required,min=1,max=100,dive,min=1"`
+	Action string `json:"action" binding:"required,oneof=approve reject spam"`
+}
+
+type ModerateResponse struct {
+	Status  string `json:"status"`
+	Updated int    `json:"updated"`
+}
+
+// PatchComment is a JSON merge patch (RFC 7396) of a comment.
+type PatchComment struct {
+	Description *string `json:"description" binding:"omitempty,notblank,max=10000"`
 }
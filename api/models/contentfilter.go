@@ -0,0 +1,18 @@
+package models
+
+// ContentFilterRules configure the spam filter run on new comments and
+// posts. Windows are durations like "10m", a zero score disables a check.
+type ContentFilterRules struct {
+	Keywords        []string `json:"keywords" binding:"max=1000,dive,notblank"`
+	Patterns        []string `json:"patterns" binding:"max=1000,dive,notblank"`
+	KeywordScore    float64  `json:"keyword_score" binding:"min=0"`
+	MaxLinks        int      `json:"max_links" binding:"min=0"`
+	LinkScore       float64  `json:"link_score" binding:"min=0"`
+	DuplicateWindow string   `json:"duplicate_window" example:"24h"`
+	DuplicateScore  float64  `json:"duplicate_score" binding:"min=0"`
+	VelocityWindow  string   `json:"velocity_window" example:"1m"`
+	VelocityLimit   int      `json:"velocity_limit" binding:"min=0"`
+	VelocityScore   float64  `json:"velocity_score" binding:"min=0"`
+	ModerateScore   float64  `json:"moderate_score" binding:"min=0"`
+	RejectScore     float64  `json:"reject_score" binding:"min=0"`
+}
@@ -4,4 +4,18 @@ type ErrorResponse struct {
 	Error string `json:"error"`
 }
 
+type ResponseOK struct {
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse lists every rule a request broke.
+type ValidationErrorResponse struct {
+	Error  string        `json:"error" example:"validation failed"`
+	Fields []*FieldError `json:"fields"`
+}
 
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Rule    string `json:"rule" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+type HealthResponse struct {
+	Status string `json:"status" example:"ok"`
+}
+
+// ReadinessResponse lists the dependency checks behind the readiness of the
+// api.
+type ReadinessResponse struct {
+	Status string         `json:"status" example:"ready"`
+	Checks []*CheckResult `json:"checks"`
+}
+
+type CheckResult struct {
+	Name      string  `json:"name" example:"database"`
+	Healthy   bool    `json:"healthy"`
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type StatusResponse struct {
+	Build     BuildInfo             `json:"build"`
+	StartedAt time.Time             `json:"started_at"`
+	Uptime    string                `json:"uptime" example:"72h3m0s"`
+	Pools     map[string]*PoolStats `json:"pools"`
+	Checks    []*CheckStatus        `json:"checks"`
+}
+
+type BuildInfo struct {
+	GoVersion string `json:"go_version" example:"go1.21.0"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Time      string `json:"time"`
+	Modified  bool   `json:"modified"`
+}
+
+type PoolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+}
+
+// CheckStatus is the last outcome of a check along with its last failure.
+type CheckStatus struct {
+	Name        string     `json:"name" example:"database"`
+	Healthy     bool       `json:"healthy"`
+	Error       string     `json:"error,omitempty"`
+	LatencyMs   float64    `json:"latency_ms"`
+	CheckedAt   *time.Time `json:"checked_at"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastFailure *time.Time `json:"last_failure"`
+	LastError   string     `json:"last_error,omitempty"`
+}
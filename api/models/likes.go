@@ -8,7 +8,13 @@ type Like struct {
 }
 
 type CreateLike struct {
-	PostId int    `json:"post_id"`
-	UserId int    `json:"user_id"`
-	Status string `json:"status"`
+	PostId int    `json:"post_id" binding:"required,min=1"`
+	UserId int    `json:"user_id" binding:"omitempty,min=1"`
+	Status string `json:"status" binding:"max=255"`
+}
+
+// LikeFilter narrows the list of likes, like PostFilter.
+type LikeFilter struct {
+	PostId string `form:"post_id" binding:"omitempty,number"`
+	UserId string `form:"user_id" binding:"omitempty,number"`
 }
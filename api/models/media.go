@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type Media struct {
+	Id          int          `json:"id"`
+	Url         string       `json:"url"`
+	ContentType string       `json:"content_type"`
+	Size        int64        `json:"size"`
+	UserId      int          `json:"user_id"`
+	Status      string       `json:"status"`
+	Renditions  []*Rendition `json:"renditions"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type Rendition struct {
+	Name        string `json:"name"`
+	Url         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
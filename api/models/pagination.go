@@ -0,0 +1,8 @@
+package models
+
+// Pagination is accepted by every list endpoint. Pages are numbered from 1
+// and hold at most 100 items.
+type Pagination struct {
+	Page  int `form:"page,default=1" binding:"min=1"`
+	Limit int `form:"limit,default=10" binding:"min=1,max=100"`
+}
@@ -2,23 +2,96 @@ package models
 
 import "time"
 
+// Post.Description is the Markdown source, DescriptionHtml its sanitized
+// rendering that is safe to embed in a page. ReadingTime is in minutes.
+// ImageRenditions stay empty until an uploaded image has been processed.
 type Post struct {
-	Id          int       `json:"id" db:"id"`
-	Title       string    `json:"title" db:"title"`
-	Description string    `json:"description" db:"description"`
-	ImageUrl    string    `json:"image_url" db:"image_url"`
-	UserId      string    `json:"user_id" db:"user_id"`
-	CategoryId  string    `json:"category_id" db:"category_id"`
-	UpdatedAt   string    `json:"updated_at" db:"updated_at"`
-	ViewsCount  string    `json:"views_count" db:"views_count"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	Id              int          `json:"id" db:"id"`
+	Title           string       `json:"title" db:"title"`
+	Description     string       `json:"description" db:"description"`
+	DescriptionHtml string       `json:"description_html" db:"description_html"`
+	Toc             []*TocEntry  `json:"toc" db:"toc"`
+	ReadingTime     int          `json:"reading_time" db:"reading_time"`
+	Excerpt         string       `json:"excerpt" db:"excerpt"`
+	ImageUrl        string       `json:"image_url" db:"image_url"`
+	UserId          string       `json:"user_id" db:"user_id"`
+	CategoryId      string       `json:"category_id" db:"category_id"`
+	UpdatedAt       time.Time    `json:"updated_at" db:"updated_at"`
+	ViewsCount      string       `json:"views_count" db:"views_count"`
+	Status          string       `json:"status" db:"status"`
+	ModeratedBy     int          `json:"moderated_by,omitempty" db:"moderated_by"`
+	ModeratedAt     *time.Time   `json:"moderated_at,omitempty" db:"moderated_at"`
+	Version         int          `json:"version" db:"version"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+	ImageRenditions []*Rendition `json:"image_renditions"`
+
+	// Author, Category and Reactions are only set when asked for with
+	// include.
+	Author    *Author    `json:"author,omitempty"`
+	Category  *Category  `json:"category,omitempty"`
+	Reactions *Reactions `json:"reactions,omitempty"`
+}
+
+// Author is the public profile of the author of a post.
+type Author struct {
+	Id              int    `json:"id"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	Username        string `json:"username"`
+	ProfileImageUrl string `json:"profile_image_url"`
+}
+
+// Reactions counts the likes of a post by their status.
+type Reactions struct {
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+}
+
+// PostView selects what post responses hold. Both are comma separated in
+// the query: fields lists the members to return, all by default, and
+// include the related resources to embed.
+type PostView struct {
+	Fields  []string `form:"fields" binding:"dive,oneof=id title description description_html toc reading_time excerpt image_url user_id category_id updated_at views_count status moderated_by moderated_at version created_at image_renditions"`
+	Include []string `form:"include" binding:"dive,oneof=author category reactions"`
+}
+
+// PostFilter narrows the list of posts, next to Pagination. Ids are kept as
+// strings so that one that is not a number is reported like any other
+// broken rule.
+type PostFilter struct {
+	Search     string `form:"search"`
+	CategoryId string `form:"category_id" binding:"omitempty,number"`
+	UserId     string `form:"user_id" binding:"omitempty,number"`
+	Status     string `form:"status" binding:"omitempty,oneof=pending approved rejected spam"`
+}
+
+type TocEntry struct {
+	Level int    `json:"level"`
+	Title string `json:"title"`
+	Id    string `json:"id"`
+}
+
+// GetAllPostsResponse keeps the member names the list had before it got a
+// model of its own, clients depend on them.
+type GetAllPostsResponse struct {
+	Posts []*Post `json:"Post"`
+	Count int     `json:"Count"`
 }
 
 type CreatePost struct {
-	Title       string `json:"title" db:"title"`
-	Description string `json:"description" db:"description"`
-	ImageUrl    string `json:"image_url" db:"image_url"`
-	UserId      string `json:"user_id" db:"user_id"`
-	CategoryId  string `json:"category_id" db:"category_id"`
-	ViewsCount  string `json:"views_count" db:"views_count"`
+	Title       string `json:"title" db:"title" binding:"required,notblank,max=255"`
+	Description string `json:"description" db:"description" binding:"required,notblank,max=100000"`
+	ImageUrl    string `json:"image_url" db:"image_url" binding:"omitempty,url,max=255"`
+	UserId      string `json:"user_id" db:"user_id" binding:"omitempty,number"`
+	CategoryId  string `json:"category_id" db:"category_id" binding:"required,number"`
+	ViewsCount  string `json:"views_count" db:"views_count" binding:"omitempty,number"`
+}
+
+// PatchPost is a JSON merge patch (RFC 7396) of a post. Members left out
+// stay unchanged.
+type PatchPost struct {
+	Title       *string `json:"title" binding:"omitempty,notblank,max=255"`
+	Description *string `json:"description" binding:"omitempty,notblank,max=100000"`
+	ImageUrl    *string `json:"image_url" binding:"omitempty,url,max=255"`
+	CategoryId  *string `json:"category_id" binding:"omitempty,number"`
 }
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+// CreateReport flags a post, comment or user. Reason is one of spam,
+// harassment, hate, violence, sexual, misinformation or other.
+type CreateReport struct {
+	TargetType string `json:"target_type" example:"post" binding:"required,oneof=post comment user"`
+	TargetId   int    `json:"target_id" binding:"required,min=1"`
+	Reason     string `json:"reason" example:"spam" binding:"required,oneof=spam harassment hate violence sexual misinformation other"`
+	Details    string `json:"details" binding:"max=2000"`
+}
+
+type Report struct {
+	Id          int        `json:"id"`
+	TargetType  string     `json:"target_type"`
+	TargetId    int        `json:"target_id"`
+	Status      string     `json:"status"`
+	ReportCount int        `json:"report_count"`
+	Hidden      bool       `json:"hidden"`
+	ResolvedBy  int        `json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type ReportEntry struct {
+	Id         int       `json:"id"`
+	ReporterId int       `json:"reporter_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ReportAction struct {
+	Id        int       `json:"id"`
+	ActorId   int       `json:"actor_id,omitempty"`
+	Action    string    `json:"action"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReportDetails is a report with everything filed against the target and
+// the audit trail of what was done about it.
+type ReportDetails struct {
+	Report  *Report         `json:"report"`
+	Entries []*ReportEntry  `json:"entries"`
+	Actions []*ReportAction `json:"actions"`
+}
+
+type GetAllReportsResponse struct {
+	Reports []*Report `json:"reports"`
+	Count   int       `json:"count"`
+}
+
+// ResolveReport closes a report. Action is dismiss, remove (the reported
+// post or comment) or suspend (the author of the target). SuspendDays of 0
+// suspends until further notice.
+type ResolveReport struct {
+	Action      string `json:"action" example:"dismiss" binding:"required,oneof=dismiss remove suspend"`
+	Note        string `json:"note" binding:"max=2000"`
+	SuspendDays int    `json:"suspend_days" binding:"min=0,max=3650"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// TrashItem is a deleted post, comment, category, user or like that can
+// still be restored.
+type TrashItem struct {
+	Type      string    `json:"type" example:"post"`
+	Id        int       `json:"id"`
+	Title     string    `json:"title"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type GetAllTrashResponse struct {
+	Items []*TrashItem `json:"items"`
+	Count int          `json:"count"`
+}
@@ -1,28 +1,78 @@
 package models
 
+import "time"
+
+// User.Status is active, suspended, banned or deactivated. A status with
+// StatusExpiresAt set lapses back to active at that time.
 type User struct {
-	Id              int    `json:"id"`
-	FirstName       string `json:"first_name"`
-	LastName        string `json:"last_name"`
-	PhoneNumber     string `json:"phone_number"`
-	Email           string `json:"email"`
-	CreatedAt       string `json:"created_at"`
-	Gender          string `json:"gender"`
-	Password        string `json:"password"`
-	Username        string `json:"username"`
-	ProfileImageUrl string `json:"profile_image_url"`
-	Type            string `json:"type"`
+	Id              int        `json:"id"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	PhoneNumber     string     `json:"phone_number"`
+	Email           string     `json:"email"`
+	CreatedAt       string     `json:"created_at"`
+	Gender          string     `json:"gender"`
+	Password        string     `json:"password"`
+	Username        string     `json:"username"`
+	ProfileImageUrl string     `json:"profile_image_url"`
+	Type            string     `json:"type"`
+	Status          string     `json:"status"`
+	StatusReason    string     `json:"status_reason,omitempty"`
+	StatusExpiresAt *time.Time `json:"status_expires_at,omitempty"`
+	Version         int        `json:"version"`
+	// ProfileImageRenditions are resized copies of ProfileImageUrl.
+	ProfileImageRenditions []*Rendition `json:"profile_image_renditions"`
 }
 
+// GetAllUsersResponse keeps the member names the list had before it got a
+// model of its own, clients depend on them.
+type GetAllUsersResponse struct {
+	Users []*User `json:"Users"`
+	Count int     `json:"Count"`
+}
+
+// CreateUser creates or replaces a regular user. The type of an account
+// cannot be set through the api, admins are appointed in the database.
 type CreateUser struct {
-	FirstName       string `json:"first_name"`
-	LastName        string `json:"last_name"`
-	PhoneNumber     string `json:"phone_number"`
-	Email           string `json:"email"`
+	FirstName       string `json:"first_name" binding:"required,notblank,max=255"`
+	LastName        string `json:"last_name" binding:"max=255"`
+	PhoneNumber     string `json:"phone_number" binding:"omitempty,phone"`
+	Email           string `json:"email" binding:"required,email,max=255"`
 	CreatedAt       string `json:"created_at"`
-	Gender          string `json:"gender"`
-	Password        string `json:"password"`
-	Username        string `json:"username"`
-	ProfileImageUrl string `json:"profile_image_url"`
-	Type            string `json:"type"`
+	Gender          string `json:"gender" example:"female" binding:"required,oneof=male female"`
+	Password        string `json:"password" binding:"required,min=8,max=255"`
+	Username        string `json:"username" binding:"required,notblank,max=255"`
+	ProfileImageUrl string `json:"profile_image_url" binding:"omitempty,url,max=255"`
+}
+
+// PatchUser is a JSON merge patch (RFC 7396) of a user. Members left out
+// stay unchanged, last_name, phone_number and profile_image_url can be
+// cleared with null.
+type PatchUser struct {
+	FirstName       *string `json:"first_name" binding:"omitempty,notblank,max=255"`
+	LastName        *string `json:"last_name" binding:"omitempty,max=255"`
+	PhoneNumber     *string `json:"phone_number" binding:"omitempty,phone"`
+	Email           *string `json:"email" binding:"omitempty,email,max=255"`
+	Gender          *string `json:"gender" example:"female" binding:"omitempty,oneof=male female"`
+	Password        *string `json:"password" binding:"omitempty,min=8,max=255"`
+	Username        *string `json:"username" binding:"omitempty,notblank,max=255"`
+	ProfileImageUrl *string `json:"profile_image_url" binding:"omitempty,url,max=255"`
+}
+
+// SetUserStatus changes the account status of a user. ExpiresAt is only
+// used for suspensions and bans, nil meaning until further notice.
+type SetUserStatus struct {
+	Status    string     `json:"status" example:"suspended" binding:"required,oneof=active suspended banned deactivated"`
+	Reason    string     `json:"reason" binding:"max=2000"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type UserStatusChange struct {
+	Id        int        `json:"id"`
+	Status    string     `json:"status"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ChangedBy int        `json:"changed_by,omitempty"`
+	ReportId  int        `json:"report_id,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
 }
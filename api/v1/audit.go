@@ -0,0 +1,165 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/logger"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// audit records a change made by the request. strg must be the storage the
+// change was made through inside WithTx, so that the change and its entry
+// are committed together. before is nil for creations and after for
+// deletions.
+func audit(c *gin.Context, strg storage.StorageI, action, entityType string, entityId interface{}, before, after interface{}) error {
+	entry := repo.AuditEntry{
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   fmt.Sprint(entityId),
+		RequestId:  requestId(c),
+		Ip:         c.ClientIP(),
+	}
+	if user := getAuthUser(c); user != nil {
+		entry.ActorId = user.Id
+	}
+
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		return err
+	}
+
+	return strg.Audit().Add(&entry)
+}
+
+// auditSnapshot marshals v for the audit log, leaving out secrets.
+func auditSnapshot(v interface{}) (json.RawMessage, error) {
+	if u, ok := v.(*repo.User); ok && u != nil {
+		redacted := *u
+		if redacted.Password != "" {
+			redacted.Password = logger.Redacted
+		}
+		v = &redacted
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "null" {
+		return nil, nil
+	}
+	return b, nil
+}
+
+// @Router /admin/audit [get]
+// @Summary Query the audit log
+// @Description Newest entries first
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Limit"
+// @Param page query int false "Page"
+// @Param actor_id query int false "Actor id"
+// @Param action query string false "create, update, delete, restore, moderate, set_status, hide or resolve"
+// @Param entity_type query string false "post, comment, category, user, like, media, report or setting"
+// @Param entity_id query string false "Entity id"
+// @Param from query string false "RFC 3339 time, inclusive"
+// @Param to query string false "RFC 3339 time, exclusive"
+// @Success 200 {object} models.GetAllAuditResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetAuditLog(c *gin.Context) {
+	query, err := validateGetAuditQuery(c)
+	if err != nil {
+		if abortValidation(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.store(c).Audit().GetAll(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp := models.GetAllAuditResponse{
+		Entries: make([]*models.AuditEntry, 0, len(result.Entries)),
+		Count:   result.Count,
+	}
+	for _, e := range result.Entries {
+		resp.Entries = append(resp.Entries, &models.AuditEntry{
+			Id:         e.Id,
+			ActorId:    e.ActorId,
+			Action:     e.Action,
+			EntityType: e.EntityType,
+			EntityId:   e.EntityId,
+			Before:     e.Before,
+			After:      e.After,
+			RequestId:  e.RequestId,
+			Ip:         e.Ip,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func validateGetAuditQuery(c *gin.Context) (repo.GetAuditQuery, error) {
+	var (
+		actorId int
+		from    time.Time
+		to      time.Time
+		err     error
+	)
+	pagination, err := bindPagination(c)
+	if err != nil {
+		return repo.GetAuditQuery{}, err
+	}
+	if c.Query("actor_id") != "" {
+		actorId, err = strconv.Atoi(c.Query("actor_id"))
+		if err != nil {
+			return repo.GetAuditQuery{}, err
+		}
+	}
+	if c.Query("from") != "" {
+		from, err = time.Parse(time.RFC3339, c.Query("from"))
+		if err != nil {
+			return repo.GetAuditQuery{}, err
+		}
+	}
+	if c.Query("to") != "" {
+		to, err = time.Parse(time.RFC3339, c.Query("to"))
+		if err != nil {
+			return repo.GetAuditQuery{}, err
+		}
+	}
+
+	return repo.GetAuditQuery{
+		Limit:      pagination.Limit,
+		Page:       pagination.Page,
+		ActorId:    actorId,
+		Action:     c.Query("action"),
+		EntityType: c.Query("entity_type"),
+		EntityId:   c.Query("entity_id"),
+		From:       from,
+		To:         to,
+	}, nil
+}
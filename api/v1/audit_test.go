@@ -0,0 +1,66 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/pkg/logger"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditUserWrites(t *testing.T) {
+	const hash = "$2a$10$secret-hash"
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantAction string
+		wantAfter  bool
+	}{
+		{
+			name:       "update",
+			method:     http.MethodPut,
+			body:       `{"first_name":"Ann","email":"ann@example.com","gender":"female","password":"new-password","username":"ann"}`,
+			wantAction: repo.AuditActionUpdate,
+			wantAfter:  true,
+		},
+		{name: "delete", method: http.MethodDelete, wantAction: repo.AuditActionDelete},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := &fakeStorage{
+				users:  newFakeUsers(&repo.User{Id: 1, FirstName: "Ann", Password: hash, Version: 1}),
+				audits: &fakeAudit{},
+			}
+			h := New(&HandlerV1Options{Cfg: &config.Config{}, Storage: strg})
+			router := gin.New()
+			router.PUT("/users/:id", h.UpdateUser)
+			router.DELETE("/users/:id", h.DeleteUser)
+
+			req := httptest.NewRequest(tt.method, "/users/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+
+			require.Len(t, strg.audits.entries, 1)
+			entry := strg.audits.entries[0]
+			require.Equal(t, tt.wantAction, entry.Action)
+			require.Equal(t, repo.TrashTypeUser, entry.EntityType)
+			require.Equal(t, "1", entry.EntityId)
+			require.Contains(t, string(entry.Before), `"Password":"`+logger.Redacted+`"`)
+			require.NotContains(t, string(entry.Before), hash)
+			if tt.wantAfter {
+				require.Contains(t, string(entry.After), `"Password":"`+logger.Redacted+`"`)
+				require.NotContains(t, string(entry.After), "new-password")
+			} else {
+				require.Nil(t, entry.After)
+			}
+		})
+	}
+}
@@ -1,11 +1,14 @@
 package v1
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage"
 	"github.com/samandar2605/post/storage/repo"
 )
 
@@ -17,6 +20,7 @@ import (
 // @Produce json
 // @Param id path int true "ID"
 // @Success 200 {object} models.Category
+// @Header 200 {string} ETag "version of the entity"
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) GetCategory(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -27,7 +31,7 @@ func (h *handlerV1) GetCategory(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.storage.Category().Get(id)
+	resp, err := h.store(c).Category().Get(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
@@ -35,11 +39,17 @@ func (h *handlerV1) GetCategory(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.Category{
-		Id:        resp.Id,
-		Title:     resp.Title,
-		CreatedAt: resp.CreatedAt,
-	})
+	c.Header("ETag", etag(resp.Version))
+	c.JSON(http.StatusOK, parseCategoryModel(resp))
+}
+
+func parseCategoryModel(c *repo.Category) *models.Category {
+	return &models.Category{
+		Id:        c.Id,
+		Title:     c.Title,
+		Version:   c.Version,
+		CreatedAt: c.CreatedAt,
+	}
 }
 
 // @Router /categories [post]
@@ -50,6 +60,8 @@ func (h *handlerV1) GetCategory(c *gin.Context) {
 // @Produce json
 // @Param category body models.CreateCategory true "Category"
 // @Success 201 {object} models.Category
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) CreateCategory(c *gin.Context) {
@@ -59,14 +71,24 @@ func (h *handlerV1) CreateCategory(c *gin.Context) {
 
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.Category().Create(&repo.Category{
-		Title: req.Title,
+	var resp *repo.Category
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		resp, err = strg.Category().Create(&repo.Category{
+			Title: req.Title,
+		})
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionCreate, repo.TrashTypeCategory, resp.Id, nil, resp)
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -75,11 +97,7 @@ func (h *handlerV1) CreateCategory(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.Category{
-		Id:        resp.Id,
-		Title:     resp.Title,
-		CreatedAt: resp.CreatedAt,
-	})
+	c.JSON(http.StatusCreated, parseCategoryModel(resp))
 }
 
 // @Summary Get Category
@@ -90,19 +108,26 @@ func (h *handlerV1) CreateCategory(c *gin.Context) {
 // @Param limit query int true "Limit"
 // @Param page query int true "Page"
 // @Param search query string false "Search"
+// @Param If-None-Match header string false "ETag of the cached page, 304 if it is still current"
 // @Success 200 {object} models.Category
+// @Success 304 {string} string
+// @Header 200 {string} ETag "hash of the page"
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /Categories [get]
 func (h *handlerV1) GetCategoryAll(ctx *gin.Context) {
 	queryParams, err := validateGetCategoryQuery(ctx)
 	if err != nil {
+		if abortValidation(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.Category().GetAll(queryParams)
+	resp, err := h.store(ctx).Category().GetAll(queryParams)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -110,32 +135,18 @@ func (h *handlerV1) GetCategoryAll(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
+	h.writeCacheable(ctx, resp)
 }
 
 func validateGetCategoryQuery(ctx *gin.Context) (repo.GetCategoryQuery, error) {
-	var (
-		limit int = 10
-		page  int = 1
-		err   error
-	)
-	if ctx.Query("limit") != "" {
-		limit, err = strconv.Atoi(ctx.Query("limit"))
-		if err != nil {
-			return repo.GetCategoryQuery{}, err
-		}
-	}
-
-	if ctx.Query("page") != "" {
-		page, err = strconv.Atoi(ctx.Query("page"))
-		if err != nil {
-			return repo.GetCategoryQuery{}, err
-		}
+	pagination, err := bindPagination(ctx)
+	if err != nil {
+		return repo.GetCategoryQuery{}, err
 	}
 
 	return repo.GetCategoryQuery{
-		Limit:  limit,
-		Page:   page,
+		Limit:  pagination.Limit,
+		Page:   pagination.Page,
 		Search: ctx.Query("search")}, nil
 }
 
@@ -146,14 +157,22 @@ func validateGetCategoryQuery(ctx *gin.Context) (repo.GetCategoryQuery, error) {
 // @Produce json
 // @Param id path int true "ID"
 // @Param user body models.CreateCategory true "Category"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
 // @Success 200 {object} models.Category
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /categories/{id} [put]
 func (h *handlerV1) UpdateCategory(ctx *gin.Context) {
-	var b repo.Category
+	var req models.CreateCategory
 
-	err := ctx.ShouldBindJSON(&b)
+	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
@@ -168,8 +187,29 @@ func (h *handlerV1) UpdateCategory(ctx *gin.Context) {
 		return
 	}
 
-	b.Id = id
-	category, err := h.storage.Category().Update(b)
+	b := repo.Category{
+		Id:    id,
+		Title: req.Title,
+	}
+	var category *repo.Category
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Category().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		b.Version = before.Version
+		category, err = strg.Category().Update(b)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypeCategory, id, before, category)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to create category",
@@ -177,15 +217,93 @@ func (h *handlerV1) UpdateCategory(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, category)
+	ctx.Header("ETag", etag(category.Version))
+	ctx.JSON(http.StatusOK, parseCategoryModel(category))
+}
+
+// @Summary Partially update a Category
+// @Description Applies a JSON merge patch (RFC 7396): only the members sent are changed
+// @Tags category
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param category body models.PatchCategory true "merge patch"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Success 200 {object} models.Category
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /categories/{id} [patch]
+func (h *handlerV1) PatchCategory(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var req models.PatchCategory
+	nulls, err := bindMergePatch(ctx, &req)
+	if err == nil {
+		err = resetNulls(nulls, nil)
+	}
+	if err != nil {
+		abortBadPatch(ctx, err)
+		return
+	}
+
+	var category *repo.Category
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Category().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		category, err = strg.Category().Patch(id, &repo.CategoryPatch{
+			Version: before.Version,
+			Title:   req.Title,
+		})
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypeCategory, id, before, category)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	ctx.Header("ETag", etag(category.Version))
+	ctx.JSON(http.StatusOK, parseCategoryModel(category))
 }
 
 // @Summary Delete a categories
-// @Description Delete a categories
+// @Description The category is moved to the trash and can be restored by an admin until it is purged
 // @Tags category
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Failure 412 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /categories/{id} [delete]
 func (h *handlerV1) DeleteCategory(ctx *gin.Context) {
@@ -197,7 +315,22 @@ func (h *handlerV1) DeleteCategory(ctx *gin.Context) {
 		return
 	}
 
-	err = h.storage.Category().Delete(id)
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Category().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		if err := strg.Category().Delete(id, before.Version); err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionDelete, repo.TrashTypeCategory, id, before, nil)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to Delete method",
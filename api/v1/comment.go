@@ -1,11 +1,15 @@
 package v1
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/samandar2605/post/storage"
 	"github.com/samandar2605/post/storage/repo"
 )
 
@@ -17,6 +21,8 @@ import (
 // @Produce json
 // @Param id path int true "ID"
 // @Success 200 {object} models.Comment
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) GetComment(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -27,7 +33,19 @@ func (h *handlerV1) GetComment(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.storage.Comment().Get(id)
+	resp, err := h.store(c).Comment().Get(id)
+	if err == nil {
+		var visible bool
+		if visible, err = h.canSeeComment(c, getAuthUser(c), resp); err == nil && !visible {
+			err = sql.ErrNoRows
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
@@ -35,25 +53,51 @@ func (h *handlerV1) GetComment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.Comment{
-		Id:          resp.Id,
-		PostId:      resp.PostId,
-		UserId:      resp.UserId,
-		Description: resp.Description,
-		CreatedAt:   resp.CreatedAt,
-		UpdatedAt:   resp.UpdatedAt,
-	})
+	c.Header("ETag", etag(resp.Version))
+	c.JSON(http.StatusOK, parseCommentModel(resp))
+}
+
+// canSeeComment hides comments that are not approved from everyone but
+// admins and their author, and comments of banned users from everyone but
+// admins.
+func (h *handlerV1) canSeeComment(c *gin.Context, user *repo.User, comment *repo.Comment) (bool, error) {
+	if isAdmin(user) {
+		return true, nil
+	}
+	if comment.Status != repo.CommentStatusApproved && (user == nil || user.Id != comment.UserId) {
+		return false, nil
+	}
+	banned, err := h.isBanned(c, comment.UserId)
+	return !banned, err
+}
+
+func parseCommentModel(comment *repo.Comment) *models.Comment {
+	return &models.Comment{
+		Id:          comment.Id,
+		PostId:      comment.PostId,
+		UserId:      comment.UserId,
+		Description: comment.Description,
+		Status:      comment.Status,
+		ModeratedBy: comment.ModeratedBy,
+		ModeratedAt: comment.ModeratedAt,
+		Version:     comment.Version,
+		CreatedAt:   comment.CreatedAt,
+		UpdatedAt:   comment.UpdatedAt,
+	}
 }
 
 // @Router /comments [post]
 // @Summary Create a comment
-// @Description Create a comment
+// @Description Comments of authenticated users are attributed to them. Unless an auto-approve rule matches, the comment waits in the moderation queue with status pending.
 // @Tags comments
 // @Accept json
 // @Produce json
 // @Param comment body models.CreateComment true "comment"
 // @Success 201 {object} models.Comment
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) CreateComment(c *gin.Context) {
 	var (
@@ -62,17 +106,40 @@ func (h *handlerV1) CreateComment(c *gin.Context) {
 
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.Comment().Create(&repo.Comment{
-		PostId:      req.PostId,
-		UserId:      req.UserId,
-		Description: req.Description,
+	user := getAuthUser(c)
+	if user != nil {
+		req.UserId = user.Id
+	}
+	if err := h.checkAuthorStatus(c, req.UserId); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errAccountInactive) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	status, err := h.filteredCommentStatus(c, user, &contentfilter.Content{
+		UserId: req.UserId,
+		Text:   req.Description,
 	})
+	if errors.Is(err, errContentRejected) {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
@@ -80,18 +147,32 @@ func (h *handlerV1) CreateComment(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.Comment{
-		Id:          resp.Id,
-		PostId:      resp.PostId,
-		UserId:      resp.UserId,
-		Description: resp.Description,
-		CreatedAt:   resp.CreatedAt,
-		UpdatedAt:   resp.UpdatedAt,
+	var resp *repo.Comment
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		resp, err = strg.Comment().Create(&repo.Comment{
+			PostId:      req.PostId,
+			UserId:      req.UserId,
+			Description: req.Description,
+			Status:      status,
+		})
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionCreate, repo.TrashTypeComment, resp.Id, nil, resp)
 	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	h.metrics.CommentCreated()
+
+	c.JSON(http.StatusCreated, parseCommentModel(resp))
 }
 
-// @Summary Get Likes
-// @Description Get Likes
+// @Summary Get comments
+// @Description Only approved comments are listed, admins may filter by any status
 // @Tags comments
 // @Accept json
 // @Produce json
@@ -99,19 +180,29 @@ func (h *handlerV1) CreateComment(c *gin.Context) {
 // @Param page query int true "Page"
 // @Param post_id query int false "post_id"
 // @Param user_id query int false "user_id"
-// @Success 200 {object} models.User
+// @Param status query string false "pending, approved, rejected or spam (admins only)"
+// @Success 200 {object} models.GetAllCommentsResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /comments [get]
 func (h *handlerV1) GetAllComment(ctx *gin.Context) {
 	queryParams, err := validateGetCommentQuery(ctx)
 	if err != nil {
+		if abortValidation(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.Comment().GetAll(queryParams)
+	if !isAdmin(getAuthUser(ctx)) {
+		queryParams.Status = repo.CommentStatusApproved
+		queryParams.HideBannedAuthors = true
+	}
+
+	result, err := h.store(ctx).Comment().GetAll(queryParams)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -119,66 +210,65 @@ func (h *handlerV1) GetAllComment(ctx *gin.Context) {
 		return
 	}
 
+	resp := models.GetAllCommentsResponse{
+		Comments: make([]*models.Comment, 0, len(result.Comments)),
+		Count:    result.Count,
+	}
+	for _, comment := range result.Comments {
+		resp.Comments = append(resp.Comments, parseCommentModel(comment))
+	}
+
 	ctx.JSON(http.StatusOK, resp)
 }
 
 func validateGetCommentQuery(ctx *gin.Context) (repo.GetCommentQuery, error) {
-	var (
-		limit  int = 10
-		page   int = 1
-		postId int
-		userId int
-		err    error
-	)
-	if ctx.Query("limit") != "" {
-		limit, err = strconv.Atoi(ctx.Query("limit"))
-		if err != nil {
-			return repo.GetCommentQuery{}, err
-		}
+	var filter models.CommentFilter
+	pagination, err := bindPagination(ctx)
+	if err := mergeValidation(err, ctx.ShouldBindQuery(&filter)); err != nil {
+		return repo.GetCommentQuery{}, err
 	}
 
-	if ctx.Query("page") != "" {
-		page, err = strconv.Atoi(ctx.Query("page"))
-		if err != nil {
-			return repo.GetCommentQuery{}, err
-		}
+	postId, err := queryId(filter.PostId)
+	if err != nil {
+		return repo.GetCommentQuery{}, err
 	}
-	if ctx.Query("post_id") != "" {
-		postId, err = strconv.Atoi(ctx.Query("post_id"))
-		if err != nil {
-			return repo.GetCommentQuery{}, err
-		}
+	userId, err := queryId(filter.UserId)
+	if err != nil {
+		return repo.GetCommentQuery{}, err
 	}
 
-	if ctx.Query("user_id") != "" {
-		userId, err = strconv.Atoi(ctx.Query("user_id"))
-		if err != nil {
-			return repo.GetCommentQuery{}, err
-		}
-	}
 	return repo.GetCommentQuery{
-		Limit:  limit,
-		Page:   page,
+		Limit:  pagination.Limit,
+		Page:   pagination.Page,
 		PostId: postId,
 		UserId: userId,
+		Status: filter.Status,
 	}, nil
 }
 
 // @Summary Update a comment
-// @Description Update a commentss
+// @Description Edited comments go through the auto-approve rules again. The author can not be changed, user_id is ignored
 // @Tags comments
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
 // @Param comment body models.CreateComment true "comment"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
 // @Success 200 {object} models.Comment
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /comments/{id} [put]
 func (h *handlerV1) UpdateComment(ctx *gin.Context) {
-	var b repo.Comment
+	var b models.CreateComment
 
 	err := ctx.ShouldBindJSON(&b)
 	if err != nil {
+		if abortBind(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
@@ -193,8 +283,46 @@ func (h *handlerV1) UpdateComment(ctx *gin.Context) {
 		return
 	}
 
-	b.Id = id
-	comment, err := h.storage.Comment().Update(&b)
+	var comment *repo.Comment
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Comment().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		// the author stays the same, whatever user_id was sent
+		status, err := h.filteredCommentStatus(ctx, getAuthUser(ctx), &contentfilter.Content{
+			UserId:   before.UserId,
+			Text:     b.Description,
+			Replaces: before.Description,
+		})
+		if err != nil {
+			return err
+		}
+		comment, err = strg.Comment().Update(&repo.Comment{
+			Id:          id,
+			PostId:      b.PostId,
+			UserId:      before.UserId,
+			Description: b.Description,
+			Status:      status,
+			Version:     before.Version,
+		})
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypeComment, id, before, comment)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
+	if errors.Is(err, errContentRejected) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to create comment",
@@ -202,15 +330,106 @@ func (h *handlerV1) UpdateComment(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, comment)
+	ctx.Header("ETag", etag(comment.Version))
+	ctx.JSON(http.StatusOK, parseCommentModel(comment))
+}
+
+// @Summary Partially update a comment
+// @Description Applies a JSON merge patch (RFC 7396). A changed description goes through the auto-approve rules again
+// @Tags comments
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param comment body models.PatchComment true "merge patch"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Success 200 {object} models.Comment
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /comments/{id} [patch]
+func (h *handlerV1) PatchComment(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var req models.PatchComment
+	nulls, err := bindMergePatch(ctx, &req)
+	if err == nil {
+		err = resetNulls(nulls, nil)
+	}
+	if err != nil {
+		abortBadPatch(ctx, err)
+		return
+	}
+
+	var comment *repo.Comment
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Comment().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		patch := repo.CommentPatch{
+			Version:     before.Version,
+			Description: req.Description,
+		}
+		if req.Description != nil {
+			status, err := h.filteredCommentStatus(ctx, getAuthUser(ctx), &contentfilter.Content{
+				UserId:   before.UserId,
+				Text:     *req.Description,
+				Replaces: before.Description,
+			})
+			if err != nil {
+				return err
+			}
+			patch.Status = &status
+		}
+		comment, err = strg.Comment().Patch(id, &patch)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypeComment, id, before, comment)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			status = http.StatusNotFound
+		case errors.Is(err, errContentRejected):
+			status = http.StatusUnprocessableEntity
+		}
+		ctx.JSON(status, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	ctx.Header("ETag", etag(comment.Version))
+	ctx.JSON(http.StatusOK, parseCommentModel(comment))
 }
 
 // @Summary Delete a comment
-// @Description Delete a comment
+// @Description The comment is moved to the trash and can be restored by an admin until it is purged
 // @Tags comments
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Failure 412 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /comments/{id} [delete]
 func (h *handlerV1) DeleteComment(ctx *gin.Context) {
@@ -222,7 +441,22 @@ func (h *handlerV1) DeleteComment(ctx *gin.Context) {
 		return
 	}
 
-	err = h.storage.Comment().Delete(id)
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Comment().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		if err := strg.Comment().Delete(id, before.Version); err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionDelete, repo.TrashTypeComment, id, before, nil)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to Delete method",
@@ -0,0 +1,182 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// checkContent runs the content filter on a submission. Admins are trusted
+// and skip it. Anonymous submissions are told apart by client ip, the user
+// id they claim is not taken.
+func (h *handlerV1) checkContent(c *gin.Context, user *repo.User, content *contentfilter.Content) contentfilter.Action {
+	if h.filter == nil || isAdmin(user) {
+		return contentfilter.Publish
+	}
+	if user == nil {
+		content.UserId = 0
+		content.Client = c.ClientIP()
+	}
+
+	return h.filter.Check(content).Action
+}
+
+// abortNoFilter answers 404 when the content filter is disabled.
+func (h *handlerV1) abortNoFilter(c *gin.Context) bool {
+	if h.filter != nil {
+		return false
+	}
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error: "content filter is disabled",
+	})
+	return true
+}
+
+// @Router /admin/content-filter [get]
+// @Summary Get the content filter rules
+// @Tags admin
+// @Produce json
+// @Success 200 {object} models.ContentFilterRules
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+func (h *handlerV1) GetContentFilterRules(c *gin.Context) {
+	if h.abortNoFilter(c) {
+		return
+	}
+	c.JSON(http.StatusOK, parseContentFilterRules(h.filter.Rules()))
+}
+
+// @Router /admin/content-filter [put]
+// @Summary Replace the content filter rules
+// @Description The rules take effect immediately and are kept across restarts
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param rules body models.ContentFilterRules true "rules"
+// @Success 200 {object} models.ContentFilterRules
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) UpdateContentFilterRules(c *gin.Context) {
+	if h.abortNoFilter(c) {
+		return
+	}
+
+	var req models.ContentFilterRules
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if abortBind(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	rules, err := toContentFilterRules(&req)
+	if err == nil {
+		err = rules.Validate()
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	value, err := json.Marshal(rules)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Setting().Get(repo.ContentFilterSettingKey)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err := strg.Setting().Set(repo.ContentFilterSettingKey, value); err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionUpdate, repo.AuditEntitySetting, repo.ContentFilterSettingKey,
+			json.RawMessage(before), json.RawMessage(value))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err := h.filter.SetRules(*rules); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, parseContentFilterRules(*rules))
+}
+
+func parseContentFilterRules(r contentfilter.Rules) *models.ContentFilterRules {
+	return &models.ContentFilterRules{
+		Keywords:        r.Keywords,
+		Patterns:        r.Patterns,
+		KeywordScore:    r.KeywordScore,
+		MaxLinks:        r.MaxLinks,
+		LinkScore:       r.LinkScore,
+		DuplicateWindow: time.Duration(r.DuplicateWindow).String(),
+		DuplicateScore:  r.DuplicateScore,
+		VelocityWindow:  time.Duration(r.VelocityWindow).String(),
+		VelocityLimit:   r.VelocityLimit,
+		VelocityScore:   r.VelocityScore,
+		ModerateScore:   r.ModerateScore,
+		RejectScore:     r.RejectScore,
+	}
+}
+
+func toContentFilterRules(m *models.ContentFilterRules) (*contentfilter.Rules, error) {
+	duplicateWindow, err := parseOptionalDuration(m.DuplicateWindow)
+	if err != nil {
+		return nil, err
+	}
+	velocityWindow, err := parseOptionalDuration(m.VelocityWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentfilter.Rules{
+		Keywords:        m.Keywords,
+		Patterns:        m.Patterns,
+		KeywordScore:    m.KeywordScore,
+		MaxLinks:        m.MaxLinks,
+		LinkScore:       m.LinkScore,
+		DuplicateWindow: contentfilter.Duration(duplicateWindow),
+		DuplicateScore:  m.DuplicateScore,
+		VelocityWindow:  contentfilter.Duration(velocityWindow),
+		VelocityLimit:   m.VelocityLimit,
+		VelocityScore:   m.VelocityScore,
+		ModerateScore:   m.ModerateScore,
+		RejectScore:     m.RejectScore,
+	}, nil
+}
+
+func parseOptionalDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentFilterRulesDisabled(t *testing.T) {
+	h := New(&HandlerV1Options{Cfg: &config.Config{}})
+	router := gin.New()
+	router.GET("/admin/content-filter", h.GetContentFilterRules)
+	router.PUT("/admin/content-filter", h.UpdateContentFilterRules)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		req := httptest.NewRequest(method, "/admin/content-filter", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusNotFound, rec.Code, method)
+	}
+}
+
+func TestCheckContentKeysAnonymousByClient(t *testing.T) {
+	filter, err := contentfilter.New(contentfilter.Rules{
+		VelocityWindow: contentfilter.Duration(time.Minute),
+		VelocityLimit:  1,
+		VelocityScore:  1,
+		ModerateScore:  1,
+		RejectScore:    2,
+	})
+	require.NoError(t, err)
+	h := New(&HandlerV1Options{Cfg: &config.Config{}, Filter: filter})
+
+	check := func(remoteAddr string, claimedId int) contentfilter.Action {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+		c.Request.RemoteAddr = remoteAddr
+		return h.checkContent(c, nil, &contentfilter.Content{Kind: "post", UserId: claimedId, Text: "hello"})
+	}
+
+	require.Equal(t, contentfilter.Publish, check("10.0.0.1:1234", 1))
+	// another claimed id from the same client counts against the same limit
+	require.Equal(t, contentfilter.Moderate, check("10.0.0.1:1234", 2))
+	require.Equal(t, contentfilter.Publish, check("10.0.0.2:1234", 1))
+}
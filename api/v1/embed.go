@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// bindPostView reads the fields and include parameters of a post request.
+func bindPostView(c *gin.Context) (models.PostView, error) {
+	view := models.PostView{
+		Fields:  splitList(c.Query("fields")),
+		Include: splitList(c.Query("include")),
+	}
+	return view, validate(&view)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// embedPosts sets the related resources of posts listed in include. Each
+// kind is loaded with one query for all posts. Authors hidden by a report
+// are only embedded for admins.
+func (h *handlerV1) embedPosts(c *gin.Context, posts []*models.Post, include []string) error {
+	if len(posts) == 0 || len(include) == 0 {
+		return nil
+	}
+
+	var userIds, categoryIds, postIds []int
+	for _, p := range posts {
+		if id, err := strconv.Atoi(p.UserId); err == nil {
+			userIds = append(userIds, id)
+		}
+		if id, err := strconv.Atoi(p.CategoryId); err == nil {
+			categoryIds = append(categoryIds, id)
+		}
+		postIds = append(postIds, p.Id)
+	}
+
+	if slices.Contains(include, "author") {
+		ids := unique(userIds)
+		users, err := h.store(c).User().GetByIds(ids)
+		if err != nil {
+			return err
+		}
+		if !isAdmin(getAuthUser(c)) {
+			hidden, err := h.store(c).Report().GetHidden(repo.ReportTargetUser, ids)
+			if err != nil {
+				return err
+			}
+			for id := range hidden {
+				delete(users, id)
+			}
+		}
+		for _, p := range posts {
+			id, _ := strconv.Atoi(p.UserId)
+			if u, ok := users[id]; ok {
+				p.Author = &models.Author{
+					Id:              u.Id,
+					FirstName:       u.FirstName,
+					LastName:        u.LastName,
+					Username:        u.UserName,
+					ProfileImageUrl: u.ProfileImageUrl,
+				}
+			}
+		}
+	}
+
+	if slices.Contains(include, "category") {
+		categories, err := h.store(c).Category().GetByIds(unique(categoryIds))
+		if err != nil {
+			return err
+		}
+		for _, p := range posts {
+			id, _ := strconv.Atoi(p.CategoryId)
+			if cat, ok := categories[id]; ok {
+				p.Category = &models.Category{
+					Id:        cat.Id,
+					Title:     cat.Title,
+					Version:   cat.Version,
+					CreatedAt: cat.CreatedAt,
+				}
+			}
+		}
+	}
+
+	if slices.Contains(include, "reactions") {
+		counts, err := h.store(c).Like().CountByPosts(postIds)
+		if err != nil {
+			return err
+		}
+		for _, p := range posts {
+			reactions := &models.Reactions{Counts: make(map[string]int)}
+			for status, n := range counts[p.Id] {
+				reactions.Counts[status] = n
+				reactions.Total += n
+			}
+			p.Reactions = reactions
+		}
+	}
+
+	return nil
+}
+
+func unique(ids []int) []int {
+	slices.Sort(ids)
+	return slices.Compact(ids)
+}
+
+// selectFields returns post with only the members listed in fields, the id
+// and the embedded resources. All members are kept when fields is empty.
+func selectFields(post *models.Post, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return post, nil
+	}
+
+	data, err := json.Marshal(post)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	keep := append([]string{"id", "author", "category", "reactions"}, fields...)
+	for name := range members {
+		if !slices.Contains(keep, name) {
+			delete(members, name)
+		}
+	}
+	return members, nil
+}
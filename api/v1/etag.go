@@ -0,0 +1,114 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+var errPreconditionFailed = errors.New("resource was modified, fetch it again")
+
+// etag is the entity tag of one version of an entity.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// renditionsTag is the entity tag of one version of an entity with the
+// renditions of its image, which are stored later without a new version.
+// It matches the version in If-Match, see checkIfMatch.
+func renditionsTag(version int, renditions []*models.Rendition) string {
+	if len(renditions) == 0 {
+		return etag(version)
+	}
+	data, _ := json.Marshal(renditions)
+	sum := sha256.Sum256(data)
+	return `"` + strconv.Itoa(version) + "-" + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// bodyTag is the entity tag of a response body without a version of its
+// own, like a page of posts.
+func bodyTag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// cacheable sets the ETag and Cache-Control headers of a response clients
+// may cache and tells whether they already have it. Responses to
+// authenticated users may show them more, so only anonymous ones are
+// public.
+func (h *handlerV1) cacheable(c *gin.Context, tag string) (fresh bool) {
+	switch maxAge := h.cfg.Cache.HttpMaxAge; {
+	case getAuthUser(c) != nil:
+		c.Header("Cache-Control", "private, no-cache")
+	case maxAge > 0:
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+	default:
+		c.Header("Cache-Control", "no-cache")
+	}
+	c.Writer.Header().Add("Vary", "X-User-Id")
+	c.Header("ETag", tag)
+
+	if notModified(c, tag, time.Time{}) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// writeCacheable answers with v as json, or with 304 when the client
+// already has it.
+func (h *handlerV1) writeCacheable(c *gin.Context, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if h.cacheable(c, bodyTag(body)) {
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// checkIfMatch returns errPreconditionFailed when the request carries an
+// If-Match header that does not match the current version. Tags of
+// renditionsTag match their version whatever the renditions. Requests
+// without the header are not checked.
+func checkIfMatch(c *gin.Context, version int) error {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return nil
+	}
+	current := etag(version)
+	withRenditions := strings.TrimSuffix(current, `"`) + "-"
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current || strings.HasPrefix(tag, withRenditions) {
+			return nil
+		}
+	}
+	return errPreconditionFailed
+}
+
+// abortPreconditionFailed answers 412 if err is a failed If-Match check or a
+// concurrent update that won the compare-and-swap.
+func abortPreconditionFailed(c *gin.Context, err error) bool {
+	if !errors.Is(err, errPreconditionFailed) && !errors.Is(err, repo.ErrVersionConflict) {
+		return false
+	}
+	c.JSON(http.StatusPreconditionFailed, models.ErrorResponse{
+		Error: errPreconditionFailed.Error(),
+	})
+	return true
+}
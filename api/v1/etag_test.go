@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		wantErr bool
+	}{
+		{name: "no header"},
+		{name: "current version", ifMatch: `"3"`},
+		{name: "any version", ifMatch: "*"},
+		{name: "one of several", ifMatch: `"1", "3"`},
+		{name: "with renditions", ifMatch: `"3-0a1b2c3d4e5f6071"`},
+		{name: "outdated version", ifMatch: `"2"`, wantErr: true},
+		{name: "outdated version with renditions", ifMatch: `"2-0a1b2c3d4e5f6071"`, wantErr: true},
+		{name: "longer version", ifMatch: `"31"`, wantErr: true},
+		{name: "weak tag", ifMatch: `W/"3"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodDelete, "/", nil)
+			if tt.ifMatch != "" {
+				c.Request.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			err := checkIfMatch(c, 3)
+			if tt.wantErr {
+				require.ErrorIs(t, err, errPreconditionFailed)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRenditionsTag(t *testing.T) {
+	thumb := &models.Rendition{Name: "thumb", Url: "/media/1/thumb.jpg", Width: 100, Height: 100}
+	large := &models.Rendition{Name: "large", Url: "/media/1/large.jpg", Width: 800, Height: 600}
+
+	require.Equal(t, etag(3), renditionsTag(3, nil))
+	withThumb := renditionsTag(3, []*models.Rendition{thumb})
+	require.NotEqual(t, etag(3), withThumb)
+	require.NotEqual(t, withThumb, renditionsTag(3, []*models.Rendition{thumb, large}))
+	require.NotEqual(t, withThumb, renditionsTag(4, []*models.Rendition{thumb}))
+}
+
+func TestDeletePostPreconditionFailed(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifMatch     string
+		deleteErr   error
+		wantDeletes []int
+	}{
+		{name: "outdated If-Match", ifMatch: `"1"`},
+		{name: "concurrent update", ifMatch: `"2"`, deleteErr: repo.ErrVersionConflict, wantDeletes: []int{2}},
+		{name: "concurrent update without If-Match", deleteErr: repo.ErrVersionConflict, wantDeletes: []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := &fakePosts{post: repo.Post{Id: 1, Version: 2}, deleteErr: tt.deleteErr}
+			h := New(&HandlerV1Options{
+				Cfg:     &config.Config{},
+				Storage: &fakeStorage{posts: posts},
+			})
+			router := gin.New()
+			router.DELETE("/posts/:id", h.DeletePost)
+
+			req := httptest.NewRequest(http.MethodDelete, "/posts/1", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusPreconditionFailed, rec.Code)
+			require.Equal(t, tt.wantDeletes, posts.deletes)
+		})
+	}
+}
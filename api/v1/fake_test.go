@@ -0,0 +1,224 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// fakeStorage serves the repositories the handler tests set up, calls of
+// any other repository panic.
+type fakeStorage struct {
+	storage.StorageI
+	posts      *fakePosts
+	users      *fakeUsers
+	categories *fakeCategories
+	reports    *fakeReports
+	trash      *fakeTrash
+	audits     *fakeAudit
+}
+
+func (s *fakeStorage) Post() repo.PostStorageI {
+	return s.posts
+}
+
+func (s *fakeStorage) User() repo.UserStorageI {
+	return s.users
+}
+
+func (s *fakeStorage) Category() repo.CategoryStorageI {
+	return s.categories
+}
+
+func (s *fakeStorage) Sitemap() repo.SitemapStorageI {
+	return &fakeSitemapStore{}
+}
+
+func (s *fakeStorage) Report() repo.ReportStorageI {
+	return s.reports
+}
+
+func (s *fakeStorage) Trash() repo.TrashStorageI {
+	return s.trash
+}
+
+func (s *fakeStorage) Audit() repo.AuditStorageI {
+	return s.audits
+}
+
+func (s *fakeStorage) WithTx(fn func(strg storage.StorageI) error) error {
+	return fn(s)
+}
+
+func (s *fakeStorage) WithContext(ctx context.Context) storage.StorageI {
+	return s
+}
+
+// fakePosts holds one post. Delete fails with deleteErr, as if another
+// request changed the post between Get and Delete. GetAll lists list up to
+// the limit and keeps the last query.
+type fakePosts struct {
+	repo.PostStorageI
+	post      repo.Post
+	list      []*repo.Post
+	query     repo.GetPostQuery
+	deleteErr error
+	deletes   []int
+}
+
+func (p *fakePosts) Get(id int) (*repo.Post, error) {
+	post := p.post
+	return &post, nil
+}
+
+func (p *fakePosts) GetAll(param repo.GetPostQuery) (*repo.GetAllPostResult, error) {
+	p.query = param
+	posts := p.list
+	if len(posts) > param.Limit {
+		posts = posts[:param.Limit]
+	}
+	return &repo.GetAllPostResult{Post: posts, Count: len(p.list)}, nil
+}
+
+func (p *fakePosts) Delete(id, version int) error {
+	p.deletes = append(p.deletes, version)
+	return p.deleteErr
+}
+
+func (p *fakePosts) UpdateStatus(ids []int, status string, moderatorId int) (int, error) {
+	p.post.Status = status
+	return len(ids), nil
+}
+
+type fakeUsers struct {
+	repo.UserStorageI
+	users map[int]*repo.User
+}
+
+func newFakeUsers(users ...*repo.User) *fakeUsers {
+	f := &fakeUsers{users: make(map[int]*repo.User)}
+	for _, u := range users {
+		f.users[u.Id] = u
+	}
+	return f
+}
+
+func (f *fakeUsers) Get(id int) (*repo.User, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	user := *u
+	return &user, nil
+}
+
+func (f *fakeUsers) GetByIds(ids []int) (map[int]*repo.User, error) {
+	users := make(map[int]*repo.User)
+	for _, id := range ids {
+		if u, ok := f.users[id]; ok {
+			users[id] = u
+		}
+	}
+	return users, nil
+}
+
+func (f *fakeUsers) Update(u *repo.User) (*repo.User, error) {
+	user := *u
+	user.Version++
+	f.users[u.Id] = &user
+	return &user, nil
+}
+
+func (f *fakeUsers) Delete(id, version int) error {
+	delete(f.users, id)
+	return nil
+}
+
+type fakeCategories struct {
+	repo.CategoryStorageI
+	categories map[int]*repo.Category
+}
+
+func newFakeCategories(categories ...*repo.Category) *fakeCategories {
+	f := &fakeCategories{categories: make(map[int]*repo.Category)}
+	for _, category := range categories {
+		f.categories[category.Id] = category
+	}
+	return f
+}
+
+func (f *fakeCategories) Get(id int) (*repo.Category, error) {
+	category, ok := f.categories[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return category, nil
+}
+
+func (f *fakeCategories) GetByIds(ids []int) (map[int]*repo.Category, error) {
+	categories := make(map[int]*repo.Category)
+	for _, id := range ids {
+		if category, ok := f.categories[id]; ok {
+			categories[id] = category
+		}
+	}
+	return categories, nil
+}
+
+// fakeReports records the status reports were hidden with.
+type fakeReports struct {
+	repo.ReportStorageI
+	hidden map[int]string
+}
+
+func (r *fakeReports) Hide(id int, previousStatus string) error {
+	if r.hidden == nil {
+		r.hidden = make(map[int]string)
+	}
+	r.hidden[id] = previousStatus
+	return nil
+}
+
+type fakeAudit struct {
+	repo.AuditStorageI
+	entries []*repo.AuditEntry
+}
+
+func (a *fakeAudit) Add(e *repo.AuditEntry) error {
+	a.entries = append(a.entries, e)
+	return nil
+}
+
+// fakeTrash fails restores with restoreErr.
+type fakeTrash struct {
+	repo.TrashStorageI
+	restoreErr error
+}
+
+func (tr *fakeTrash) Restore(itemType string, id int) error {
+	return tr.restoreErr
+}
+
+// fakeSitemapStore lists a single post.
+type fakeSitemapStore struct {
+	repo.SitemapStorageI
+}
+
+func (f *fakeSitemapStore) GetPostMaxId() (int, error) {
+	return 1, nil
+}
+
+func (f *fakeSitemapStore) GetPosts(fromId, toId int) ([]*repo.SitemapEntry, error) {
+	return []*repo.SitemapEntry{{Id: 1, UpdatedAt: time.Now()}}, nil
+}
+
+func (f *fakeSitemapStore) GetCategories() ([]*repo.SitemapEntry, error) {
+	return nil, nil
+}
+
+func (f *fakeSitemapStore) GetAuthors() ([]*repo.SitemapEntry, error) {
+	return nil, nil
+}
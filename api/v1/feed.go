@@ -0,0 +1,262 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/feed"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// @Router /feeds/{format} [get]
+// @Summary RSS or Atom feed of the latest posts
+// @Description Also served per category at /feeds/categories/{id}/{format} and per author at /feeds/authors/{id}/{format}
+// @Tags feeds
+// @Produce xml
+// @Param format path string true "rss.xml or atom.xml"
+// @Param limit query int false "Number of items"
+// @Param content query string false "full or excerpt"
+// @Success 200 {string} string
+// @Success 304 {string} string
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetFeed(c *gin.Context) {
+	query, err := h.validateGetFeedQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	f, err := h.buildFeed(c, query)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var (
+		body        []byte
+		contentType string
+	)
+	if path.Base(c.FullPath()) == "atom.xml" {
+		body, err = feed.Atom(f)
+		contentType = "application/atom+xml; charset=utf-8"
+	} else {
+		body, err = feed.RSS(f)
+		contentType = "application/rss+xml; charset=utf-8"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if !f.Updated.IsZero() {
+		c.Header("Last-Modified", f.Updated.UTC().Format(http.TimeFormat))
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+
+	if notModified(c, etag, f.Updated) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+type feedQuery struct {
+	posts       repo.GetPostQuery
+	fullContent bool
+}
+
+func (h *handlerV1) validateGetFeedQuery(c *gin.Context) (*feedQuery, error) {
+	q := feedQuery{
+		posts: repo.GetPostQuery{
+			Page:              1,
+			Limit:             h.cfg.Feed.Items,
+			Status:            repo.PostStatusApproved,
+			HideBannedAuthors: true,
+		},
+		fullContent: h.cfg.Feed.FullContent,
+	}
+
+	if c.Query("limit") != "" {
+		limit, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("invalid limit %q", c.Query("limit"))
+		}
+		q.posts.Limit = limit
+	}
+	if q.posts.Limit > h.cfg.Feed.MaxItems {
+		q.posts.Limit = h.cfg.Feed.MaxItems
+	}
+
+	switch c.Query("content") {
+	case "":
+	case "full":
+		q.fullContent = true
+	case "excerpt":
+		q.fullContent = false
+	default:
+		return nil, fmt.Errorf("content must be full or excerpt")
+	}
+
+	if c.Param("id") != "" {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(c.FullPath(), "/feeds/categories/") {
+			q.posts.CategoryId = id
+		} else {
+			q.posts.UserId = id
+		}
+	}
+
+	return &q, nil
+}
+
+func (h *handlerV1) buildFeed(c *gin.Context, q *feedQuery) (*feed.Feed, error) {
+	site := strings.TrimRight(h.cfg.Site.Url, "/")
+	f := feed.Feed{
+		Title:       h.cfg.Site.Title,
+		Link:        site,
+		Self:        site + c.Request.URL.Path,
+		Description: h.cfg.Site.Description,
+	}
+
+	switch {
+	case q.posts.CategoryId > 0:
+		category, err := h.store(c).Category().Get(q.posts.CategoryId)
+		if err != nil {
+			return nil, err
+		}
+		f.Title += " - " + category.Title
+		f.Link = fmt.Sprintf("%s/categories/%d", site, category.Id)
+	case q.posts.UserId > 0:
+		user, err := h.store(c).User().Get(q.posts.UserId)
+		if err != nil {
+			return nil, err
+		}
+		// banned authors have no feed, just like their posts are left out
+		// of every other one
+		if user.CurrentStatus() == repo.UserStatusBanned {
+			return nil, sql.ErrNoRows
+		}
+		f.Title += " - " + strings.TrimSpace(user.FirstName+" "+user.LastName)
+		f.Link = fmt.Sprintf("%s/authors/%d", site, user.Id)
+	}
+
+	posts, err := h.store(c).Post().GetAll(q.posts)
+	if err != nil {
+		return nil, err
+	}
+
+	authors, categories, err := h.feedNames(c, posts.Post)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range posts.Post {
+		if p.UpdatedAt.After(f.Updated) {
+			f.Updated = p.UpdatedAt
+		}
+
+		link := fmt.Sprintf("%s/posts/%d", site, p.Id)
+		item := feed.Item{
+			Id:        link,
+			Title:     p.Title,
+			Link:      link,
+			Author:    authors[p.UserId],
+			Category:  categories[p.CategoryId],
+			Summary:   p.Excerpt,
+			Published: p.CreatedAt,
+			Updated:   p.UpdatedAt,
+		}
+		if q.fullContent {
+			item.Content = p.DescriptionHtml
+		}
+		f.Items = append(f.Items, &item)
+	}
+
+	return &f, nil
+}
+
+// feedNames resolves the display names of the authors and categories of
+// posts, keyed by their ids, with one query each. A missing author or
+// category only leaves the field empty.
+func (h *handlerV1) feedNames(c *gin.Context, posts []*repo.Post) (map[string]string, map[string]string, error) {
+	var userIds, categoryIds []int
+	for _, p := range posts {
+		if id, err := strconv.Atoi(p.UserId); err == nil {
+			userIds = append(userIds, id)
+		}
+		if id, err := strconv.Atoi(p.CategoryId); err == nil {
+			categoryIds = append(categoryIds, id)
+		}
+	}
+
+	users, err := h.store(c).User().GetByIds(unique(userIds))
+	if err != nil {
+		return nil, nil, err
+	}
+	categories, err := h.store(c).Category().GetByIds(unique(categoryIds))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authorNames := make(map[string]string, len(users))
+	for id, user := range users {
+		authorNames[strconv.Itoa(id)] = strings.TrimSpace(user.FirstName + " " + user.LastName)
+	}
+	categoryTitles := make(map[string]string, len(categories))
+	for id, category := range categories {
+		categoryTitles[strconv.Itoa(id)] = category.Title
+	}
+	return authorNames, categoryTitles, nil
+}
+
+// notModified evaluates If-None-Match and If-Modified-Since; the ETag wins
+// when a client sends both.
+func notModified(c *gin.Context, etag string, modified time.Time) bool {
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimSpace(t)
+			if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err == nil && !modified.Truncate(time.Second).After(since) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func newFeedRouter(t *testing.T) (*gin.Engine, *fakeStorage) {
+	t.Helper()
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	past := time.Now().Add(-time.Hour)
+	strg := &fakeStorage{
+		posts: &fakePosts{list: []*repo.Post{
+			{Id: 1, Title: "First", Excerpt: "first excerpt", DescriptionHtml: "<p>first</p>", UserId: "1", CategoryId: "1", CreatedAt: updated, UpdatedAt: updated},
+			{Id: 2, Title: "Second", Excerpt: "second excerpt", DescriptionHtml: "<p>second</p>", UserId: "1", CategoryId: "1", CreatedAt: updated, UpdatedAt: updated.Add(-time.Hour)},
+		}},
+		users: newFakeUsers(
+			&repo.User{Id: 1, FirstName: "Ann", LastName: "Lee", Status: repo.UserStatusActive},
+			&repo.User{Id: 2, FirstName: "Bob", Status: repo.UserStatusBanned},
+			&repo.User{Id: 3, FirstName: "Eve", Status: repo.UserStatusBanned, StatusExpiresAt: &past},
+		),
+		categories: newFakeCategories(&repo.Category{Id: 1, Title: "Go"}),
+	}
+	h := New(&HandlerV1Options{
+		Cfg: &config.Config{
+			Site: config.SiteConfig{Url: "https://example.com/", Title: "Blog"},
+			Feed: config.FeedConfig{Items: 1, MaxItems: 5, FullContent: true},
+		},
+		Storage: strg,
+	})
+
+	router := gin.New()
+	for _, format := range []string{"rss.xml", "atom.xml"} {
+		router.GET("/feeds/"+format, h.GetFeed)
+		router.GET("/feeds/categories/:id/"+format, h.GetFeed)
+		router.GET("/feeds/authors/:id/"+format, h.GetFeed)
+	}
+	return router, strg
+}
+
+func getFeed(router *gin.Engine, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGetFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		want        int
+		contentType string
+		contains    []string
+		notContains []string
+	}{
+		{
+			name:        "rss",
+			target:      "/feeds/rss.xml",
+			want:        http.StatusOK,
+			contentType: "application/rss+xml; charset=utf-8",
+			contains:    []string{"<title>Blog</title>", "<title>First</title>", "<dc:creator>Ann Lee</dc:creator>", "<![CDATA[<p>first</p>]]>"},
+			notContains: []string{"<title>Second</title>"},
+		},
+		{
+			name:        "atom",
+			target:      "/feeds/atom.xml",
+			want:        http.StatusOK,
+			contentType: "application/atom+xml; charset=utf-8",
+			contains:    []string{`<feed xmlns="http://www.w3.org/2005/Atom">`, "<id>https://example.com/feeds/atom.xml</id>", "<name>Ann Lee</name>"},
+		},
+		{
+			name:        "excerpt",
+			target:      "/feeds/rss.xml?content=excerpt",
+			want:        http.StatusOK,
+			contains:    []string{"<description>first excerpt</description>"},
+			notContains: []string{"content:encoded>"},
+		},
+		{name: "invalid content", target: "/feeds/rss.xml?content=summary", want: http.StatusBadRequest},
+		{name: "invalid limit", target: "/feeds/rss.xml?limit=0", want: http.StatusBadRequest},
+		{
+			name:     "category",
+			target:   "/feeds/categories/1/rss.xml",
+			want:     http.StatusOK,
+			contains: []string{"<title>Blog - Go</title>", "<link>https://example.com/categories/1</link>"},
+		},
+		{name: "unknown category", target: "/feeds/categories/9/rss.xml", want: http.StatusNotFound},
+		{
+			name:     "author",
+			target:   "/feeds/authors/1/atom.xml",
+			want:     http.StatusOK,
+			contains: []string{"<title>Blog - Ann Lee</title>", `href="https://example.com/authors/1"`},
+		},
+		{name: "unknown author", target: "/feeds/authors/9/rss.xml", want: http.StatusNotFound},
+		{name: "banned author", target: "/feeds/authors/2/rss.xml", want: http.StatusNotFound, notContains: []string{"Bob"}},
+		{name: "expired ban", target: "/feeds/authors/3/rss.xml", want: http.StatusOK, contains: []string{"<title>Blog - Eve</title>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newFeedRouter(t)
+			rec := getFeed(router, tt.target, nil)
+			require.Equal(t, tt.want, rec.Code, rec.Body.String())
+			if tt.contentType != "" {
+				require.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+			}
+			for _, s := range tt.contains {
+				require.Contains(t, rec.Body.String(), s)
+			}
+			for _, s := range tt.notContains {
+				require.NotContains(t, rec.Body.String(), s)
+			}
+		})
+	}
+}
+
+func TestGetFeedQuery(t *testing.T) {
+	router, strg := newFeedRouter(t)
+
+	require.Equal(t, http.StatusOK, getFeed(router, "/feeds/rss.xml", nil).Code)
+	require.Equal(t, 1, strg.posts.query.Limit)
+	require.Equal(t, repo.PostStatusApproved, strg.posts.query.Status)
+	require.True(t, strg.posts.query.HideBannedAuthors)
+
+	require.Equal(t, http.StatusOK, getFeed(router, "/feeds/rss.xml?limit=3", nil).Code)
+	require.Equal(t, 3, strg.posts.query.Limit)
+
+	// limits above feed.max_items are capped
+	require.Equal(t, http.StatusOK, getFeed(router, "/feeds/rss.xml?limit=1000", nil).Code)
+	require.Equal(t, 5, strg.posts.query.Limit)
+
+	require.Equal(t, http.StatusOK, getFeed(router, "/feeds/categories/1/rss.xml", nil).Code)
+	require.Equal(t, 1, strg.posts.query.CategoryId)
+	require.Zero(t, strg.posts.query.UserId)
+
+	require.Equal(t, http.StatusOK, getFeed(router, "/feeds/authors/1/rss.xml", nil).Code)
+	require.Equal(t, 1, strg.posts.query.UserId)
+	require.Zero(t, strg.posts.query.CategoryId)
+}
+
+func TestGetFeedNotModified(t *testing.T) {
+	router, _ := newFeedRouter(t)
+	rec := getFeed(router, "/feeds/rss.xml", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	require.Equal(t, "Fri, 01 Mar 2024 12:00:00 GMT", rec.Header().Get("Last-Modified"))
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{name: "etag", header: http.Header{"If-None-Match": {etag}}, want: http.StatusNotModified},
+		{name: "strong etag", header: http.Header{"If-None-Match": {`"other", ` + etag[2:]}}, want: http.StatusNotModified},
+		{name: "changed etag", header: http.Header{"If-None-Match": {`W/"other"`}}, want: http.StatusOK},
+		{name: "modified since", header: http.Header{"If-Modified-Since": {"Fri, 01 Mar 2024 12:00:00 GMT"}}, want: http.StatusNotModified},
+		{name: "modified after", header: http.Header{"If-Modified-Since": {"Fri, 01 Mar 2024 11:59:59 GMT"}}, want: http.StatusOK},
+		{
+			name: "etag wins",
+			header: http.Header{
+				"If-None-Match":     {`W/"other"`},
+				"If-Modified-Since": {"Fri, 01 Mar 2024 12:00:00 GMT"},
+			},
+			want: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := getFeed(router, "/feeds/rss.xml", tt.header)
+			require.Equal(t, tt.want, rec.Code)
+			if tt.want == http.StatusNotModified {
+				require.Empty(t, rec.Body.String())
+			}
+		})
+	}
+}
@@ -1,23 +1,79 @@
 package v1
 
 import (
+	"database/sql"
+	"log/slog"
+	"time"
+
 	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/pkg/blob"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/samandar2605/post/pkg/health"
+	"github.com/samandar2605/post/pkg/metrics"
+	"github.com/samandar2605/post/pkg/ratelimit"
 	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/worker"
 )
 
 type handlerV1 struct {
 	cfg     *config.Config
 	storage storage.StorageI
+	blob    blob.Store
+	images  *worker.ImageProcessor
+	sitemap *worker.Sitemap
+	filter  *contentfilter.Engine
+	log     *slog.Logger
+	metrics *metrics.Metrics
+	health  *health.Checker
+	pools   map[string]*sql.DB
+
+	rateLimits ratelimit.Store
+	// startedAt is when the handler was created, for the uptime.
+	startedAt time.Time
 }
 
 type HandlerV1Options struct {
 	Cfg     *config.Config
 	Storage storage.StorageI
+	Blob    blob.Store
+	Images  *worker.ImageProcessor
+	Sitemap *worker.Sitemap
+	Filter  *contentfilter.Engine
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+	// Metrics may be nil, nothing is recorded then.
+	Metrics *metrics.Metrics
+	// Health holds the readiness checks, without it the api is always
+	// ready.
+	Health *health.Checker
+	// Pools are the database connection pools by name, for the status
+	// page.
+	Pools map[string]*sql.DB
+	// RateLimits keeps the token buckets of clients, nil disables rate
+	// limiting.
+	RateLimits ratelimit.Store
 }
 
 func New(options *HandlerV1Options) *handlerV1 {
+	log := options.Logger
+	if log == nil {
+		log = slog.Default()
+	}
+
 	return &handlerV1{
 		cfg:     options.Cfg,
 		storage: options.Storage,
+		blob:    options.Blob,
+		images:  options.Images,
+		sitemap: options.Sitemap,
+		filter:  options.Filter,
+		log:     log,
+		metrics: options.Metrics,
+		health:  options.Health,
+		pools:   options.Pools,
+
+		rateLimits: options.RateLimits,
+
+		startedAt: time.Now(),
 	}
 }
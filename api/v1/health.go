@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/health"
+)
+
+// @Router /healthz [get]
+// @Summary Liveness probe
+// @Description Answers as long as the process serves requests, without checking dependencies.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.HealthResponse
+func (h *handlerV1) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, models.HealthResponse{Status: "ok"})
+}
+
+// @Router /readyz [get]
+// @Summary Readiness probe
+// @Description Checks that the database answers a ping in time, the schema is at the expected migration and the background workers run.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.ReadinessResponse
+// @Failure 503 {object} models.ReadinessResponse
+func (h *handlerV1) Readyz(c *gin.Context) {
+	states, healthy := h.runChecks(c)
+
+	resp := models.ReadinessResponse{
+		Status: "ready",
+		Checks: make([]*models.CheckResult, 0, len(states)),
+	}
+	for _, s := range states {
+		resp.Checks = append(resp.Checks, &models.CheckResult{
+			Name:      s.Name,
+			Healthy:   s.Healthy,
+			Error:     s.Error,
+			LatencyMs: milliseconds(s.Latency),
+		})
+	}
+
+	status := http.StatusOK
+	if !healthy {
+		resp.Status = "not ready"
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, resp)
+}
+
+// @Router /debug/status [get]
+// @Summary Detailed status of the api
+// @Description Build info, uptime, database pool stats and the outcome and last failure of every readiness check. Admins only.
+// @Tags health
+// @Produce json
+// @Success 200 {object} models.StatusResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+func (h *handlerV1) GetStatus(c *gin.Context) {
+	states, _ := h.runChecks(c)
+
+	resp := models.StatusResponse{
+		Build:     buildInfo(),
+		StartedAt: h.startedAt,
+		Uptime:    time.Since(h.startedAt).Round(time.Second).String(),
+		Pools:     make(map[string]*models.PoolStats, len(h.pools)),
+		Checks:    make([]*models.CheckStatus, 0, len(states)),
+	}
+	for name, db := range h.pools {
+		stats := db.Stats()
+		resp.Pools[name] = &models.PoolStats{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDuration:       stats.WaitDuration.String(),
+		}
+	}
+	for _, s := range states {
+		resp.Checks = append(resp.Checks, &models.CheckStatus{
+			Name:        s.Name,
+			Healthy:     s.Healthy,
+			Error:       s.Error,
+			LatencyMs:   milliseconds(s.Latency),
+			CheckedAt:   timeOrNil(s.CheckedAt),
+			LastSuccess: timeOrNil(s.LastSuccess),
+			LastFailure: timeOrNil(s.LastFailure),
+			LastError:   s.LastError,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *handlerV1) runChecks(c *gin.Context) ([]health.State, bool) {
+	if h.health == nil {
+		return nil, true
+	}
+	return h.health.Check(c.Request.Context())
+}
+
+func buildInfo() models.BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return models.BuildInfo{}
+	}
+
+	result := models.BuildInfo{
+		GoVersion: info.GoVersion,
+		Version:   info.Main.Version,
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			result.Revision = s.Value
+		case "vcs.time":
+			result.Time = s.Value
+		case "vcs.modified":
+			result.Modified = s.Value == "true"
+		}
+	}
+	return result
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
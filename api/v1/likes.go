@@ -6,6 +6,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage"
 	"github.com/samandar2605/post/storage/repo"
 )
 
@@ -27,7 +28,7 @@ func (h *handlerV1) GetLike(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.storage.Like().Get(id)
+	resp, err := h.store(c).Like().Get(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
@@ -52,6 +53,8 @@ func (h *handlerV1) GetLike(c *gin.Context) {
 // @Param like body models.CreateLike true "like"
 // @Success 201 {object} models.Like
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) CreateLike(c *gin.Context) {
 	var (
@@ -60,22 +63,33 @@ func (h *handlerV1) CreateLike(c *gin.Context) {
 
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.Like().Create(&repo.Like{
-		PostId: req.PostId,
-		UserId: req.UserId,
-		Status: req.Status})
+	var resp *repo.Like
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		resp, err = strg.Like().Create(&repo.Like{
+			PostId: req.PostId,
+			UserId: req.UserId,
+			Status: req.Status})
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionCreate, repo.TrashTypeLike, resp.Id, nil, resp)
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
+	h.metrics.ReactionCreated()
 
 	c.JSON(http.StatusCreated, models.Like{
 		Id:     resp.Id,
@@ -95,18 +109,22 @@ func (h *handlerV1) CreateLike(c *gin.Context) {
 // @Param post_id query int false "post_id"
 // @Param user_id query int false "user_id"
 // @Success 200 {object} models.User
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /likes [get]
 func (h *handlerV1) GetAllLike(ctx *gin.Context) {
 	queryParams, err := validateGetLikeQuery(ctx)
 	if err != nil {
+		if abortValidation(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.Like().GetAll(queryParams)
+	resp, err := h.store(ctx).Like().GetAll(queryParams)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -118,42 +136,24 @@ func (h *handlerV1) GetAllLike(ctx *gin.Context) {
 }
 
 func validateGetLikeQuery(ctx *gin.Context) (repo.GetLikesQuery, error) {
-	var (
-		limit  int = 10
-		page   int = 1
-		postId int
-		userId int
-		err    error
-	)
-	if ctx.Query("limit") != "" {
-		limit, err = strconv.Atoi(ctx.Query("limit"))
-		if err != nil {
-			return repo.GetLikesQuery{}, err
-		}
+	var filter models.LikeFilter
+	pagination, err := bindPagination(ctx)
+	if err := mergeValidation(err, ctx.ShouldBindQuery(&filter)); err != nil {
+		return repo.GetLikesQuery{}, err
 	}
 
-	if ctx.Query("page") != "" {
-		page, err = strconv.Atoi(ctx.Query("page"))
-		if err != nil {
-			return repo.GetLikesQuery{}, err
-		}
+	postId, err := queryId(filter.PostId)
+	if err != nil {
+		return repo.GetLikesQuery{}, err
 	}
-	if ctx.Query("post_id") != "" {
-		postId, err = strconv.Atoi(ctx.Query("post_id"))
-		if err != nil {
-			return repo.GetLikesQuery{}, err
-		}
+	userId, err := queryId(filter.UserId)
+	if err != nil {
+		return repo.GetLikesQuery{}, err
 	}
 
-	if ctx.Query("user_id") != "" {
-		userId, err = strconv.Atoi(ctx.Query("user_id"))
-		if err != nil {
-			return repo.GetLikesQuery{}, err
-		}
-	}
 	return repo.GetLikesQuery{
-		Limit:  limit,
-		Page:   page,
+		Limit:  pagination.Limit,
+		Page:   pagination.Page,
 		PostId: postId,
 		UserId: userId,
 	}, nil
@@ -167,13 +167,18 @@ func validateGetLikeQuery(ctx *gin.Context) (repo.GetLikesQuery, error) {
 // @Param id path int true "ID"
 // @Param like body models.CreateLike true "like"
 // @Success 200 {object} models.Like
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /likes/{id} [put]
 func (h *handlerV1) UpdateLike(ctx *gin.Context) {
-	var b repo.Like
+	var req models.CreateLike
 
-	err := ctx.ShouldBindJSON(&b)
+	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
@@ -188,8 +193,24 @@ func (h *handlerV1) UpdateLike(ctx *gin.Context) {
 		return
 	}
 
-	b.Id = id
-	like, err := h.storage.Like().Update(&b)
+	b := repo.Like{
+		Id:     id,
+		PostId: req.PostId,
+		UserId: req.UserId,
+		Status: req.Status,
+	}
+	var like *repo.Like
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Like().Get(id)
+		if err != nil {
+			return err
+		}
+		like, err = strg.Like().Update(&b)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypeLike, id, before, like)
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to create like",
@@ -201,7 +222,7 @@ func (h *handlerV1) UpdateLike(ctx *gin.Context) {
 }
 
 // @Summary Delete a like
-// @Description Delete a like
+// @Description The like is moved to the trash and can be restored by an admin until it is purged
 // @Tags Like
 // @Accept json
 // @Produce json
@@ -217,7 +238,16 @@ func (h *handlerV1) DeleteLike(ctx *gin.Context) {
 		return
 	}
 
-	err = h.storage.Like().Delete(id)
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Like().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := strg.Like().Delete(id); err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionDelete, repo.TrashTypeLike, id, before, nil)
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to Delete method",
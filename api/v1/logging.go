@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/logger"
+)
+
+const requestIdHeader = "X-Request-ID"
+
+// requestIdRegex limits the ids taken from clients to what is safe to log.
+var requestIdRegex = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestId takes the id of the request from the X-Request-ID header, or
+// generates one, and keeps it in the request context for logs and audit
+// entries. The id is echoed in the response header and in the body of json
+// error responses.
+func (h *handlerV1) RequestId(c *gin.Context) {
+	id := c.GetHeader(requestIdHeader)
+	if !requestIdRegex.MatchString(id) {
+		id = newRequestId()
+	}
+
+	c.Request = c.Request.WithContext(logger.WithRequestId(c.Request.Context(), id))
+	c.Header(requestIdHeader, id)
+	c.Writer = &requestIdWriter{ResponseWriter: c.Writer, requestId: id}
+	c.Next()
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestId returns the id assigned by the RequestId middleware.
+func requestId(c *gin.Context) string {
+	return logger.RequestId(c.Request.Context())
+}
+
+// requestIdWriter adds a request_id member to json error bodies.
+type requestIdWriter struct {
+	gin.ResponseWriter
+	requestId string
+}
+
+func (w *requestIdWriter) Write(b []byte) (int, error) {
+	if w.Written() || w.Status() < http.StatusBadRequest || len(b) < 2 || b[0] != '{' ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		return w.ResponseWriter.Write(b)
+	}
+
+	id, _ := json.Marshal(w.requestId)
+	body := append([]byte(`{"request_id":`), id...)
+	if b[1] != '}' {
+		body = append(body, ',')
+	}
+	body = append(body, b[1:]...)
+	if _, err := w.ResponseWriter.Write(body); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// AccessLog logs method, route, status and latency of every request once it
+// was served. Client errors are logged as warnings, server errors as errors.
+func (h *handlerV1) AccessLog(c *gin.Context) {
+	start := time.Now()
+	c.Next()
+
+	status := c.Writer.Status()
+	level := slog.LevelInfo
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case status >= http.StatusBadRequest:
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", c.Request.Method),
+		slog.String("route", c.FullPath()),
+		slog.String("path", c.Request.URL.Path),
+		slog.Int("status", status),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		slog.Int("bytes", c.Writer.Size()),
+		slog.String("ip", c.ClientIP()),
+	}
+	if user := getAuthUser(c); user != nil {
+		attrs = append(attrs, slog.Int("user_id", user.Id))
+	}
+	if len(c.Errors) > 0 {
+		attrs = append(attrs, slog.String("errors", c.Errors.String()))
+	}
+	h.log.LogAttrs(c.Request.Context(), level, "request", attrs...)
+}
+
+// Recover logs a panic of a handler and answers 500.
+func (h *handlerV1) Recover(c *gin.Context, err interface{}) {
+	h.log.ErrorContext(c.Request.Context(), "handler panicked",
+		"error", fmt.Sprint(err),
+		"stack", string(debug.Stack()),
+	)
+	c.AbortWithStatusJSON(http.StatusInternalServerError, models.ErrorResponse{
+		Error: "internal server error",
+	})
+}
@@ -0,0 +1,276 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/blob"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// mediaTypes maps the sniffed content types we accept to file extensions.
+var mediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// @Router /media [post]
+// @Summary Upload an image
+// @Description Upload an image for a post or user avatar as the authenticated user. The returned url can be used as image_url or profile_image_url.
+// @Tags media
+// @Accept mpfd
+// @Produce json
+// @Param file formData file true "Image"
+// @Success 201 {object} models.Media
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) UploadMedia(c *gin.Context) {
+	maxSize := h.cfg.Media.MaxUploadSize
+	// leave room for the multipart envelope around the file itself
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+				Error: fmt.Sprintf("file is larger than %d bytes", maxSize),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Error: fmt.Sprintf("file is larger than %d bytes", maxSize),
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	// the client supplied Content-Type is not trusted, sniff the bytes instead
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	ext, ok := mediaTypes[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, models.ErrorResponse{
+			Error: "unsupported file type " + contentType,
+		})
+		return
+	}
+
+	key, err := newMediaKey(ext)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	err = h.blob.Put(
+		c.Request.Context(),
+		key,
+		io.MultiReader(bytes.NewReader(head), file),
+		fileHeader.Size,
+		contentType,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var resp *repo.Media
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		resp, err = strg.Media().Create(&repo.Media{
+			ObjectKey:   key,
+			Url:         h.mediaUrl(key),
+			ContentType: contentType,
+			Size:        fileHeader.Size,
+			UserId:      getAuthUser(c).Id,
+		})
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionCreate, repo.AuditEntityMedia, resp.Id, nil, resp)
+	})
+	if err != nil {
+		_ = h.blob.Delete(c.Request.Context(), key)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if h.images != nil {
+		h.images.Enqueue(resp.Id)
+	}
+
+	c.JSON(http.StatusCreated, models.Media{
+		Id:          resp.Id,
+		Url:         resp.Url,
+		ContentType: resp.ContentType,
+		Size:        resp.Size,
+		UserId:      resp.UserId,
+		Status:      resp.Status,
+		Renditions:  make([]*models.Rendition, 0),
+		CreatedAt:   resp.CreatedAt,
+	})
+}
+
+// ServeMedia streams an uploaded file from the blob store. Keys are random
+// and never reused, so responses can be cached forever.
+func (h *handlerV1) ServeMedia(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	rc, obj, err := h.blob.Get(c.Request.Context(), key)
+	if errors.Is(err, blob.ErrNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "media not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	defer rc.Close()
+
+	c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, rc, map[string]string{
+		"Cache-Control": "public, max-age=31536000, immutable",
+	})
+}
+
+// @Router /media/{id} [get]
+// @Summary Get uploaded media by id
+// @Description Get uploaded media by id with the renditions generated so far
+// @Tags media
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} models.Media
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetMedia(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp, err := h.store(c).Media().Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "media not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	renditions, err := h.store(c).Media().GetRenditions(resp.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Media{
+		Id:          resp.Id,
+		Url:         resp.Url,
+		ContentType: resp.ContentType,
+		Size:        resp.Size,
+		UserId:      resp.UserId,
+		Status:      resp.Status,
+		Renditions:  renditionsResponse(renditions),
+		CreatedAt:   resp.CreatedAt,
+	})
+}
+
+func (h *handlerV1) mediaUrl(key string) string {
+	return strings.TrimRight(h.cfg.Media.BaseUrl, "/") + "/media/" + key
+}
+
+// checkMediaUrl rejects urls that point into our media store but do not
+// belong to an uploaded file. External urls are accepted as is.
+func (h *handlerV1) checkMediaUrl(c *gin.Context, url string) error {
+	prefix := h.mediaUrl("")
+	if url == "" || !strings.HasPrefix(url, prefix) {
+		return nil
+	}
+
+	_, err := h.store(c).Media().GetByKey(strings.TrimPrefix(url, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("unknown media url %s", url)
+	}
+	return err
+}
+
+func renditionsResponse(rs []*repo.MediaRendition) []*models.Rendition {
+	result := make([]*models.Rendition, 0, len(rs))
+	for _, r := range rs {
+		result = append(result, &models.Rendition{
+			Name:        r.Name,
+			Url:         r.Url,
+			ContentType: r.ContentType,
+			Width:       r.Width,
+			Height:      r.Height,
+		})
+	}
+	return result
+}
+
+func newMediaKey(ext string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return time.Now().UTC().Format("2006/01/") + hex.EncodeToString(b) + ext, nil
+}
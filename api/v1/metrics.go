@@ -0,0 +1,18 @@
+package v1
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Instrument records the count and latency of requests by route, and how
+// many are in flight.
+func (h *handlerV1) Instrument(c *gin.Context) {
+	done := h.metrics.RequestStarted()
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	done(c.Request.Method, route, c.Writer.Status())
+}
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticateAccountStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		status    string
+		expiresAt *time.Time
+		method    string
+		want      int
+	}{
+		{name: "active write", status: repo.UserStatusActive, method: http.MethodPost, want: http.StatusOK},
+		{name: "banned read", status: repo.UserStatusBanned, method: http.MethodGet, want: http.StatusForbidden},
+		{name: "banned until later", status: repo.UserStatusBanned, expiresAt: &future, method: http.MethodPost, want: http.StatusForbidden},
+		{name: "expired ban", status: repo.UserStatusBanned, expiresAt: &past, method: http.MethodPost, want: http.StatusOK},
+		{name: "suspended read", status: repo.UserStatusSuspended, method: http.MethodGet, want: http.StatusOK},
+		{name: "suspended write", status: repo.UserStatusSuspended, method: http.MethodPost, want: http.StatusForbidden},
+		{name: "expired suspension", status: repo.UserStatusSuspended, expiresAt: &past, method: http.MethodPost, want: http.StatusOK},
+		{name: "deactivated", status: repo.UserStatusDeactivated, method: http.MethodGet, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := &fakeStorage{users: newFakeUsers(&repo.User{Id: 1, Status: tt.status, StatusExpiresAt: tt.expiresAt})}
+			cfg := &config.Config{Auth: config.AuthConfig{GatewaySecret: "secret"}}
+			h := New(&HandlerV1Options{Cfg: cfg, Storage: strg})
+			router := gin.New()
+			router.Use(h.Authenticate)
+			router.Handle(tt.method, "/", func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.Header.Set("X-User-Id", "1")
+			req.Header.Set("X-Gateway-Secret", "secret")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			require.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
+
+func TestCheckAuthorStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	strg := &fakeStorage{users: newFakeUsers(
+		&repo.User{Id: 1, Status: repo.UserStatusActive},
+		&repo.User{Id: 2, Status: repo.UserStatusBanned, StatusReason: "spam"},
+		&repo.User{Id: 3, Status: repo.UserStatusSuspended},
+		&repo.User{Id: 4, Status: repo.UserStatusBanned, StatusExpiresAt: &past},
+	)}
+	h := New(&HandlerV1Options{Cfg: &config.Config{}, Storage: strg})
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+
+	require.NoError(t, h.checkAuthorStatus(c, 1))
+	err := h.checkAuthorStatus(c, 2)
+	require.ErrorIs(t, err, errAccountInactive)
+	require.ErrorContains(t, err, "banned: spam")
+	require.ErrorIs(t, h.checkAuthorStatus(c, 3), errAccountInactive)
+	require.NoError(t, h.checkAuthorStatus(c, 4))
+	// unknown authors are left to the foreign keys
+	require.NoError(t, h.checkAuthorStatus(c, 5))
+}
+
+func TestSetUserStatusOwn(t *testing.T) {
+	h := New(&HandlerV1Options{Cfg: &config.Config{}, Storage: &fakeStorage{}})
+	router := gin.New()
+	router.PUT("/users/:id/status", func(c *gin.Context) {
+		c.Set(authUserKey, &repo.User{Id: 1, Type: "admin"})
+	}, h.SetUserStatus)
+
+	req := httptest.NewRequest(http.MethodPut, "/users/1/status", strings.NewReader(`{"status":"banned"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Contains(t, rec.Body.String(), "can not change their own status")
+}
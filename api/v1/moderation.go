@@ -0,0 +1,317 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// commentStatus decides whether a comment by user is published right away
+// or waits in the moderation queue. Anonymous comments are always queued.
+func (h *handlerV1) commentStatus(c *gin.Context, user *repo.User) (string, error) {
+	if user == nil {
+		return repo.CommentStatusPending, nil
+	}
+
+	rules := h.cfg.Moderation
+	if rules.AutoApproveAdmins && isAdmin(user) {
+		return repo.CommentStatusApproved, nil
+	}
+	for _, id := range rules.TrustedUserIds {
+		if id == user.Id {
+			return repo.CommentStatusApproved, nil
+		}
+	}
+	if rules.AutoApproveAfter > 0 {
+		approved, err := h.store(c).Comment().CountByUser(user.Id, repo.CommentStatusApproved)
+		if err != nil {
+			return "", err
+		}
+		if approved >= rules.AutoApproveAfter {
+			return repo.CommentStatusApproved, nil
+		}
+	}
+
+	return repo.CommentStatusPending, nil
+}
+
+var errContentRejected = errors.New("rejected by the content filter")
+
+// filteredCommentStatus runs the content filter before the auto-approve
+// rules: content it is unsure about always waits for a moderator. The
+// user id of content is that of user when authenticated.
+func (h *handlerV1) filteredCommentStatus(c *gin.Context, user *repo.User, content *contentfilter.Content) (string, error) {
+	content.Kind = "comment"
+	if user != nil {
+		content.UserId = user.Id
+	}
+
+	switch h.checkContent(c, user, content) {
+	case contentfilter.Reject:
+		return "", errContentRejected
+	case contentfilter.Moderate:
+		return repo.CommentStatusPending, nil
+	}
+	return h.commentStatus(c, user)
+}
+
+// @Router /admin/comments/moderation [get]
+// @Summary Comment moderation queue
+// @Description Comments waiting for review, or any other status via ?status=
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Limit"
+// @Param page query int false "Page"
+// @Param post_id query int false "post_id"
+// @Param user_id query int false "user_id"
+// @Param status query string false "pending (default), approved, rejected or spam"
+// @Success 200 {object} models.GetAllCommentsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetModerationQueue(c *gin.Context) {
+	query, err := validateGetCommentQuery(c)
+	if err != nil {
+		if abortValidation(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if query.Status == "" {
+		query.Status = repo.CommentStatusPending
+	}
+
+	result, err := h.store(c).Comment().GetAll(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp := models.GetAllCommentsResponse{
+		Comments: make([]*models.Comment, 0, len(result.Comments)),
+		Count:    result.Count,
+	}
+	for _, comment := range result.Comments {
+		resp.Comments = append(resp.Comments, parseCommentModel(comment))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+var moderationActions = map[string]string{
+	"approve": repo.CommentStatusApproved,
+	"reject":  repo.CommentStatusRejected,
+	"spam":    repo.CommentStatusSpam,
+}
+
+// parseModerationRequest binds the bulk moderation body shared by comments
+// and posts and returns the status the action leads to.
+func parseModerationRequest(c *gin.Context) (*models.ModerateRequest, string, error) {
+	var req models.ModerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return nil, "", err
+	}
+
+	return &req, moderationActions[req.Action], nil
+}
+
+// @Router /admin/comments/moderation [post]
+// @Summary Approve or reject comments in bulk
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param moderation body models.ModerateRequest true "moderation"
+// @Success 200 {object} models.ModerateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) ModerateComments(c *gin.Context) {
+	req, status, err := parseModerationRequest(c)
+	if err != nil {
+		if abortBind(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var updated int
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		before := make([]*repo.Comment, 0, len(req.Ids))
+		for _, id := range req.Ids {
+			comment, err := strg.Comment().Get(id)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			before = append(before, comment)
+		}
+
+		updated, err = strg.Comment().UpdateStatus(req.Ids, status, getAuthUser(c).Id)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range before {
+			after, err := strg.Comment().Get(b.Id)
+			if err != nil {
+				return err
+			}
+			err = audit(c, strg, repo.AuditActionModerate, repo.TrashTypeComment, b.Id, b, after)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ModerateResponse{
+		Status:  status,
+		Updated: updated,
+	})
+}
+
+// @Router /admin/posts/moderation [get]
+// @Summary Post moderation queue
+// @Description Posts held back by the content filter, or any other status via ?status=
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Limit"
+// @Param page query int false "Page"
+// @Param category_id query int false "Category id"
+// @Param user_id query int false "Author id"
+// @Param status query string false "pending (default), approved, rejected or spam"
+// @Success 200 {object} models.GetAllPostsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetPostModerationQueue(c *gin.Context) {
+	query, err := validateGetPostQuery(c)
+	if err != nil {
+		if abortValidation(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if query.Status == "" {
+		query.Status = repo.PostStatusPending
+	}
+
+	result, err := h.store(c).Post().GetAll(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp := models.GetAllPostsResponse{
+		Posts: make([]*models.Post, 0, len(result.Post)),
+		Count: result.Count,
+	}
+	for _, p := range result.Post {
+		resp.Posts = append(resp.Posts, parsePostModel(p, nil))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// @Router /admin/posts/moderation [post]
+// @Summary Approve or reject posts in bulk
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param moderation body models.ModerateRequest true "moderation"
+// @Success 200 {object} models.ModerateResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) ModeratePosts(c *gin.Context) {
+	req, status, err := parseModerationRequest(c)
+	if err != nil {
+		if abortBind(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var updated int
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		before := make([]*repo.Post, 0, len(req.Ids))
+		for _, id := range req.Ids {
+			post, err := strg.Post().Get(id)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			before = append(before, post)
+		}
+
+		updated, err = strg.Post().UpdateStatus(req.Ids, status, getAuthUser(c).Id)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range before {
+			after, err := strg.Post().Get(b.Id)
+			if err != nil {
+				return err
+			}
+			err = audit(c, strg, repo.AuditActionModerate, repo.TrashTypePost, b.Id, b, after)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ModerateResponse{
+		Status:  status,
+		Updated: updated,
+	})
+}
@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+var errUnsupportedPatch = errors.New("patches must be sent as " + mergePatchContentType)
+
+// bindMergePatch decodes a JSON merge patch (RFC 7396) of a flat resource
+// into dst, whose fields are pointers so that members left out stay nil.
+// It returns the members set to null, which reset a field. The binding
+// rules are checked for the members that were sent.
+func bindMergePatch(c *gin.Context, dst interface{}) ([]string, error) {
+	switch c.ContentType() {
+	case mergePatchContentType, "application/json":
+	default:
+		return nil, errUnsupportedPatch
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(body, &members); err != nil || members == nil {
+		return nil, errors.New("merge patch must be a JSON object")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return nil, err
+	}
+	if err := validate(dst); err != nil {
+		return nil, err
+	}
+
+	nulls := make([]string, 0)
+	for name, value := range members {
+		if string(bytes.TrimSpace(value)) == "null" {
+			nulls = append(nulls, name)
+		}
+	}
+	return nulls, nil
+}
+
+// resetNulls points the fields of members that were set to null at an empty
+// value. Only the members in nullable may be null.
+func resetNulls(nulls []string, nullable map[string]**string) error {
+	for _, name := range nulls {
+		field, ok := nullable[name]
+		if !ok {
+			return fmt.Errorf("%s can not be null", name)
+		}
+		*field = new(string)
+	}
+	return nil
+}
+
+// abortBadPatch answers errors of bindMergePatch and resetNulls.
+func abortBadPatch(c *gin.Context, err error) {
+	if abortBind(c, err) {
+		return
+	}
+	status := http.StatusBadRequest
+	if errors.Is(err, errUnsupportedPatch) {
+		status = http.StatusUnsupportedMediaType
+	}
+	c.JSON(status, models.ErrorResponse{
+		Error: err.Error(),
+	})
+}
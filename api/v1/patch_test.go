@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type testPatch struct {
+	Title *string `json:"title" binding:"omitempty,notblank,max=10"`
+	Note  *string `json:"note" binding:"omitempty,max=10"`
+}
+
+func TestBindMergePatch(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		want        testPatch
+	}{
+		{
+			name: "members left out stay nil",
+			body: `{"title":"new"}`,
+			want: testPatch{Title: str("new")},
+		},
+		{
+			name:        "merge patch content type",
+			contentType: mergePatchContentType,
+			body:        `{"note":"new"}`,
+			want:        testPatch{Note: str("new")},
+		},
+		{
+			name: "null clears a nullable member",
+			body: `{"title":"new","note":null}`,
+			want: testPatch{Title: str("new"), Note: str("")},
+		},
+		{
+			name:       "null on a required member",
+			body:       `{"title":null}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown member",
+			body:       `{"title":"new","author":"x"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not an object",
+			body:       `["title"]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "malformed json",
+			body:       `{"title":`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "broken binding rule",
+			body:       `{"title":"  "}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "other content type",
+			contentType: "text/plain",
+			body:        `{"title":"new"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(tt.body))
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			c.Request.Header.Set("Content-Type", contentType)
+
+			var patch testPatch
+			nulls, err := bindMergePatch(c, &patch)
+			if err == nil {
+				err = resetNulls(nulls, map[string]**string{
+					"note": &patch.Note,
+				})
+			}
+
+			if tt.wantStatus != 0 {
+				require.Error(t, err)
+				abortBadPatch(c, err)
+				require.Equal(t, tt.wantStatus, rec.Code)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, patch)
+		})
+	}
+}
@@ -1,12 +1,17 @@
 package v1
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/samandar2605/post/storage"
 	"github.com/samandar2605/post/storage/repo"
+	"github.com/samandar2605/post/worker"
 )
 
 // @Router /posts/{id} [get]
@@ -16,7 +21,14 @@ import (
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
+// @Param fields query string false "Comma separated members to return, e.g. id,title,excerpt"
+// @Param include query string false "Comma separated related resources to embed: author, category, reactions"
+// @Param If-None-Match header string false "ETag of the cached version, 304 if it is still current"
 // @Success 200 {object} models.Post
+// @Success 304 {string} string
+// @Header 200 {string} ETag "version of the entity and its image renditions, a hash of the response when resources are included"
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) GetPost(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -27,36 +39,83 @@ func (h *handlerV1) GetPost(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.storage.Post().Get(id)
+	view, err := bindPostView(c)
 	if err != nil {
+		if abortValidation(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.Post{
-		Id:          resp.Id,
-		Title:       resp.Title,
-		Description: resp.Description,
-		ImageUrl:    resp.ImageUrl,
-		UserId:      resp.UserId,
-		CategoryId:  resp.CategoryId,
-		UpdatedAt:   resp.UpdatedAt,
-		ViewsCount:  resp.ViewsCount,
-		CreatedAt:   resp.CreatedAt,
-	})
+	resp, err := h.store(c).Post().Get(id)
+	if err == nil {
+		var visible bool
+		if visible, err = h.canSeePost(c, getAuthUser(c), resp); err == nil && !visible {
+			err = sql.ErrNoRows
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	renditions, err := h.store(c).Media().GetRenditionsByUrls([]string{resp.ImageUrl})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	post := parsePostModel(resp, renditions)
+	if err := h.embedPosts(c, []*models.Post{post}, view.Include); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	body, err := selectFields(post, view.Fields)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	// the version does not change with the included resources
+	if len(view.Include) > 0 {
+		h.writeCacheable(c, body)
+		return
+	}
+	if h.cacheable(c, renditionsTag(resp.Version, post.ImageRenditions)) {
+		return
+	}
+	c.JSON(http.StatusOK, body)
 }
 
 // @Router /posts [post]
 // @Summary Create a post
-// @Description Create a post
+// @Description Posts the content filter is unsure about are created with status pending and wait for moderation
 // @Tags post
 // @Accept json
 // @Produce json
 // @Param post body models.CreatePost true "post"
 // @Success 201 {object} models.Post
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) CreatePost(c *gin.Context) {
 	var (
@@ -65,19 +124,76 @@ func (h *handlerV1) CreatePost(c *gin.Context) {
 
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.Post().Create(&repo.Post{
+	if err := h.checkMediaUrl(c, req.ImageUrl); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	user := getAuthUser(c)
+	if user != nil {
+		req.UserId = strconv.Itoa(user.Id)
+	}
+	authorId, _ := strconv.Atoi(req.UserId)
+	if err := h.checkAuthorStatus(c, authorId); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errAccountInactive) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	status := repo.PostStatusApproved
+	switch h.checkContent(c, user, &contentfilter.Content{
+		Kind:   "post",
+		UserId: authorId,
+		Text:   req.Title + "\n" + req.Description,
+	}) {
+	case contentfilter.Reject:
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error: "post rejected by the content filter",
+		})
+		return
+	case contentfilter.Moderate:
+		status = repo.PostStatusPending
+	}
+
+	post := repo.Post{
 		Title:       req.Title,
 		Description: req.Description,
 		ImageUrl:    req.ImageUrl,
 		UserId:      req.UserId,
 		CategoryId:  req.CategoryId,
 		ViewsCount:  req.ViewsCount,
+		Status:      status,
+	}
+	if err := worker.RenderDescription(&post); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var resp *repo.Post
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		resp, err = strg.Post().Create(&post)
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionCreate, repo.TrashTypePost, resp.Id, nil, resp)
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -85,18 +201,63 @@ func (h *handlerV1) CreatePost(c *gin.Context) {
 		})
 		return
 	}
+	h.metrics.PostCreated()
 
-	c.JSON(http.StatusCreated, models.Post{
-		Id:          resp.Id,
-		Title:       resp.Title,
-		Description: resp.Description,
-		ImageUrl:    resp.ImageUrl,
-		UserId:      resp.UserId,
-		CategoryId:  resp.CategoryId,
-		ViewsCount:  resp.ViewsCount,
-		UpdatedAt:   resp.UpdatedAt,
-		CreatedAt:   resp.CreatedAt,
-	})
+	renditions, err := h.store(c).Media().GetRenditionsByUrls([]string{resp.ImageUrl})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, parsePostModel(resp, renditions))
+}
+
+// canSeePost hides posts that are not approved from everyone but admins and
+// their author, and posts of banned users from everyone but admins.
+func (h *handlerV1) canSeePost(c *gin.Context, user *repo.User, p *repo.Post) (bool, error) {
+	if isAdmin(user) {
+		return true, nil
+	}
+	authorId, _ := strconv.Atoi(p.UserId)
+	if p.Status != repo.PostStatusApproved && (user == nil || user.Id != authorId) {
+		return false, nil
+	}
+	banned, err := h.isBanned(c, authorId)
+	return !banned, err
+}
+
+func parsePostModel(p *repo.Post, renditions map[string][]*repo.MediaRendition) *models.Post {
+	toc := make([]*models.TocEntry, 0, len(p.Toc))
+	for _, e := range p.Toc {
+		toc = append(toc, &models.TocEntry{
+			Level: e.Level,
+			Title: e.Title,
+			Id:    e.Id,
+		})
+	}
+
+	return &models.Post{
+		Id:              p.Id,
+		Title:           p.Title,
+		Description:     p.Description,
+		DescriptionHtml: p.DescriptionHtml,
+		Toc:             toc,
+		ReadingTime:     p.ReadingTime,
+		Excerpt:         p.Excerpt,
+		ImageUrl:        p.ImageUrl,
+		UserId:          p.UserId,
+		CategoryId:      p.CategoryId,
+		ViewsCount:      p.ViewsCount,
+		Status:          p.Status,
+		ModeratedBy:     p.ModeratedBy,
+		ModeratedAt:     p.ModeratedAt,
+		Version:         p.Version,
+		UpdatedAt:       p.UpdatedAt,
+		CreatedAt:       p.CreatedAt,
+		ImageRenditions: renditionsResponse(renditions[p.ImageUrl]),
+	}
 }
 
 // @Summary Get post
@@ -107,11 +268,37 @@ func (h *handlerV1) CreatePost(c *gin.Context) {
 // @Param limit query int true "Limit"
 // @Param page query int true "Page"
 // @Param search query string false "Search"
-// @Success 200 {object} models.Post
+// @Param category_id query int false "Category id"
+// @Param user_id query int false "Author id"
+// @Param status query string false "pending, approved, rejected or spam (admins only)"
+// @Param fields query string false "Comma separated members to return for each post, e.g. id,title,excerpt"
+// @Param include query string false "Comma separated related resources to embed: author, category, reactions"
+// @Param If-None-Match header string false "ETag of the cached page, 304 if it is still current"
+// @Success 200 {object} models.GetAllPostsResponse
+// @Success 304 {string} string
+// @Header 200 {string} ETag "hash of the page"
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /posts [get]
 func (h *handlerV1) GetPostAll(ctx *gin.Context) {
 	queryParams, err := validateGetPostQuery(ctx)
+	view, viewErr := bindPostView(ctx)
+	if err = mergeValidation(err, viewErr); err != nil {
+		if abortValidation(ctx, err) {
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if !isAdmin(getAuthUser(ctx)) {
+		queryParams.Status = repo.PostStatusApproved
+		queryParams.HideBannedAuthors = true
+	}
+
+	resp, err := h.store(ctx).Post().GetAll(queryParams)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -119,7 +306,11 @@ func (h *handlerV1) GetPostAll(ctx *gin.Context) {
 		return
 	}
 
-	resp, err := h.storage.Post().GetAll(queryParams)
+	urls := make([]string, 0, len(resp.Post))
+	for _, p := range resp.Post {
+		urls = append(urls, p.ImageUrl)
+	}
+	renditions, err := h.store(ctx).Media().GetRenditionsByUrls(urls)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -127,33 +318,65 @@ func (h *handlerV1) GetPostAll(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
-}
+	result := models.GetAllPostsResponse{
+		Posts: make([]*models.Post, 0, len(resp.Post)),
+		Count: resp.Count,
+	}
+	for _, p := range resp.Post {
+		result.Posts = append(result.Posts, parsePostModel(p, renditions))
+	}
+	if err := h.embedPosts(ctx, result.Posts, view.Include); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 
-func validateGetPostQuery(ctx *gin.Context) (repo.GetPostQuery, error) {
-	var (
-		limit int = 10
-		page  int = 1
-		err   error
-	)
-	if ctx.Query("limit") != "" {
-		limit, err = strconv.Atoi(ctx.Query("limit"))
+	if len(view.Fields) == 0 {
+		h.writeCacheable(ctx, result)
+		return
+	}
+	posts := make([]interface{}, 0, len(result.Posts))
+	for _, p := range result.Posts {
+		post, err := selectFields(p, view.Fields)
 		if err != nil {
-			return repo.GetPostQuery{}, err
+			ctx.JSON(http.StatusInternalServerError, gin.H{
+				"message": err.Error(),
+			})
+			return
 		}
+		posts = append(posts, post)
 	}
+	h.writeCacheable(ctx, gin.H{
+		"Post":  posts,
+		"Count": result.Count,
+	})
+}
 
-	if ctx.Query("page") != "" {
-		page, err = strconv.Atoi(ctx.Query("page"))
-		if err != nil {
-			return repo.GetPostQuery{}, err
-		}
+func validateGetPostQuery(ctx *gin.Context) (repo.GetPostQuery, error) {
+	var filter models.PostFilter
+	pagination, err := bindPagination(ctx)
+	if err := mergeValidation(err, ctx.ShouldBindQuery(&filter)); err != nil {
+		return repo.GetPostQuery{}, err
+	}
+
+	categoryId, err := queryId(filter.CategoryId)
+	if err != nil {
+		return repo.GetPostQuery{}, err
+	}
+	userId, err := queryId(filter.UserId)
+	if err != nil {
+		return repo.GetPostQuery{}, err
 	}
 
 	return repo.GetPostQuery{
-		Limit:  limit,
-		Page:   page,
-		Search: ctx.Query("search")}, nil
+		Limit:      pagination.Limit,
+		Page:       pagination.Page,
+		Search:     filter.Search,
+		CategoryId: categoryId,
+		UserId:     userId,
+		Status:     filter.Status,
+	}, nil
 }
 
 // @Summary Update a post
@@ -163,14 +386,22 @@ func validateGetPostQuery(ctx *gin.Context) (repo.GetPostQuery, error) {
 // @Produce json
 // @Param id path int true "ID"
 // @Param user body models.CreatePost true "post"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
 // @Success 200 {object} models.Post
+// @Header 200 {string} ETag "version of the entity and its image renditions"
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /posts/{id} [put]
 func (h *handlerV1) UpdatePost(ctx *gin.Context) {
-	var b repo.Post
+	var req models.CreatePost
 
-	err := ctx.ShouldBindJSON(&b)
+	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
@@ -185,8 +416,48 @@ func (h *handlerV1) UpdatePost(ctx *gin.Context) {
 		return
 	}
 
-	b.Id = id
-	post, err := h.storage.Post().Update(&b)
+	if err := h.checkMediaUrl(ctx, req.ImageUrl); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	b := repo.Post{
+		Id:          id,
+		Title:       req.Title,
+		Description: req.Description,
+		ImageUrl:    req.ImageUrl,
+		UserId:      req.UserId,
+		CategoryId:  req.CategoryId,
+		ViewsCount:  req.ViewsCount,
+	}
+	if err := worker.RenderDescription(&b); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var post *repo.Post
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Post().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		b.Version = before.Version
+		post, err = strg.Post().Update(&b)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypePost, id, before, post)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to create post",
@@ -194,15 +465,138 @@ func (h *handlerV1) UpdatePost(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, post)
+	renditions, err := h.store(ctx).Media().GetRenditionsByUrls([]string{post.ImageUrl})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
+
+	model := parsePostModel(post, renditions)
+	ctx.Header("ETag", renditionsTag(post.Version, model.ImageRenditions))
+	ctx.JSON(http.StatusOK, model)
+}
+
+// @Summary Partially update a post
+// @Description Applies a JSON merge patch (RFC 7396): only the members sent are changed and image_url can be cleared with null
+// @Tags post
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param post body models.PatchPost true "merge patch"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Success 200 {object} models.Post
+// @Header 200 {string} ETag "version of the entity and its image renditions"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /posts/{id} [patch]
+func (h *handlerV1) PatchPost(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var req models.PatchPost
+	nulls, err := bindMergePatch(ctx, &req)
+	if err == nil {
+		err = resetNulls(nulls, map[string]**string{"image_url": &req.ImageUrl})
+	}
+	if err != nil {
+		abortBadPatch(ctx, err)
+		return
+	}
+
+	if req.ImageUrl != nil {
+		if err := h.checkMediaUrl(ctx, *req.ImageUrl); err != nil {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+	}
+
+	patch := repo.PostPatch{
+		Title:      req.Title,
+		ImageUrl:   req.ImageUrl,
+		CategoryId: req.CategoryId,
+	}
+	if req.Description != nil {
+		rendered := repo.Post{Description: *req.Description}
+		if err := worker.RenderDescription(&rendered); err != nil {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		patch.Description = req.Description
+		patch.DescriptionHtml = rendered.DescriptionHtml
+		patch.Toc = rendered.Toc
+		patch.ReadingTime = rendered.ReadingTime
+		patch.Excerpt = rendered.Excerpt
+	}
+
+	var post *repo.Post
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Post().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		patch.Version = before.Version
+		post, err = strg.Post().Patch(id, &patch)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypePost, id, before, post)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	renditions, err := h.store(ctx).Media().GetRenditionsByUrls([]string{post.ImageUrl})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	model := parsePostModel(post, renditions)
+	ctx.Header("ETag", renditionsTag(post.Version, model.ImageRenditions))
+	ctx.JSON(http.StatusOK, model)
 }
 
 // @Summary Delete a posts
-// @Description Delete a posts
+// @Description The post is moved to the trash and can be restored by an admin until it is purged
 // @Tags post
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Failure 412 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /posts/{id} [delete]
 func (h *handlerV1) DeletePost(ctx *gin.Context) {
@@ -214,7 +608,22 @@ func (h *handlerV1) DeletePost(ctx *gin.Context) {
 		return
 	}
 
-	err = h.storage.Post().Delete(id)
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.Post().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		if err := strg.Post().Delete(id, before.Version); err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionDelete, repo.TrashTypePost, id, before, nil)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to Delete method",
@@ -0,0 +1,64 @@
+package v1
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/pkg/ratelimit"
+)
+
+// RateLimit answers 429 once a client used up its token bucket. Clients are
+// told by user id when authenticated, unless configured to go by ip, and by
+// ip otherwise, which is only taken from trusted proxies. Writes have a
+// bucket of their own with a stricter limit.
+//
+// The state of the bucket is sent in the RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers.
+// Requests are let through when the store fails.
+func (h *handlerV1) RateLimit(c *gin.Context) {
+	if h.rateLimits == nil {
+		c.Next()
+		return
+	}
+
+	cfg := h.cfg.RateLimit
+	class, limit := "read", ratelimit.Limit{Rate: cfg.ReadRate, Burst: cfg.ReadBurst}
+	switch c.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		class, limit = "write", ratelimit.Limit{Rate: cfg.WriteRate, Burst: cfg.WriteBurst}
+	}
+
+	client := "ip:" + c.ClientIP()
+	if user := getAuthUser(c); user != nil && cfg.KeyBy == "user" {
+		client = "user:" + strconv.Itoa(user.Id)
+	}
+
+	res, err := h.rateLimits.Take(c.Request.Context(), class+":"+client, limit, time.Now())
+	if err != nil {
+		h.log.WarnContext(c.Request.Context(), "rate limit store failed", "error", err)
+		c.Next()
+		return
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(limit.Window())))
+	if !res.Allowed {
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, models.ErrorResponse{
+			Error: "rate limit exceeded",
+		})
+		return
+	}
+	c.Next()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,528 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// @Router /reports [post]
+// @Summary Report a post, comment or user
+// @Description Reports on the same target are grouped, reporting a target twice has no effect. Targets reported by enough users are hidden until an admin resolves the report.
+// @Tags reports
+// @Accept json
+// @Produce json
+// @Param report body models.CreateReport true "report"
+// @Success 201 {object} models.Report
+// @Success 200 {object} models.Report
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) CreateReport(c *gin.Context) {
+	var req models.CreateReport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if abortBind(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	_, err := h.reportTargetAuthor(c, req.TargetType, req.TargetId)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "target not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var (
+		report *repo.Report
+		added  bool
+	)
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		entry := repo.ReportEntry{
+			ReporterId: getAuthUser(c).Id,
+			Reason:     req.Reason,
+			Details:    req.Details,
+		}
+		report, added, err = strg.Report().Add(req.TargetType, req.TargetId, &entry)
+		if err != nil {
+			return err
+		}
+		if !added {
+			return nil
+		}
+		if err := audit(c, strg, repo.AuditActionCreate, repo.AuditEntityReport, report.Id, nil, entry); err != nil {
+			return err
+		}
+
+		threshold := h.cfg.Moderation.ReportHideThreshold
+		if threshold > 0 && report.ReportCount >= threshold && !report.Hidden {
+			if err := hideReportTarget(c, strg, report); err != nil {
+				return err
+			}
+			report.Hidden = true
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusCreated
+	if !added {
+		status = http.StatusOK
+	}
+	c.JSON(status, parseReportModel(report))
+}
+
+// reportTargetAuthor returns the user responsible for a reported target,
+// sql.ErrNoRows if it does not exist.
+func (h *handlerV1) reportTargetAuthor(c *gin.Context, targetType string, targetId int) (int, error) {
+	switch targetType {
+	case repo.ReportTargetPost:
+		post, err := h.store(c).Post().Get(targetId)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(post.UserId)
+	case repo.ReportTargetComment:
+		comment, err := h.store(c).Comment().Get(targetId)
+		if err != nil {
+			return 0, err
+		}
+		return comment.UserId, nil
+	case repo.ReportTargetUser:
+		user, err := h.store(c).User().Get(targetId)
+		if err != nil {
+			return 0, err
+		}
+		return user.Id, nil
+	}
+	return 0, fmt.Errorf("target_type must be post, comment or user")
+}
+
+// setReportTargetStatus changes the moderation status of a reported post to
+// postStatus or of a reported comment to commentStatus, and returns the
+// status it had. User profiles have no status; they are hidden through the
+// report. Targets deleted in the meantime are left alone.
+func setReportTargetStatus(c *gin.Context, strg storage.StorageI, report *repo.Report, postStatus, commentStatus string, actorId int) (string, error) {
+	var (
+		previous      string
+		before, after interface{}
+	)
+	switch report.TargetType {
+	case repo.ReportTargetPost:
+		post, err := strg.Post().Get(report.TargetId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := strg.Post().UpdateStatus([]int{report.TargetId}, postStatus, actorId); err != nil {
+			return "", err
+		}
+		previous, before = post.Status, post
+		if after, err = strg.Post().Get(report.TargetId); err != nil {
+			return "", err
+		}
+	case repo.ReportTargetComment:
+		comment, err := strg.Comment().Get(report.TargetId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := strg.Comment().UpdateStatus([]int{report.TargetId}, commentStatus, actorId); err != nil {
+			return "", err
+		}
+		previous, before = comment.Status, comment
+		if after, err = strg.Comment().Get(report.TargetId); err != nil {
+			return "", err
+		}
+	default:
+		return "", nil
+	}
+	return previous, audit(c, strg, repo.AuditActionModerate, report.TargetType, report.TargetId, before, after)
+}
+
+// hideReportTarget sends reported posts and comments back to the
+// moderation queue, remembering their status for when the report is
+// dismissed.
+func hideReportTarget(c *gin.Context, strg storage.StorageI, report *repo.Report) error {
+	previous, err := setReportTargetStatus(c, strg, report, repo.PostStatusPending, repo.CommentStatusPending, 0)
+	if err != nil {
+		return err
+	}
+	if err := strg.Report().Hide(report.Id, previous); err != nil {
+		return err
+	}
+	after := *report
+	after.Hidden, after.HiddenStatus = true, previous
+	return audit(c, strg, repo.AuditActionHide, repo.AuditEntityReport, report.Id, report, &after)
+}
+
+// @Router /admin/reports [get]
+// @Summary List reports
+// @Description Most reported targets first
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Limit"
+// @Param page query int false "Page"
+// @Param status query string false "open (default), dismissed, removed, suspended or all"
+// @Param target_type query string false "post, comment or user"
+// @Success 200 {object} models.GetAllReportsResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetReports(c *gin.Context) {
+	query, err := validateGetReportsQuery(c)
+	if err != nil {
+		if abortValidation(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.store(c).Report().GetAll(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp := models.GetAllReportsResponse{
+		Reports: make([]*models.Report, 0, len(result.Reports)),
+		Count:   result.Count,
+	}
+	for _, r := range result.Reports {
+		resp.Reports = append(resp.Reports, parseReportModel(r))
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func validateGetReportsQuery(c *gin.Context) (repo.GetReportsQuery, error) {
+	pagination, err := bindPagination(c)
+	if err != nil {
+		return repo.GetReportsQuery{}, err
+	}
+
+	status := c.DefaultQuery("status", repo.ReportStatusOpen)
+	switch status {
+	case "all":
+		status = ""
+	case repo.ReportStatusOpen, repo.ReportStatusDismissed,
+		repo.ReportStatusRemoved, repo.ReportStatusSuspended:
+	default:
+		return repo.GetReportsQuery{}, fmt.Errorf("unknown status %q", status)
+	}
+
+	targetType := c.Query("target_type")
+	switch targetType {
+	case "", repo.ReportTargetPost, repo.ReportTargetComment, repo.ReportTargetUser:
+	default:
+		return repo.GetReportsQuery{}, fmt.Errorf("unknown target_type %q", targetType)
+	}
+
+	return repo.GetReportsQuery{
+		Limit:      pagination.Limit,
+		Page:       pagination.Page,
+		Status:     status,
+		TargetType: targetType,
+	}, nil
+}
+
+// @Router /admin/reports/{id} [get]
+// @Summary Get a report with its entries and audit trail
+// @Tags admin
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {object} models.ReportDetails
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	details, err := h.reportDetails(c, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+func (h *handlerV1) reportDetails(c *gin.Context, id int) (*models.ReportDetails, error) {
+	report, err := h.store(c).Report().Get(id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := h.store(c).Report().GetEntries(id)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := h.store(c).Report().GetActions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	details := models.ReportDetails{
+		Report:  parseReportModel(report),
+		Entries: make([]*models.ReportEntry, 0, len(entries)),
+		Actions: make([]*models.ReportAction, 0, len(actions)),
+	}
+	for _, e := range entries {
+		details.Entries = append(details.Entries, &models.ReportEntry{
+			Id:         e.Id,
+			ReporterId: e.ReporterId,
+			Reason:     e.Reason,
+			Details:    e.Details,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	for _, a := range actions {
+		details.Actions = append(details.Actions, &models.ReportAction{
+			Id:        a.Id,
+			ActorId:   a.ActorId,
+			Action:    a.Action,
+			Note:      a.Note,
+			CreatedAt: a.CreatedAt,
+		})
+	}
+
+	return &details, nil
+}
+
+// @Router /admin/reports/{id}/resolve [post]
+// @Summary Resolve a report
+// @Description dismiss restores the status hidden content had, remove rejects the reported post or comment, suspend rejects it too and suspends its author (or the reported user)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param resolution body models.ResolveReport true "resolution"
+// @Success 200 {object} models.ReportDetails
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) ResolveReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var req models.ResolveReport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if abortBind(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	report, err := h.store(c).Report().Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if report.Status != repo.ReportStatusOpen {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "report is already " + report.Status,
+		})
+		return
+	}
+	if err := validateResolution(report, &req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	actor := getAuthUser(c)
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		status, err := h.applyReportResolution(c, strg, report, &req, actor.Id)
+		if err != nil {
+			return err
+		}
+		if err := strg.Report().Resolve(report.Id, status, actor.Id, req.Note); err != nil {
+			return err
+		}
+		after, err := strg.Report().Get(report.Id)
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionResolve, repo.AuditEntityReport, report.Id, report, after)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: "report was resolved concurrently",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	details, err := h.reportDetails(c, report.Id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, details)
+}
+
+func validateResolution(report *repo.Report, req *models.ResolveReport) error {
+	switch req.Action {
+	case "dismiss", "suspend":
+		return nil
+	case "remove":
+		if report.TargetType == repo.ReportTargetUser {
+			return fmt.Errorf("user profiles can not be removed, suspend the user instead")
+		}
+		return nil
+	}
+	return fmt.Errorf("action must be dismiss, remove or suspend")
+}
+
+// applyReportResolution changes the reported content and its author and
+// returns the status to close the report with.
+func (h *handlerV1) applyReportResolution(c *gin.Context, strg storage.StorageI, report *repo.Report, req *models.ResolveReport, actorId int) (string, error) {
+	isContent := report.TargetType != repo.ReportTargetUser
+
+	switch req.Action {
+	case "dismiss":
+		// content hidden before its status was kept goes back to approved
+		if report.Hidden && isContent {
+			postStatus, commentStatus := repo.PostStatusApproved, repo.CommentStatusApproved
+			if report.HiddenStatus != "" {
+				postStatus, commentStatus = report.HiddenStatus, report.HiddenStatus
+			}
+			if _, err := setReportTargetStatus(c, strg, report, postStatus, commentStatus, actorId); err != nil {
+				return "", err
+			}
+		}
+		return repo.ReportStatusDismissed, nil
+
+	case "remove":
+		if _, err := setReportTargetStatus(c, strg, report, repo.PostStatusRejected, repo.CommentStatusRejected, actorId); err != nil {
+			return "", err
+		}
+		return repo.ReportStatusRemoved, nil
+
+	case "suspend":
+		userId, err := h.reportTargetAuthor(c, report.TargetType, report.TargetId)
+		if err != nil {
+			return "", err
+		}
+		if isContent {
+			if _, err := setReportTargetStatus(c, strg, report, repo.PostStatusRejected, repo.CommentStatusRejected, actorId); err != nil {
+				return "", err
+			}
+		}
+
+		change := repo.UserStatusChange{
+			UserId:    userId,
+			Status:    repo.UserStatusSuspended,
+			Reason:    req.Note,
+			ChangedBy: actorId,
+			ReportId:  report.Id,
+		}
+		if req.SuspendDays > 0 {
+			expires := time.Now().AddDate(0, 0, req.SuspendDays)
+			change.ExpiresAt = &expires
+		}
+		if err := setUserStatus(c, strg, &change); err != nil {
+			return "", err
+		}
+		return repo.ReportStatusSuspended, nil
+	}
+
+	return "", fmt.Errorf("action must be dismiss, remove or suspend")
+}
+
+func parseReportModel(r *repo.Report) *models.Report {
+	return &models.Report{
+		Id:          r.Id,
+		TargetType:  r.TargetType,
+		TargetId:    r.TargetId,
+		Status:      r.Status,
+		ReportCount: r.ReportCount,
+		Hidden:      r.Hidden,
+		ResolvedBy:  r.ResolvedBy,
+		ResolvedAt:  r.ResolvedAt,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHideReportTargetKeepsStatus(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	strg := &fakeStorage{
+		posts:   &fakePosts{post: repo.Post{Id: 1, Status: repo.PostStatusPending}},
+		reports: &fakeReports{},
+		audits:  &fakeAudit{},
+	}
+
+	report := &repo.Report{Id: 7, TargetType: repo.ReportTargetPost, TargetId: 1}
+	require.NoError(t, hideReportTarget(c, strg, report))
+	require.Equal(t, map[int]string{7: repo.PostStatusPending}, strg.reports.hidden)
+	require.Equal(t, repo.PostStatusPending, strg.posts.post.Status)
+}
+
+func TestDismissReportRestoresStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		hidden       bool
+		hiddenStatus string
+		wantStatus   string
+	}{
+		{name: "held by the filter before", hidden: true, hiddenStatus: repo.PostStatusPending, wantStatus: repo.PostStatusPending},
+		{name: "published before", hidden: true, hiddenStatus: repo.PostStatusApproved, wantStatus: repo.PostStatusApproved},
+		{name: "hidden before the status was kept", hidden: true, wantStatus: repo.PostStatusApproved},
+		{name: "not hidden", wantStatus: repo.PostStatusRejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+			strg := &fakeStorage{
+				posts:  &fakePosts{post: repo.Post{Id: 1, Status: repo.PostStatusRejected}},
+				audits: &fakeAudit{},
+			}
+			h := New(&HandlerV1Options{Cfg: &config.Config{}, Storage: strg})
+
+			report := &repo.Report{
+				Id:           7,
+				TargetType:   repo.ReportTargetPost,
+				TargetId:     1,
+				Hidden:       tt.hidden,
+				HiddenStatus: tt.hiddenStatus,
+			}
+			status, err := h.applyReportResolution(c, strg, report, &models.ResolveReport{Action: "dismiss"}, 2)
+			require.NoError(t, err)
+			require.Equal(t, repo.ReportStatusDismissed, status)
+			require.Equal(t, tt.wantStatus, strg.posts.post.Status)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+)
+
+// exposedHeaders are the response headers scripts on other origins may read.
+var exposedHeaders = strings.Join([]string{
+	requestIdHeader, "ETag", "Retry-After",
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy",
+}, ", ")
+
+// Cors lets browsers on the configured origins call the api. Preflight
+// requests are answered right here, with 403 for origins that are not
+// allowed; other requests from such origins go through without the
+// Access-Control headers, so the browser keeps the response from the page.
+func (h *handlerV1) Cors(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	if origin == "" {
+		c.Next()
+		return
+	}
+
+	cfg := h.cfg.Cors
+	c.Writer.Header().Add("Vary", "Origin")
+	allowed := slices.Contains(cfg.AllowedOrigins, origin)
+	if !allowed && slices.Contains(cfg.AllowedOrigins, "*") {
+		allowed = true
+		if !cfg.AllowCredentials {
+			origin = "*"
+		}
+	}
+
+	preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+	if !allowed {
+		if preflight {
+			c.AbortWithStatusJSON(http.StatusForbidden, models.ErrorResponse{
+				Error: "origin not allowed",
+			})
+			return
+		}
+		c.Next()
+		return
+	}
+
+	c.Header("Access-Control-Allow-Origin", origin)
+	if cfg.AllowCredentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		c.Header("Access-Control-Expose-Headers", exposedHeaders)
+		c.Next()
+		return
+	}
+
+	c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+	c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+	c.Header("Access-Control-Allow-Methods", strings.Join(cfg.AllowedMethods, ", "))
+	c.Header("Access-Control-Allow-Headers", strings.Join(cfg.AllowedHeaders, ", "))
+	if cfg.MaxAge > 0 {
+		c.Header("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds())))
+	}
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+const (
+	apiCSP = "default-src 'none'; frame-ancestors 'none'"
+	// swaggerCSP lets the swagger UI load its own scripts and styles, which
+	// it partly inlines.
+	swaggerCSP = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data:; frame-ancestors 'none'"
+)
+
+// SecurityHeaders sets the headers that keep browsers from sniffing, framing
+// or running content of the api. Strict-Transport-Security is only sent on
+// https, either served directly or by a proxy in front saying so in
+// X-Forwarded-Proto.
+func (h *handlerV1) SecurityHeaders(c *gin.Context) {
+	header := c.Writer.Header()
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("X-Frame-Options", "DENY")
+	header.Set("Referrer-Policy", "no-referrer")
+	if strings.HasPrefix(c.Request.URL.Path, "/swagger/") {
+		header.Set("Content-Security-Policy", swaggerCSP)
+	} else {
+		header.Set("Content-Security-Policy", apiCSP)
+	}
+
+	https := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	if maxAge := h.cfg.Server.HSTSMaxAge; https && maxAge > 0 {
+		header.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int(maxAge.Seconds())))
+	}
+	c.Next()
+}
+
+const mediaUploadRoute = "/v1/media"
+
+// LimitBody keeps request bodies to http.max_body_bytes. Bodies that say
+// they are larger are answered 413 right away, others are cut off there
+// while being read, which abortBind answers with 413. Media uploads are
+// left to UploadMedia, which allows the size of a media file.
+func (h *handlerV1) LimitBody(c *gin.Context) {
+	if c.FullPath() == mediaUploadRoute && c.Request.Method == http.MethodPost {
+		c.Next()
+		return
+	}
+
+	max := h.cfg.Server.MaxBodyBytes
+	if c.Request.ContentLength > max {
+		abortTooLarge(c, max)
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, max)
+	c.Next()
+}
+
+// abortBind answers errors of binding a request body that are not a plain
+// 400: 413 for a body over the limit of LimitBody and 422 for broken rules.
+// It reports whether it answered.
+func abortBind(c *gin.Context, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		abortTooLarge(c, maxBytesErr.Limit)
+		return true
+	}
+	return abortValidation(c, err)
+}
+
+func abortTooLarge(c *gin.Context, max int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
+		Error: fmt.Sprintf("request body is larger than %d bytes", max),
+	})
+}
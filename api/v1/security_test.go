@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          string
+		contentType   string
+		body          string
+		unknownLength bool
+		wantStatus    int
+	}{
+		{name: "within the limit", body: `{"name":"go"}`, wantStatus: http.StatusOK},
+		{name: "too large", body: `{"name":"golang"}`, wantStatus: http.StatusRequestEntityTooLarge},
+		{
+			name:          "too large without content length",
+			body:          `{"name":"golang"}`,
+			unknownLength: true,
+			wantStatus:    http.StatusRequestEntityTooLarge,
+		},
+		{name: "broken rule", body: `{"name":" "}`, wantStatus: http.StatusUnprocessableEntity},
+		{
+			name:        "media upload",
+			path:        mediaUploadRoute,
+			contentType: "multipart/form-data; boundary=x",
+			body:        strings.Repeat("x", 32),
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "json claiming to be multipart",
+			contentType: "multipart/form-data; boundary=x",
+			body:        `{"name":"` + strings.Repeat("x", 32) + `"}`,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:          "json claiming to be multipart without content length",
+			contentType:   "multipart/form-data; boundary=x",
+			body:          `{"name":"` + strings.Repeat("x", 32) + `"}`,
+			unknownLength: true,
+			wantStatus:    http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(&HandlerV1Options{
+				Cfg: &config.Config{Server: config.ServerConfig{MaxBodyBytes: 16}},
+			})
+			router := gin.New()
+			router.POST(mediaUploadRoute, h.LimitBody, func(c *gin.Context) {
+				_, err := io.ReadAll(c.Request.Body)
+				require.NoError(t, err)
+				c.Status(http.StatusOK)
+			})
+			router.POST("/v1/posts", h.LimitBody, func(c *gin.Context) {
+				var req struct {
+					Name string `json:"name" binding:"notblank"`
+				}
+				if err := c.ShouldBindJSON(&req); err != nil {
+					if abortBind(c, err) {
+						return
+					}
+					c.Status(http.StatusBadRequest)
+					return
+				}
+				c.Status(http.StatusOK)
+			})
+
+			path := tt.path
+			if path == "" {
+				path = "/v1/posts"
+			}
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(tt.body))
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+}
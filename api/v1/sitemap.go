@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+)
+
+// @Router /sitemap.xml [get]
+// @Summary Sitemap index
+// @Description Sitemap index pointing at the post, category and author sitemaps
+// @Tags sitemap
+// @Produce xml
+// @Success 200 {string} string
+// @Failure 503 {object} models.ErrorResponse
+func (h *handlerV1) GetSitemapIndex(c *gin.Context) {
+	index := h.sitemap.Index()
+	if index == nil {
+		c.Header("Retry-After", "60")
+		c.JSON(http.StatusServiceUnavailable, models.ErrorResponse{
+			Error: "sitemap is not generated yet",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=600")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", index)
+}
+
+// @Router /sitemaps/{name} [get]
+// @Summary One sitemap of the index
+// @Tags sitemap
+// @Produce xml
+// @Param name path string true "e.g. posts-1.xml"
+// @Success 200 {string} string
+// @Failure 404 {object} models.ErrorResponse
+func (h *handlerV1) GetSitemap(c *gin.Context) {
+	body, ok := h.sitemap.File(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "sitemap not found",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=600")
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
+
+// @Router /robots.txt [get]
+// @Summary robots.txt
+// @Tags sitemap
+// @Produce plain
+// @Success 200 {string} string
+func (h *handlerV1) GetRobots(c *gin.Context) {
+	site := strings.TrimRight(h.cfg.Site.Url, "/")
+
+	c.String(http.StatusOK, fmt.Sprintf(
+		"User-agent: *\nDisallow: /v1/\nDisallow: /swagger/\n\nSitemap: %s/sitemap.xml\n",
+		site,
+	))
+}
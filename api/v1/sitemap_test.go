@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/worker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSitemap(t *testing.T) {
+	sitemap := worker.NewSitemap(worker.SitemapOptions{
+		Storage:         &fakeStorage{},
+		SiteUrl:         "https://example.com",
+		RefreshInterval: time.Minute,
+		RebuildInterval: time.Hour,
+	})
+	h := New(&HandlerV1Options{Cfg: &config.Config{}, Sitemap: sitemap})
+	router := gin.New()
+	router.GET("/sitemap.xml", h.GetSitemapIndex)
+	router.GET("/sitemaps/:name", h.GetSitemap)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/sitemap.xml")
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	require.NoError(t, sitemap.Refresh())
+	rec = get("/sitemap.xml")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), "<loc>https://example.com/sitemaps/posts-1.xml</loc>")
+
+	rec = get("/sitemaps/posts-1.xml")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "<loc>https://example.com/posts/1</loc>")
+
+	require.Equal(t, http.StatusNotFound, get("/sitemaps/posts-2.xml").Code)
+}
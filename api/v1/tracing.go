@@ -0,0 +1,76 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/samandar2605/post/api/v1")
+
+// storageKey holds the storage bound to the context of the request.
+const storageKey = "storage"
+
+// Trace serves every request in a span, continuing the trace of the caller
+// when the request carries W3C trace context headers. Repository calls of
+// the handlers become children of that span.
+func (h *handlerV1) Trace(c *gin.Context) {
+	ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	ctx, span := tracer.Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Request.URL.Path),
+			attribute.String("client.address", c.ClientIP()),
+			attribute.String("request_id", requestId(c)),
+		),
+	)
+	defer span.End()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Set(storageKey, h.storage.WithContext(storage.WithSession(ctx, session(c))))
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if user := getAuthUser(c); user != nil {
+		span.SetAttributes(attribute.Int("user_id", user.Id))
+	}
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	if len(c.Errors) > 0 {
+		span.RecordError(c.Errors.Last())
+	}
+}
+
+// session tells the requests of one client apart for the storage, which
+// keeps a client's reads on the primary database right after it wrote. The
+// user id is not verified yet at this point, which is fine for this.
+func session(c *gin.Context) string {
+	if id := c.GetHeader("X-User-Id"); id != "" {
+		return "user:" + id
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// store returns the storage handlers use for the request, bound to its
+// trace by the Trace middleware.
+func (h *handlerV1) store(c *gin.Context) storage.StorageI {
+	if strg, ok := c.Get(storageKey); ok {
+		return strg.(storage.StorageI)
+	}
+	return h.storage
+}
@@ -0,0 +1,176 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// @Router /admin/trash [get]
+// @Summary List deleted items
+// @Description Most recently deleted first. Items are purged for good once the configured retention has passed.
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Limit"
+// @Param page query int false "Page"
+// @Param type query string false "post, comment, category, user or like"
+// @Success 200 {object} models.GetAllTrashResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetTrash(c *gin.Context) {
+	query, err := validateGetTrashQuery(c)
+	if err != nil {
+		if abortValidation(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	result, err := h.store(c).Trash().GetAll(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp := models.GetAllTrashResponse{
+		Items: make([]*models.TrashItem, 0, len(result.Items)),
+		Count: result.Count,
+	}
+	for _, item := range result.Items {
+		resp.Items = append(resp.Items, &models.TrashItem{
+			Type:      item.Type,
+			Id:        item.Id,
+			Title:     item.Title,
+			DeletedAt: item.DeletedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func validateGetTrashQuery(c *gin.Context) (repo.GetTrashQuery, error) {
+	pagination, err := bindPagination(c)
+	if err != nil {
+		return repo.GetTrashQuery{}, err
+	}
+
+	itemType := c.Query("type")
+	if itemType != "" {
+		if err := validateTrashType(itemType); err != nil {
+			return repo.GetTrashQuery{}, err
+		}
+	}
+
+	return repo.GetTrashQuery{
+		Limit: pagination.Limit,
+		Page:  pagination.Page,
+		Type:  itemType,
+	}, nil
+}
+
+func validateTrashType(itemType string) error {
+	for _, t := range repo.TrashTypes {
+		if t == itemType {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown type %q", itemType)
+}
+
+// @Router /admin/trash/{type}/{id}/restore [post]
+// @Summary Restore a deleted item
+// @Description Users whose email, username or phone number was taken since are not restored, 409 names the field
+// @Tags admin
+// @Produce json
+// @Param type path string true "post, comment, category, user or like"
+// @Param id path int true "ID"
+// @Success 200 {object} models.ResponseOK
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) RestoreTrashItem(c *gin.Context) {
+	itemType := c.Param("type")
+	if err := validateTrashType(itemType); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		if err := strg.Trash().Restore(itemType, id); err != nil {
+			return err
+		}
+		after, err := getTrashItem(strg, itemType, id)
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionRestore, itemType, id, nil, after)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
+	var dup *repo.DuplicateError
+	if errors.As(err, &dup) {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error: dup.Error() + " by another user",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResponseOK{
+		Message: "restored",
+	})
+}
+
+// getTrashItem loads a restored item for the audit log.
+func getTrashItem(strg storage.StorageI, itemType string, id int) (interface{}, error) {
+	switch itemType {
+	case repo.TrashTypePost:
+		return strg.Post().Get(id)
+	case repo.TrashTypeComment:
+		return strg.Comment().Get(id)
+	case repo.TrashTypeCategory:
+		return strg.Category().Get(id)
+	case repo.TrashTypeUser:
+		return strg.User().Get(id)
+	case repo.TrashTypeLike:
+		return strg.Like().Get(id)
+	}
+	return nil, fmt.Errorf("unknown type %q", itemType)
+}
@@ -0,0 +1,49 @@
+package v1
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreTrashItemFails(t *testing.T) {
+	tests := []struct {
+		name       string
+		restoreErr error
+		wantStatus int
+		wantError  string
+	}{
+		{name: "purged", restoreErr: sql.ErrNoRows, wantStatus: http.StatusNotFound, wantError: "not found"},
+		{
+			name:       "taken email",
+			restoreErr: &repo.DuplicateError{Field: "email"},
+			wantStatus: http.StatusConflict,
+			wantError:  "email is already taken by another user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strg := &fakeStorage{trash: &fakeTrash{restoreErr: tt.restoreErr}, audits: &fakeAudit{}}
+			h := New(&HandlerV1Options{Cfg: &config.Config{}, Storage: strg})
+			router := gin.New()
+			router.POST("/admin/trash/:type/:id/restore", h.RestoreTrashItem)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/trash/user/1/restore", nil))
+
+			require.Equal(t, tt.wantStatus, rec.Code)
+			var resp models.ErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			require.Equal(t, tt.wantError, resp.Error)
+			require.Empty(t, strg.audits.entries)
+		})
+	}
+}
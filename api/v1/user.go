@@ -1,11 +1,14 @@
 package v1
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage"
 	"github.com/samandar2605/post/storage/repo"
 )
 
@@ -17,6 +20,8 @@ import (
 // @Produce json
 // @Param id path int true "ID"
 // @Success 200 {object} models.User
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) GetUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
@@ -27,7 +32,13 @@ func (h *handlerV1) GetUser(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.storage.User().Get(id)
+	resp, err := h.store(c).User().Get(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
@@ -35,18 +46,33 @@ func (h *handlerV1) GetUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.User{
-		Id:              resp.Id,
-		FirstName:       resp.FirstName,
-		LastName:        resp.LastName,
-		PhoneNumber:     resp.PhoneNumber,
-		Email:           resp.Email,
-		Gender:          resp.Gender,
-		Password:        resp.Password,
-		Username:        resp.UserName,
-		ProfileImageUrl: resp.ProfileImageUrl,
-		Type:            resp.Type,
-	})
+	// profiles hidden by reports stay visible to admins reviewing them
+	if !isAdmin(getAuthUser(c)) {
+		hidden, err := h.store(c).Report().IsHidden(repo.ReportTargetUser, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+		if hidden {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error: "not found",
+			})
+			return
+		}
+	}
+
+	renditions, err := h.store(c).Media().GetRenditionsByUrls([]string{resp.ProfileImageUrl})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.Header("ETag", etag(resp.Version))
+	c.JSON(http.StatusOK, parseUserModel(resp, renditions))
 }
 
 // @Router /users [post]
@@ -58,6 +84,8 @@ func (h *handlerV1) GetUser(c *gin.Context) {
 // @Param user body models.CreateUser true "user"
 // @Success 201 {object} models.User
 // @Failure 400 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 func (h *handlerV1) CreateUser(c *gin.Context) {
 	var (
@@ -66,22 +94,39 @@ func (h *handlerV1) CreateUser(c *gin.Context) {
 
 	err := c.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error: err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.User().Create(&repo.User{
-		FirstName:       req.FirstName,
-		LastName:        req.LastName,
-		PhoneNumber:     req.PhoneNumber,
-		Email:           req.Email,
-		Gender:          req.Gender,
-		UserName:        req.Username,
-		Password:        req.Password,
-		ProfileImageUrl: req.ProfileImageUrl,
-		Type:            req.Type,
+	if err := h.checkMediaUrl(c, req.ProfileImageUrl); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var resp *repo.User
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		resp, err = strg.User().Create(&repo.User{
+			FirstName:       req.FirstName,
+			LastName:        req.LastName,
+			PhoneNumber:     req.PhoneNumber,
+			Email:           req.Email,
+			Gender:          req.Gender,
+			UserName:        req.Username,
+			Password:        req.Password,
+			ProfileImageUrl: req.ProfileImageUrl,
+			Type:            "user",
+		})
+		if err != nil {
+			return err
+		}
+		return audit(c, strg, repo.AuditActionCreate, repo.TrashTypeUser, resp.Id, nil, resp)
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -90,18 +135,35 @@ func (h *handlerV1) CreateUser(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, models.User{
-		Id:              resp.Id,
-		FirstName:       resp.FirstName,
-		LastName:        resp.LastName,
-		PhoneNumber:     resp.PhoneNumber,
-		Email:           resp.Email,
-		Gender:          resp.Gender,
-		Password:        resp.Password,
-		Username:        resp.UserName,
-		ProfileImageUrl: resp.ProfileImageUrl,
-		Type:            resp.Type,
-	})
+	renditions, err := h.store(c).Media().GetRenditionsByUrls([]string{resp.ProfileImageUrl})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, parseUserModel(resp, renditions))
+}
+
+func parseUserModel(u *repo.User, renditions map[string][]*repo.MediaRendition) *models.User {
+	return &models.User{
+		Id:                     u.Id,
+		FirstName:              u.FirstName,
+		LastName:               u.LastName,
+		PhoneNumber:            u.PhoneNumber,
+		Email:                  u.Email,
+		Gender:                 u.Gender,
+		Password:               u.Password,
+		Username:               u.UserName,
+		ProfileImageUrl:        u.ProfileImageUrl,
+		Type:                   u.Type,
+		Status:                 u.CurrentStatus(),
+		StatusReason:           u.StatusReason,
+		StatusExpiresAt:        u.StatusExpiresAt,
+		Version:                u.Version,
+		ProfileImageRenditions: renditionsResponse(renditions[u.ProfileImageUrl]),
+	}
 }
 
 // @Summary Get users
@@ -112,19 +174,26 @@ func (h *handlerV1) CreateUser(c *gin.Context) {
 // @Param limit query int true "Limit"
 // @Param page query int true "Page"
 // @Param search query string false "Search"
-// @Success 200 {object} models.User
+// @Success 200 {object} models.GetAllUsersResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users [get]
 func (h *handlerV1) GetUserAll(ctx *gin.Context) {
 	queryParams, err := validateGetUsersQuery(ctx)
 	if err != nil {
+		if abortValidation(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
 		return
 	}
 
-	resp, err := h.storage.User().GetAll(queryParams)
+	// as in GetUser, only admins see hidden profiles
+	queryParams.HideReported = !isAdmin(getAuthUser(ctx))
+
+	resp, err := h.store(ctx).User().GetAll(queryParams)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
@@ -132,32 +201,38 @@ func (h *handlerV1) GetUserAll(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, resp)
-}
+	urls := make([]string, 0, len(resp.Users))
+	for _, u := range resp.Users {
+		urls = append(urls, u.ProfileImageUrl)
+	}
+	renditions, err := h.store(ctx).Media().GetRenditionsByUrls(urls)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"message": err.Error(),
+		})
+		return
+	}
 
-func validateGetUsersQuery(ctx *gin.Context) (repo.GetUserQuery, error) {
-	var (
-		limit int = 10
-		page  int = 1
-		err   error
-	)
-	if ctx.Query("limit") != "" {
-		limit, err = strconv.Atoi(ctx.Query("limit"))
-		if err != nil {
-			return repo.GetUserQuery{}, err
-		}
+	result := models.GetAllUsersResponse{
+		Users: make([]*models.User, 0, len(resp.Users)),
+		Count: resp.Count,
+	}
+	for _, u := range resp.Users {
+		result.Users = append(result.Users, parseUserModel(u, renditions))
 	}
 
-	if ctx.Query("page") != "" {
-		page, err = strconv.Atoi(ctx.Query("page"))
-		if err != nil {
-			return repo.GetUserQuery{}, err
-		}
+	ctx.JSON(http.StatusOK, result)
+}
+
+func validateGetUsersQuery(ctx *gin.Context) (repo.GetUserQuery, error) {
+	pagination, err := bindPagination(ctx)
+	if err != nil {
+		return repo.GetUserQuery{}, err
 	}
 
 	return repo.GetUserQuery{
-		Limit:  limit,
-		Page:   page,
+		Limit:  pagination.Limit,
+		Page:   pagination.Page,
 		Search: ctx.Query("search"),
 	}, nil
 }
@@ -173,14 +248,22 @@ func validateGetUsersQuery(ctx *gin.Context) (repo.GetUserQuery, error) {
 // @Produce json
 // @Param id path int true "ID"
 // @Param user body models.CreateUser true "User"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
 // @Success 200 {object} models.User
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/{id} [put]
 func (h *handlerV1) UpdateUser(ctx *gin.Context) {
-	var b repo.User
+	var req models.CreateUser
 
-	err := ctx.ShouldBindJSON(&b)
+	err := ctx.ShouldBindJSON(&req)
 	if err != nil {
+		if abortBind(ctx, err) {
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": err.Error(),
 		})
@@ -195,8 +278,44 @@ func (h *handlerV1) UpdateUser(ctx *gin.Context) {
 		return
 	}
 
-	b.Id = id
-	user, err := h.storage.User().Update(&b)
+	if err := h.checkMediaUrl(ctx, req.ProfileImageUrl); err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	b := repo.User{
+		Id:              id,
+		FirstName:       req.FirstName,
+		LastName:        req.LastName,
+		PhoneNumber:     req.PhoneNumber,
+		Email:           req.Email,
+		Gender:          req.Gender,
+		UserName:        req.Username,
+		Password:        req.Password,
+		ProfileImageUrl: req.ProfileImageUrl,
+	}
+	var user *repo.User
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.User().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		b.Version = before.Version
+		b.Type = before.Type
+		user, err = strg.User().Update(&b)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypeUser, id, before, user)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to create user",
@@ -204,16 +323,124 @@ func (h *handlerV1) UpdateUser(ctx *gin.Context) {
 		return
 	}
 
+	ctx.Header("ETag", etag(user.Version))
 	ctx.JSON(http.StatusOK, user)
 }
 
 
+// @Summary Partially update a user
+// @Description Applies a JSON merge patch (RFC 7396): only the members sent are changed, last_name, phone_number and profile_image_url can be cleared with null
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param user body models.PatchUser true "merge patch"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Success 200 {object} models.User
+// @Header 200 {string} ETag "version of the entity"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 415 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /users/{id} [patch]
+func (h *handlerV1) PatchUser(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var req models.PatchUser
+	nulls, err := bindMergePatch(ctx, &req)
+	if err == nil {
+		err = resetNulls(nulls, map[string]**string{
+			"last_name":         &req.LastName,
+			"phone_number":      &req.PhoneNumber,
+			"profile_image_url": &req.ProfileImageUrl,
+		})
+	}
+	if err != nil {
+		abortBadPatch(ctx, err)
+		return
+	}
+
+	if req.ProfileImageUrl != nil {
+		if err := h.checkMediaUrl(ctx, *req.ProfileImageUrl); err != nil {
+			ctx.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: err.Error(),
+			})
+			return
+		}
+	}
+
+	patch := repo.UserPatch{
+		FirstName:       req.FirstName,
+		LastName:        req.LastName,
+		PhoneNumber:     req.PhoneNumber,
+		Email:           req.Email,
+		Gender:          req.Gender,
+		UserName:        req.Username,
+		Password:        req.Password,
+		ProfileImageUrl: req.ProfileImageUrl,
+	}
+
+	var user *repo.User
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.User().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		patch.Version = before.Version
+		user, err = strg.User().Patch(id, &patch)
+		if err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionUpdate, repo.TrashTypeUser, id, before, user)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		ctx.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	renditions, err := h.store(ctx).Media().GetRenditionsByUrls([]string{user.ProfileImageUrl})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	ctx.Header("ETag", etag(user.Version))
+	ctx.JSON(http.StatusOK, parseUserModel(user, renditions))
+}
+
 // @Summary Delete a User
-// @Description Delete a user
+// @Description The user is moved to the trash and can be restored by an admin until it is purged
 // @Tags users
 // @Accept json
 // @Produce json
 // @Param id path int true "ID"
+// @Param If-Match header string false "ETag of the version being changed, 412 if it is outdated"
+// @Failure 412 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Router /users/{id} [delete]
 func (h *handlerV1) DeleteUser(ctx *gin.Context) {
@@ -225,7 +452,22 @@ func (h *handlerV1) DeleteUser(ctx *gin.Context) {
 		return
 	}
 
-	err = h.storage.User().Delete(id)
+	err = h.store(ctx).WithTx(func(strg storage.StorageI) error {
+		before, err := strg.User().Get(id)
+		if err != nil {
+			return err
+		}
+		if err := checkIfMatch(ctx, before.Version); err != nil {
+			return err
+		}
+		if err := strg.User().Delete(id, before.Version); err != nil {
+			return err
+		}
+		return audit(ctx, strg, repo.AuditActionDelete, repo.TrashTypeUser, id, before, nil)
+	})
+	if abortPreconditionFailed(ctx, err) {
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"message": "failed to Delete method",
@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// isBanned reports whether userId is currently banned. Unknown users are
+// not.
+func (h *handlerV1) isBanned(c *gin.Context, userId int) (bool, error) {
+	user, err := h.store(c).User().Get(userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.CurrentStatus() == repo.UserStatusBanned, nil
+}
+
+// @Router /admin/users/{id}/status [post]
+// @Summary Suspend, ban, deactivate or reactivate a user
+// @Description Suspended users can only read, banned and deactivated users are locked out and the posts and comments of banned users are hidden. Every change is kept in the status history.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "ID"
+// @Param status body models.SetUserStatus true "status"
+// @Success 200 {object} models.User
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 413 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) SetUserStatus(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	var req models.SetUserStatus
+	if err := c.ShouldBindJSON(&req); err != nil {
+		if abortBind(c, err) {
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	switch req.Status {
+	case repo.UserStatusSuspended, repo.UserStatusBanned:
+		if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "expires_at must be in the future",
+			})
+			return
+		}
+	case repo.UserStatusActive, repo.UserStatusDeactivated:
+		req.ExpiresAt = nil
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "status must be active, suspended, banned or deactivated",
+		})
+		return
+	}
+
+	actor := getAuthUser(c)
+	if actor.Id == id {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: "admins can not change their own status",
+		})
+		return
+	}
+
+	err = h.store(c).WithTx(func(strg storage.StorageI) error {
+		return setUserStatus(c, strg, &repo.UserStatusChange{
+			UserId:    id,
+			Status:    req.Status,
+			Reason:    req.Reason,
+			ExpiresAt: req.ExpiresAt,
+			ChangedBy: actor.Id,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error: "not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	user, err := h.store(c).User().Get(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, parseUserModel(user, nil))
+}
+
+// @Router /admin/users/{id}/status/history [get]
+// @Summary Status history of a user
+// @Description Newest first
+// @Tags admin
+// @Produce json
+// @Param id path int true "ID"
+// @Success 200 {array} models.UserStatusChange
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+func (h *handlerV1) GetUserStatusHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	history, err := h.store(c).User().GetStatusHistory(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	resp := make([]*models.UserStatusChange, 0, len(history))
+	for _, change := range history {
+		resp = append(resp, &models.UserStatusChange{
+			Id:        change.Id,
+			Status:    change.Status,
+			Reason:    change.Reason,
+			ExpiresAt: change.ExpiresAt,
+			ChangedBy: change.ChangedBy,
+			ReportId:  change.ReportId,
+			CreatedAt: change.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// setUserStatus applies change and records it in the audit log.
+func setUserStatus(c *gin.Context, strg storage.StorageI, change *repo.UserStatusChange) error {
+	before, err := strg.User().Get(change.UserId)
+	if err != nil {
+		return err
+	}
+	if err := strg.User().SetStatus(change); err != nil {
+		return err
+	}
+	after, err := strg.User().Get(change.UserId)
+	if err != nil {
+		return err
+	}
+	return audit(c, strg, repo.AuditActionSetStatus, repo.TrashTypeUser, change.UserId, before, after)
+}
@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/go-playground/validator/v10/non-standard/validators"
+	"github.com/samandar2605/post/api/models"
+)
+
+// phoneRegex accepts phone numbers in E.164 format, the leading + optional.
+var phoneRegex = regexp.MustCompile(`^\+?[1-9][0-9]{6,14}$`)
+
+// The request models declare their rules in binding tags, which gin checks
+// when a body or query is bound. Errors name fields after their json or
+// form tag so that they match what the client sent.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name := strings.Split(f.Tag.Get(tag), ",")[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return f.Name
+	})
+	_ = v.RegisterValidation("notblank", validators.NotBlank)
+	_ = v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+		return phoneRegex.MatchString(fl.Field().String())
+	})
+}
+
+// validate checks the binding tags of a request model that was not bound
+// by gin.
+func validate(obj interface{}) error {
+	return binding.Validator.ValidateStruct(obj)
+}
+
+// abortValidation answers 422 listing every broken rule if err came from
+// validating a request model, and reports whether it did.
+func abortValidation(c *gin.Context, err error) bool {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return false
+	}
+
+	resp := models.ValidationErrorResponse{
+		Error:  "validation failed",
+		Fields: make([]*models.FieldError, 0, len(errs)),
+	}
+	for _, e := range errs {
+		field := e.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+		resp.Fields = append(resp.Fields, &models.FieldError{
+			Field:   field,
+			Rule:    e.Tag(),
+			Message: validationMessage(e),
+		})
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, resp)
+	return true
+}
+
+func validationMessage(e validator.FieldError) string {
+	switch e.Tag() {
+	case "required":
+		return "is required"
+	case "notblank":
+		return "can not be blank"
+	case "email":
+		return "must be a valid email address"
+	case "phone":
+		return "must be a phone number in international format, like +998901234567"
+	case "url":
+		return "must be a valid url"
+	case "number":
+		return "must be a whole number"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(e.Param(), " ", ", ")
+	case "min", "max":
+		bound := "at least"
+		if e.Tag() == "max" {
+			bound = "at most"
+		}
+		switch e.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters long", bound, e.Param())
+		case reflect.Slice, reflect.Map, reflect.Array:
+			return fmt.Sprintf("must have %s %s items", bound, e.Param())
+		}
+		return fmt.Sprintf("must be %s %s", bound, e.Param())
+	}
+	return fmt.Sprintf("does not satisfy %s", e.Tag())
+}
+
+// mergeValidation joins the validation errors of a request bound in parts,
+// so that abortValidation lists the broken rules of every part. Other
+// errors are returned as they are.
+func mergeValidation(errs ...error) error {
+	var all validator.ValidationErrors
+	for _, err := range errs {
+		var v validator.ValidationErrors
+		switch {
+		case err == nil:
+		case errors.As(err, &v):
+			all = append(all, v...)
+		default:
+			return err
+		}
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	return all
+}
+
+// queryId converts an id query parameter checked with the number rule, 0
+// when it was not sent.
+func queryId(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+// bindPagination reads page and limit of a list request, by default the
+// first 10 items.
+func bindPagination(c *gin.Context) (models.Pagination, error) {
+	var p models.Pagination
+	err := c.ShouldBindQuery(&p)
+	return p, err
+}
@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samandar2605/post/api/models"
+	"github.com/stretchr/testify/require"
+)
+
+type testModel struct {
+	Name   string   `json:"name" binding:"required,notblank,max=5"`
+	Phone  string   `json:"phone" binding:"omitempty,phone"`
+	Gender string   `json:"gender" binding:"omitempty,oneof=male female"`
+	Tags   []string `form:"tags" binding:"max=2,dive,oneof=a b"`
+}
+
+func TestAbortValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		model      testModel
+		err        error
+		wantFields []*models.FieldError
+	}{
+		{
+			name:  "valid",
+			model: testModel{Name: "ann", Phone: "+998901234567", Gender: "female"},
+		},
+		{
+			name: "other error",
+			err:  errors.New("connection refused"),
+		},
+		{
+			name:  "every broken rule",
+			model: testModel{Phone: "12", Gender: "x", Tags: []string{"a", "b", "c"}},
+			wantFields: []*models.FieldError{
+				{Field: "name", Rule: "required", Message: "is required"},
+				{Field: "phone", Rule: "phone", Message: "must be a phone number in international format, like +998901234567"},
+				{Field: "gender", Rule: "oneof", Message: "must be one of: male, female"},
+				{Field: "tags", Rule: "max", Message: "must have at most 2 items"},
+			},
+		},
+		{
+			name:  "blank",
+			model: testModel{Name: "   "},
+			wantFields: []*models.FieldError{
+				{Field: "name", Rule: "notblank", Message: "can not be blank"},
+			},
+		},
+		{
+			name:  "items of a list",
+			model: testModel{Name: "ann", Tags: []string{"c"}},
+			wantFields: []*models.FieldError{
+				{Field: "tags[0]", Rule: "oneof", Message: "must be one of: a, b"},
+			},
+		},
+		{
+			name:  "string length",
+			model: testModel{Name: "annabel"},
+			wantFields: []*models.FieldError{
+				{Field: "name", Rule: "max", Message: "must be at most 5 characters long"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err
+			if err == nil {
+				err = validate(&tt.model)
+			}
+			if err == nil {
+				require.Nil(t, tt.wantFields)
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			aborted := abortValidation(c, err)
+			if tt.wantFields == nil {
+				require.False(t, aborted)
+				require.False(t, c.Writer.Written())
+				return
+			}
+
+			require.True(t, aborted)
+			require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			var resp models.ValidationErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			require.Equal(t, "validation failed", resp.Error)
+			require.Equal(t, tt.wantFields, resp.Fields)
+		})
+	}
+}
+
+func TestValidateGetPostQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantFields []string
+	}{
+		{name: "defaults"},
+		{name: "filters", query: "category_id=3&user_id=7&status=spam&search=go"},
+		{
+			name:       "every broken rule",
+			query:      "page=0&category_id=x&user_id=-1&status=deleted&fields=secret",
+			wantFields: []string{"page", "category_id", "user_id", "status", "fields[0]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			c.Request = httptest.NewRequest(http.MethodGet, "/posts?"+tt.query, nil)
+
+			_, err := validateGetPostQuery(c)
+			_, viewErr := bindPostView(c)
+			err = mergeValidation(err, viewErr)
+			if tt.wantFields == nil {
+				require.NoError(t, err)
+				return
+			}
+
+			require.True(t, abortValidation(c, err))
+			var resp models.ValidationErrorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+			fields := make([]string, 0, len(resp.Fields))
+			for _, f := range resp.Fields {
+				fields = append(fields, f.Field)
+			}
+			require.Equal(t, tt.wantFields, fields)
+		})
+	}
+}
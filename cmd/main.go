@@ -1,48 +1,380 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/samandar2605/post/api"
 	"github.com/samandar2605/post/config"
+	"github.com/samandar2605/post/migrations"
+	"github.com/samandar2605/post/pkg/blob"
+	"github.com/samandar2605/post/pkg/cache"
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/samandar2605/post/pkg/health"
+	"github.com/samandar2605/post/pkg/imageproc"
+	"github.com/samandar2605/post/pkg/logger"
+	"github.com/samandar2605/post/pkg/metrics"
+	"github.com/samandar2605/post/pkg/ratelimit"
+	"github.com/samandar2605/post/pkg/tracing"
 	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/postgres"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/samandar2605/post/worker"
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
-	cfg := config.Load(".")
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(configCommand(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "render" {
+		os.Exit(renderCommand(args[1:]))
+	}
+
+	cfg, err := config.Parse(".", args)
+	if config.IsHelp(err) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
 
-	psqlUrl := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Postgres.Host,
-		cfg.Postgres.Port,
-		cfg.Postgres.User,
-		cfg.Postgres.Password,
-		cfg.Postgres.Database,
-	)
+	log, err := logger.New(os.Stdout, cfg.Log.Level)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	slog.SetDefault(log)
+	log.Info("config loaded", "config", cfg)
+	if cfg.Auth.GatewaySecret == "" {
+		log.Warn("auth.gateway_secret is not set, X-User-Id is ignored and every request is anonymous")
+	}
+
+	shutdownTracing, err := tracing.New(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		Endpoint:    cfg.Tracing.OtlpEndpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("failed to init tracing", err)
+	}
+
+	psqlConn, err := sqlx.Connect("postgres", cfg.Postgres.DSN())
+	if err != nil {
+		fatal("failed to connect database", err)
+	}
+	setPool(psqlConn, cfg.Postgres)
+	pools := map[string]*sql.DB{"primary": psqlConn.DB}
+
+	// Replicas are connected to lazily, one that is down when the api
+	// starts is skipped until it is back.
+	var replicaConns []*sqlx.DB
+	for i, host := range cfg.Postgres.ReplicaHosts {
+		conn, err := sqlx.Open("postgres", cfg.Postgres.Replica(host).DSN())
+		if err != nil {
+			fatal("failed to open replica "+host, err)
+		}
+		setPool(conn, cfg.Postgres)
+		replicaConns = append(replicaConns, conn)
+		pools[fmt.Sprintf("replica%d", i+1)] = conn.DB
+	}
+
+	m := metrics.New()
+	for name, pool := range pools {
+		if err := m.RegisterDB(pool, name); err != nil {
+			fatal("failed to register database metrics", err)
+		}
+	}
+
+	replicas := postgres.NewReplicas(psqlConn, replicaConns, postgres.ReplicaOptions{
+		MaxLag:        cfg.Postgres.ReplicaMaxLag,
+		Stickiness:    cfg.Postgres.ReplicaStickiness,
+		CheckInterval: cfg.Postgres.ReplicaCheckInterval,
+		CheckTimeout:  cfg.Health.CheckTimeout,
+	})
+	strg := storage.WithObserver(storage.NewReplicatedStoragePg(replicas), m)
+	if cfg.Cache.Enabled {
+		strg = storage.WithCache(strg, cache.NewLRU(cfg.Cache.Size), storage.CacheOptions{
+			TTL:     cfg.Cache.TTL,
+			ListTTL: cfg.Cache.ListTTL,
+		})
+	}
 
-	psqlConn, err := sqlx.Connect("postgres", psqlUrl)
+	blobStore, err := newBlobStore(cfg.Media)
 	if err != nil {
-		log.Fatalf("failed to connect database: %v", err)
+		fatal("failed to init media store", err)
 	}
-	fmt.Println(cfg.HttpPort)
-	fmt.Println(cfg.Postgres.Host)
-	fmt.Println(cfg.Postgres.Password)
-	fmt.Println(cfg.Postgres.User)
-	fmt.Println(cfg.Postgres.Port)
-	fmt.Println(cfg.Postgres.Database)
 
-	strg := storage.NewStoragePg(psqlConn)
+	workers := worker.NewGroup(context.Background())
+
+	cleaner := worker.NewMediaCleaner(strg, blobStore, cfg.Media.OrphanTTL, cfg.Media.CleanupInterval)
+	workers.Go("media_cleaner", cleaner.Run)
+
+	purger := worker.NewTrashPurger(strg, cfg.Trash.Retention, cfg.Trash.PurgeInterval)
+	workers.Go("trash_purger", purger.Run)
+
+	sizes, err := imageproc.ParseSizes(cfg.Media.Images.Sizes)
+	if err != nil {
+		fatal("invalid image sizes", err)
+	}
+	if len(sizes) == 0 {
+		sizes = imageproc.DefaultSizes
+	}
+
+	images := worker.NewImageProcessor(worker.ImageProcessorOptions{
+		Storage: strg,
+		Blob:    blobStore,
+		BaseUrl: cfg.Media.BaseUrl,
+		Sizes:   sizes,
+		Formats: cfg.Media.Images.Formats,
+		Quality: cfg.Media.Images.Quality,
+		Workers: cfg.Media.Images.Workers,
+	})
+	workers.Go("image_processor", func(ctx context.Context) {
+		images.Start(ctx)
+		images.Wait()
+	})
+
+	sitemap := worker.NewSitemap(worker.SitemapOptions{
+		Storage:         strg,
+		SiteUrl:         cfg.Site.Url,
+		ChunkSize:       cfg.Sitemap.ChunkSize,
+		RefreshInterval: cfg.Sitemap.RefreshInterval,
+		RebuildInterval: cfg.Sitemap.RebuildInterval,
+	})
+	workers.Go("sitemap", sitemap.Run)
+
+	filter, err := newContentFilter(cfg.ContentFilter, strg)
+	if err != nil {
+		fatal("failed to init content filter", err)
+	}
+
+	var rateLimits ratelimit.Store
+	if cfg.RateLimit.Enabled {
+		rateLimits = ratelimit.NewMemory()
+	}
 
 	apiServer := api.New(&api.RouterOptions{
 		Cfg:     &cfg,
 		Storage: strg,
+		Blob:    blobStore,
+		Images:  images,
+		Sitemap: sitemap,
+		Filter:  filter,
+		Logger:  log,
+		Metrics: m,
+		Health:  newHealthChecker(cfg.Health, psqlConn, workers),
+		Pools:   pools,
+
+		RateLimits: rateLimits,
 	})
-	err = apiServer.Run(cfg.HttpPort)
+	server := newHttpServer(cfg.Server, cfg.HttpPort, apiServer)
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.Server.TLSCertFile != "" && cfg.Server.TLSKeyFile != "" {
+			serveErr <- server.ListenAndServeTLS(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+			return
+		}
+		serveErr <- server.ListenAndServe()
+	}()
+	log.Info("server started", "port", cfg.HttpPort, "tls", cfg.Server.TLSCertFile != "")
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	select {
+	case err := <-serveErr:
+		fatal("failed to run server", err)
+	case <-signals.Done():
+	}
+	log.Info("shutting down", "timeout", cfg.Server.ShutdownTimeout.String())
+
+	// In order: stop taking requests and drain the ones in flight, then the
+	// workers, and close the database once nothing uses it anymore.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error("failed to drain connections", "error", err)
+	}
+	if err := workers.Stop(ctx); err != nil {
+		log.Error("failed to stop workers", "error", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error("failed to flush traces", "error", err)
+	}
+	for name, pool := range pools {
+		if err := pool.Close(); err != nil {
+			log.Error("failed to close database", "pool", name, "error", err)
+		}
+	}
+
+	log.Info("server stopped")
+}
+
+// configCommand runs "config print", which writes the effective
+// configuration as YAML with the secrets masked.
+func configCommand(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: post config print [flags]")
+		return 2
+	}
+
+	cfg, err := config.Parse(".", args[1:])
+	if config.IsHelp(err) {
+		return 0
+	}
 	if err != nil {
-		log.Fatalf("failed to run server: %v", err)
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	}
+
+	out, err := yaml.Marshal(logger.Redact(cfg))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// renderCommand runs "render descriptions", which renders the Markdown of
+// the posts written before descriptions were rendered on write. It is safe
+// to run while the api serves.
+func renderCommand(args []string) int {
+	if len(args) == 0 || args[0] != "descriptions" {
+		fmt.Fprintln(os.Stderr, "usage: post render descriptions [flags]")
+		return 2
+	}
+
+	cfg, err := config.Parse(".", args[1:])
+	if config.IsHelp(err) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 2
+	}
+
+	psqlConn, err := sqlx.Connect("postgres", cfg.Postgres.DSN())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer psqlConn.Close()
+
+	n, err := worker.RenderDescriptions(context.Background(), storage.NewStoragePg(psqlConn), 100)
+	fmt.Printf("rendered %d posts\n", n)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func newHttpServer(cfg config.ServerConfig, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// setPool applies the pool settings of cfg to db.
+func setPool(db *sqlx.DB, cfg config.PostgresConfig) {
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+// newHealthChecker checks that the database answers, its schema is at the
+// latest migration and no background worker stopped.
+func newHealthChecker(cfg config.HealthConfig, db *sqlx.DB, workers *worker.Group) *health.Checker {
+	checker := health.New(cfg.CheckTimeout)
+	checker.Register("database", db.PingContext)
+	checker.Register("migrations", func(ctx context.Context) error {
+		version, dirty, err := postgres.SchemaVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("migration %d failed half way", version)
+		}
+		if want := migrations.Latest(); version != want {
+			return fmt.Errorf("schema is at version %d, expected %d", version, want)
+		}
+		return nil
+	})
+	checker.Register("workers", workers.Check)
+	return checker
+}
+
+func newBlobStore(cfg config.MediaConfig) (blob.Store, error) {
+	switch cfg.Driver {
+	case "local":
+		return blob.NewLocal(cfg.LocalDir)
+	case "s3":
+		return blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		}, nil), nil
+	}
+	return nil, fmt.Errorf("unknown media driver %q", cfg.Driver)
+}
+
+// newContentFilter prefers the rules last saved through the admin api over
+// the configured ones.
+func newContentFilter(cfg config.ContentFilterConfig, strg storage.StorageI) (*contentfilter.Engine, error) {
+	rules := contentfilter.Rules{
+		Keywords:        cfg.Keywords,
+		KeywordScore:    cfg.KeywordScore,
+		MaxLinks:        cfg.MaxLinks,
+		LinkScore:       cfg.LinkScore,
+		DuplicateWindow: contentfilter.Duration(cfg.DuplicateWindow),
+		DuplicateScore:  cfg.DuplicateScore,
+		VelocityWindow:  contentfilter.Duration(cfg.VelocityWindow),
+		VelocityLimit:   cfg.VelocityLimit,
+		VelocityScore:   cfg.VelocityScore,
+		ModerateScore:   cfg.ModerateScore,
+		RejectScore:     cfg.RejectScore,
+	}
+
+	stored, err := strg.Setting().Get(repo.ContentFilterSettingKey)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(stored, &rules); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, err
 	}
 
-	log.Print("Server stopped")
+	return contentfilter.New(rules)
 }
@@ -3,13 +3,76 @@
 package config
 
 import (
-	"github.com/spf13/viper"
-	"github.com/subosito/gotenv"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/samandar2605/post/pkg/logger"
 )
 
 type Config struct {
-	HttpPort string
-	Postgres PostgresConfig
+	HttpPort      string
+	Server        ServerConfig
+	Log           LogConfig
+	Tracing       TracingConfig
+	Health        HealthConfig
+	Postgres      PostgresConfig
+	Cors          CorsConfig
+	RateLimit     RateLimitConfig
+	Cache         CacheConfig
+	Media         MediaConfig
+	Site          SiteConfig
+	Feed          FeedConfig
+	Sitemap       SitemapConfig
+	Trash         TrashConfig
+	Auth          AuthConfig
+	Moderation    ModerationConfig
+	ContentFilter ContentFilterConfig
+}
+
+type ServerConfig struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ShutdownTimeout is how long in-flight requests may take to finish
+	// once SIGINT or SIGTERM was received.
+	ShutdownTimeout time.Duration
+	// TLSCertFile and TLSKeyFile enable https when both are set.
+	TLSCertFile string
+	TLSKeyFile  string
+	// MaxBodyBytes is the largest json body the api accepts, larger ones
+	// are answered with 413 before they are bound.
+	MaxBodyBytes int64
+	// HSTSMaxAge is sent in Strict-Transport-Security on https requests,
+	// zero leaves the header out.
+	HSTSMaxAge time.Duration
+	// TrustedProxies are the addresses or CIDR ranges of the proxies in
+	// front of the api. Only their X-Forwarded-For tells the client ip,
+	// without any it is the address of the connection.
+	TrustedProxies []string
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error.
+	Level string
+}
+
+type TracingConfig struct {
+	// Exporter is "otlp", "stdout" or "none".
+	Exporter    string
+	ServiceName string
+	// OtlpEndpoint is the url of the OTLP/HTTP collector.
+	OtlpEndpoint string
+	SampleRatio  float64
+}
+
+type HealthConfig struct {
+	// CheckTimeout bounds every readiness check, e.g. the database ping.
+	CheckTimeout time.Duration
 }
 
 type PostgresConfig struct {
@@ -18,24 +81,203 @@ type PostgresConfig struct {
 	User     string
 	Password string
 	Database string
-}
+	// SSLMode is disable, require, verify-ca or verify-full.
+	SSLMode     string
+	SSLRootCert string
 
-func Load(path string) Config {
-	gotenv.Load(path + "/.env")
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// StatementTimeout makes the server cancel statements running longer,
+	// 0 disables it.
+	StatementTimeout time.Duration
 
-	conf := viper.New()
-	conf.AutomaticEnv()
+	// ReplicaHosts are read replicas as host or host:port, they share the
+	// credentials and pool settings of the primary.
+	ReplicaHosts []string
+	// ReplicaMaxLag is how far a replica may be behind and still serve
+	// reads, ReplicaStickiness how long a client reads from the primary
+	// after it wrote.
+	ReplicaMaxLag        time.Duration
+	ReplicaStickiness    time.Duration
+	ReplicaCheckInterval time.Duration
+}
 
-	cfg := Config{
-		HttpPort: conf.GetString("HTTP_PORT"),
-		Postgres: PostgresConfig{
-			Host:     conf.GetString("POSTGRES_HOST"),
-			Port:     conf.GetString("POSTGRES_PORT"),
-			User:     conf.GetString("POSTGRES_USER"),
-			Password: conf.GetString("POSTGRES_PASSWORD"),
-			Database: conf.GetString("POSTGRES_DATABASE"),
-		},
+// Replica returns the settings to connect to the replica on host.
+func (c PostgresConfig) Replica(host string) PostgresConfig {
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		c.Host, c.Port = h, port
+	} else {
+		c.Host = host
 	}
+	return c
+}
+
+// DSN returns the connection string for lib/pq.
+func (c PostgresConfig) DSN() string {
+	params := []string{
+		"host=" + dsnValue(c.Host),
+		"port=" + dsnValue(c.Port),
+		"user=" + dsnValue(c.User),
+		"password=" + dsnValue(c.Password),
+		"dbname=" + dsnValue(c.Database),
+		"sslmode=" + dsnValue(c.SSLMode),
+	}
+	if c.SSLRootCert != "" {
+		params = append(params, "sslrootcert="+dsnValue(c.SSLRootCert))
+	}
+	if c.StatementTimeout > 0 {
+		params = append(params, fmt.Sprintf("statement_timeout=%d", c.StatementTimeout.Milliseconds()))
+	}
+	return strings.Join(params, " ")
+}
+
+// dsnValue quotes a connection string value, so that passwords may hold
+// spaces and quotes.
+func dsnValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// CorsConfig lists what browsers on other origins may do with the api.
+type CorsConfig struct {
+	// AllowedOrigins are origins like https://blog.example.com, or "*".
+	// Cross origin requests are refused when it is empty.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+type RateLimitConfig struct {
+	Enabled bool
+	// KeyBy is "user" to limit authenticated users by id and everyone
+	// else by ip, or "ip" to limit everyone by ip.
+	KeyBy string
+	// ReadRate and WriteRate are the requests per second sustained by a
+	// client, ReadBurst and WriteBurst how many more it may send at once.
+	// Writes are POST, PUT, PATCH and DELETE requests.
+	ReadRate   float64
+	ReadBurst  int
+	WriteRate  float64
+	WriteBurst int
+}
+
+type CacheConfig struct {
+	// Enabled caches posts and categories in the process.
+	Enabled bool
+	// Size is how many entries the cache holds at most.
+	Size int
+	// TTL is how long a single post or category is cached, ListTTL how
+	// long a page of them.
+	TTL     time.Duration
+	ListTTL time.Duration
+	// HttpMaxAge is the max-age clients may reuse public responses for
+	// without asking again, zero makes them revalidate with the ETag.
+	HttpMaxAge time.Duration
+}
+
+// SiteConfig describes the public blog the api serves, it is used for
+// absolute links in feeds and sitemaps.
+type SiteConfig struct {
+	Url         string
+	Title       string
+	Description string
+}
+
+type FeedConfig struct {
+	Items       int
+	MaxItems    int
+	FullContent bool
+}
+
+type AuthConfig struct {
+	// GatewaySecret must be sent by the auth gateway in the
+	// X-Gateway-Secret header for its X-User-Id header to be trusted.
+	// Without it every request is anonymous.
+	GatewaySecret string
+	// GatewaySecretFile is read into GatewaySecret, e.g. for a mounted
+	// secret.
+	GatewaySecretFile string
+}
+
+// ModerationConfig holds the rules for publishing comments without review.
+type ModerationConfig struct {
+	AutoApproveAdmins bool
+	TrustedUserIds    []int
+	// AutoApproveAfter approves comments of users that already have this
+	// many approved comments, 0 disables the rule.
+	AutoApproveAfter int
+	// ReportHideThreshold hides content reported by this many users until
+	// an admin resolves the report, 0 disables it.
+	ReportHideThreshold int
+}
+
+// ContentFilterConfig holds the initial spam filter rules. Once rules were
+// changed through the admin api the stored ones take precedence.
+type ContentFilterConfig struct {
+	Keywords        []string
+	KeywordScore    float64
+	MaxLinks        int
+	LinkScore       float64
+	DuplicateWindow time.Duration
+	DuplicateScore  float64
+	VelocityWindow  time.Duration
+	VelocityLimit   int
+	VelocityScore   float64
+	// ModerateScore and RejectScore are the total scores from which content
+	// is held for moderation or rejected.
+	ModerateScore float64
+	RejectScore   float64
+}
+
+type SitemapConfig struct {
+	ChunkSize       int
+	RefreshInterval time.Duration
+	RebuildInterval time.Duration
+}
+
+type TrashConfig struct {
+	// Retention is how long deleted items can be restored before the purge
+	// worker removes them for good.
+	Retention     time.Duration
+	PurgeInterval time.Duration
+}
+
+type MediaConfig struct {
+	// Driver selects the blob store: "local" or "s3".
+	Driver        string
+	LocalDir      string
+	BaseUrl       string
+	MaxUploadSize int64
+	// OrphanTTL is how long an upload may stay unreferenced by any post or
+	// user before the cleanup worker deletes it.
+	OrphanTTL       time.Duration
+	CleanupInterval time.Duration
+	S3              S3Config
+	Images          ImageConfig
+}
+
+type ImageConfig struct {
+	// Sizes is a comma separated list of name:WIDTHxHEIGHT renditions.
+	Sizes string
+	// Formats lists output formats in order of preference, e.g. webp,jpeg.
+	// webp needs an encoder registered with imageproc.RegisterFormat.
+	Formats []string
+	Quality int
+	Workers int
+}
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
 
-	return cfg
+// LogValue masks the secrets when the configuration is logged.
+func (c Config) LogValue() slog.Value {
+	return slog.AnyValue(logger.Redact(c))
 }
@@ -0,0 +1,117 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/config"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestParseLayers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+postgres:
+  user: file
+  database: blog
+  max_open_conns: 40
+content_filter:
+  keywords: [spam, casino]
+`)
+	t.Setenv("POSTGRES_USER", "env")
+	t.Setenv("POSTGRES_MAX_OPEN_CONNS", "30")
+
+	cfg, err := config.Parse(t.TempDir(), []string{"--config", file, "--postgres-max-open-conns", "20"})
+	require.NoError(t, err)
+	require.Equal(t, "env", cfg.Postgres.User)
+	require.Equal(t, "blog", cfg.Postgres.Database)
+	require.Equal(t, 20, cfg.Postgres.MaxOpenConns)
+	require.Equal(t, 10, cfg.Postgres.MaxIdleConns)
+	require.Equal(t, []string{"spam", "casino"}, cfg.ContentFilter.Keywords)
+	require.Equal(t, []string{"jpeg"}, cfg.Media.Images.Formats)
+}
+
+func TestParseToml(t *testing.T) {
+	file := writeFile(t, "config.toml", `
+[postgres]
+user = "blog"
+database = "blog"
+ssl_mode = "verify-full"
+`)
+
+	cfg, err := config.Parse(t.TempDir(), []string{"--config", file})
+	require.NoError(t, err)
+	require.Equal(t, "verify-full", cfg.Postgres.SSLMode)
+}
+
+func TestValidate(t *testing.T) {
+	_, err := config.Parse(t.TempDir(), []string{
+		"--postgres-user", "blog",
+		"--postgres-ssl-mode", "sometimes",
+		"--postgres-max-idle-conns", "100",
+		"--cors-allowed-origins", "https://blog.example.com,blog.example.com",
+		"--rate-limit-key-by", "country",
+		"--http-max-body-bytes", "0",
+		"--http-trusted-proxies", "10.0.0.0/8,proxy.local",
+		"--cache-size", "0",
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "postgres.database: is required")
+	require.Contains(t, err.Error(), `postgres.ssl_mode: must be one of disable, require, verify-ca, verify-full, got "sometimes"`)
+	require.Contains(t, err.Error(), "postgres.max_idle_conns")
+	require.Contains(t, err.Error(), `"blog.example.com" is neither`)
+	require.NotContains(t, err.Error(), `"https://blog.example.com"`)
+	require.Contains(t, err.Error(), "rate_limit.key_by")
+	require.Contains(t, err.Error(), "http.max_body_bytes: must be positive, got 0")
+	require.Contains(t, err.Error(), `http.trusted_proxies: "proxy.local" is neither`)
+	require.NotContains(t, err.Error(), `"10.0.0.0/8"`)
+	require.Contains(t, err.Error(), "cache.size: must be positive, got 0")
+}
+
+func TestGatewaySecretFile(t *testing.T) {
+	file := writeFile(t, "secret", "0123456789abcdef\n")
+
+	cfg, err := config.Parse(t.TempDir(), []string{
+		"--postgres-user", "blog",
+		"--postgres-database", "blog",
+		"--auth-gateway-secret-file", file,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "0123456789abcdef", cfg.Auth.GatewaySecret)
+}
+
+func TestDSN(t *testing.T) {
+	cfg := config.PostgresConfig{
+		Host:             "db",
+		Port:             "5432",
+		User:             "blog",
+		Password:         `it's a \secret`,
+		Database:         "blog",
+		SSLMode:          "require",
+		StatementTimeout: 1500 * time.Millisecond,
+	}
+	require.Equal(t,
+		`host='db' port='5432' user='blog' password='it\'s a \\secret' dbname='blog' sslmode='require' statement_timeout=1500`,
+		cfg.DSN(),
+	)
+}
+
+func TestReplica(t *testing.T) {
+	cfg := config.PostgresConfig{Host: "db", Port: "5432", User: "blog"}
+
+	replica := cfg.Replica("db-replica:5433")
+	require.Equal(t, "db-replica", replica.Host)
+	require.Equal(t, "5433", replica.Port)
+	require.Equal(t, "blog", replica.User)
+
+	replica = cfg.Replica("db-replica")
+	require.Equal(t, "db-replica", replica.Host)
+	require.Equal(t, "5432", replica.Port)
+}
@@ -0,0 +1,358 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/subosito/gotenv"
+)
+
+// defaults lists every setting with the value used when neither the config
+// file, the environment nor a flag sets it. Keys are section.name, the
+// environment variable is the key in upper case with dots replaced by
+// underscores, e.g. POSTGRES_HOST, and the flag has dashes instead, e.g.
+// --postgres-host.
+var defaults = map[string]interface{}{
+	"http.port":                        ":8000",
+	"http.read_timeout":                "15s",
+	"http.read_header_timeout":         "5s",
+	"http.write_timeout":               "30s",
+	"http.idle_timeout":                "120s",
+	"http.max_header_bytes":            1 << 20,
+	"http.shutdown_timeout":            "20s",
+	"http.tls_cert_file":               "",
+	"http.tls_key_file":                "",
+	"http.max_body_bytes":              1 << 20,
+	"http.hsts_max_age":                "8760h",
+	"http.trusted_proxies":             "",
+	"log.level":                        "info",
+	"tracing.exporter":                 "none",
+	"tracing.service_name":             "post",
+	"tracing.otlp_endpoint":            "",
+	"tracing.sample_ratio":             1,
+	"health.check_timeout":             "2s",
+	"postgres.host":                    "localhost",
+	"postgres.port":                    "5432",
+	"postgres.user":                    "",
+	"postgres.password":                "",
+	"postgres.database":                "",
+	"postgres.ssl_mode":                "disable",
+	"postgres.ssl_root_cert":           "",
+	"postgres.max_open_conns":          25,
+	"postgres.max_idle_conns":          10,
+	"postgres.conn_max_lifetime":       "30m",
+	"postgres.conn_max_idle_time":      "5m",
+	"postgres.statement_timeout":       "30s",
+	"postgres.replica_hosts":           "",
+	"postgres.replica_max_lag":         "5s",
+	"postgres.replica_stickiness":      "5s",
+	"postgres.replica_check_interval":  "5s",
+	"cors.allowed_origins":             "",
+	"cors.allowed_methods":             "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+	"cors.allowed_headers":             "Content-Type,If-Match,If-None-Match,X-Request-ID",
+	"cors.allow_credentials":           false,
+	"cors.max_age":                     "10m",
+	"rate_limit.enabled":               true,
+	"rate_limit.key_by":                "user",
+	"rate_limit.read_rate":             20,
+	"rate_limit.read_burst":            40,
+	"rate_limit.write_rate":            2,
+	"rate_limit.write_burst":           10,
+	"cache.enabled":                    true,
+	"cache.size":                       10000,
+	"cache.ttl":                        "5m",
+	"cache.list_ttl":                   "1m",
+	"cache.http_max_age":               "0s",
+	"site.url":                         "http://localhost:8000",
+	"site.title":                       "Blog",
+	"site.description":                 "",
+	"feed.items":                       20,
+	"feed.max_items":                   100,
+	"feed.full_content":                true,
+	"auth.gateway_secret":              "",
+	"auth.gateway_secret_file":         "",
+	"moderation.auto_approve_admins":   true,
+	"moderation.trusted_user_ids":      "",
+	"moderation.auto_approve_after":    3,
+	"moderation.report_hide_threshold": 5,
+	"content_filter.keywords":          "",
+	"content_filter.keyword_score":     1,
+	"content_filter.max_links":         2,
+	"content_filter.link_score":        0.5,
+	"content_filter.duplicate_window":  "24h",
+	"content_filter.duplicate_score":   1,
+	"content_filter.velocity_window":   "1m",
+	"content_filter.velocity_limit":    5,
+	"content_filter.velocity_score":    1,
+	"content_filter.moderate_score":    1,
+	"content_filter.reject_score":      3,
+	"sitemap.chunk_size":               50000,
+	"sitemap.refresh_interval":         "10m",
+	"sitemap.rebuild_interval":         "24h",
+	"trash.retention":                  "720h",
+	"trash.purge_interval":             "1h",
+	"media.driver":                     "local",
+	"media.local_dir":                  "./uploads",
+	"media.base_url":                   "http://localhost:8000",
+	"media.max_upload_size":            10 << 20,
+	"media.orphan_ttl":                 "24h",
+	"media.cleanup_interval":           "1h",
+	"media.s3.endpoint":                "",
+	"media.s3.region":                  "",
+	"media.s3.bucket":                  "",
+	"media.s3.access_key":              "",
+	"media.s3.secret_key":              "",
+	"image.sizes":                      "thumbnail:150x150,medium:600x400,large:1200x800",
+	"image.formats":                    "jpeg",
+	"image.quality":                    85,
+	"image.workers":                    2,
+}
+
+// Load reads the configuration from the defaults, the .env file in path and
+// the environment, without validating it.
+func Load(path string) Config {
+	cfg, _ := read(path, nil)
+	return cfg
+}
+
+// Parse reads the configuration in layers, each overriding the one before:
+// the defaults, the YAML or TOML file given by --config or CONFIG_FILE, the
+// .env file in path and the environment, and the flags in args. The result
+// is validated.
+func Parse(path string, args []string) (Config, error) {
+	cfg, err := read(path, args)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func read(path string, args []string) (Config, error) {
+	gotenv.Load(path + "/.env")
+
+	conf := viper.New()
+	conf.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	conf.AutomaticEnv()
+
+	keys := make([]string, 0, len(defaults))
+	for key, value := range defaults {
+		conf.SetDefault(key, value)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	flags := pflag.NewFlagSet("post", pflag.ContinueOnError)
+	file := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML configuration file")
+	for _, key := range keys {
+		flags.String(flagName(key), "", "overrides "+key)
+		if err := conf.BindPFlag(key, flags.Lookup(flagName(key))); err != nil {
+			return Config{}, err
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if *file != "" {
+		conf.SetConfigFile(*file)
+		if err := conf.ReadInConfig(); err != nil {
+			return Config{}, fmt.Errorf("config file: %w", err)
+		}
+	}
+
+	cfg := Config{
+		HttpPort: conf.GetString("http.port"),
+		Server: ServerConfig{
+			ReadTimeout:       conf.GetDuration("http.read_timeout"),
+			ReadHeaderTimeout: conf.GetDuration("http.read_header_timeout"),
+			WriteTimeout:      conf.GetDuration("http.write_timeout"),
+			IdleTimeout:       conf.GetDuration("http.idle_timeout"),
+			MaxHeaderBytes:    conf.GetInt("http.max_header_bytes"),
+			ShutdownTimeout:   conf.GetDuration("http.shutdown_timeout"),
+			TLSCertFile:       conf.GetString("http.tls_cert_file"),
+			TLSKeyFile:        conf.GetString("http.tls_key_file"),
+			MaxBodyBytes:      conf.GetInt64("http.max_body_bytes"),
+			HSTSMaxAge:        conf.GetDuration("http.hsts_max_age"),
+			TrustedProxies:    getStringSlice(conf, "http.trusted_proxies"),
+		},
+		Log: LogConfig{
+			Level: conf.GetString("log.level"),
+		},
+		Tracing: TracingConfig{
+			Exporter:     conf.GetString("tracing.exporter"),
+			ServiceName:  conf.GetString("tracing.service_name"),
+			OtlpEndpoint: conf.GetString("tracing.otlp_endpoint"),
+			SampleRatio:  conf.GetFloat64("tracing.sample_ratio"),
+		},
+		Health: HealthConfig{
+			CheckTimeout: conf.GetDuration("health.check_timeout"),
+		},
+		Postgres: PostgresConfig{
+			Host:             conf.GetString("postgres.host"),
+			Port:             conf.GetString("postgres.port"),
+			User:             conf.GetString("postgres.user"),
+			Password:         conf.GetString("postgres.password"),
+			Database:         conf.GetString("postgres.database"),
+			SSLMode:          conf.GetString("postgres.ssl_mode"),
+			SSLRootCert:      conf.GetString("postgres.ssl_root_cert"),
+			MaxOpenConns:     conf.GetInt("postgres.max_open_conns"),
+			MaxIdleConns:     conf.GetInt("postgres.max_idle_conns"),
+			ConnMaxLifetime:  conf.GetDuration("postgres.conn_max_lifetime"),
+			ConnMaxIdleTime:  conf.GetDuration("postgres.conn_max_idle_time"),
+			StatementTimeout: conf.GetDuration("postgres.statement_timeout"),
+
+			ReplicaHosts:         getStringSlice(conf, "postgres.replica_hosts"),
+			ReplicaMaxLag:        conf.GetDuration("postgres.replica_max_lag"),
+			ReplicaStickiness:    conf.GetDuration("postgres.replica_stickiness"),
+			ReplicaCheckInterval: conf.GetDuration("postgres.replica_check_interval"),
+		},
+		Cors: CorsConfig{
+			AllowedOrigins:   getStringSlice(conf, "cors.allowed_origins"),
+			AllowedMethods:   getStringSlice(conf, "cors.allowed_methods"),
+			AllowedHeaders:   getStringSlice(conf, "cors.allowed_headers"),
+			AllowCredentials: conf.GetBool("cors.allow_credentials"),
+			MaxAge:           conf.GetDuration("cors.max_age"),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:    conf.GetBool("rate_limit.enabled"),
+			KeyBy:      conf.GetString("rate_limit.key_by"),
+			ReadRate:   conf.GetFloat64("rate_limit.read_rate"),
+			ReadBurst:  conf.GetInt("rate_limit.read_burst"),
+			WriteRate:  conf.GetFloat64("rate_limit.write_rate"),
+			WriteBurst: conf.GetInt("rate_limit.write_burst"),
+		},
+		Cache: CacheConfig{
+			Enabled:    conf.GetBool("cache.enabled"),
+			Size:       conf.GetInt("cache.size"),
+			TTL:        conf.GetDuration("cache.ttl"),
+			ListTTL:    conf.GetDuration("cache.list_ttl"),
+			HttpMaxAge: conf.GetDuration("cache.http_max_age"),
+		},
+		Site: SiteConfig{
+			Url:         conf.GetString("site.url"),
+			Title:       conf.GetString("site.title"),
+			Description: conf.GetString("site.description"),
+		},
+		Feed: FeedConfig{
+			Items:       conf.GetInt("feed.items"),
+			MaxItems:    conf.GetInt("feed.max_items"),
+			FullContent: conf.GetBool("feed.full_content"),
+		},
+		Auth: AuthConfig{
+			GatewaySecret:     conf.GetString("auth.gateway_secret"),
+			GatewaySecretFile: conf.GetString("auth.gateway_secret_file"),
+		},
+		Moderation: ModerationConfig{
+			AutoApproveAdmins:   conf.GetBool("moderation.auto_approve_admins"),
+			TrustedUserIds:      getIntSlice(conf, "moderation.trusted_user_ids"),
+			AutoApproveAfter:    conf.GetInt("moderation.auto_approve_after"),
+			ReportHideThreshold: conf.GetInt("moderation.report_hide_threshold"),
+		},
+		ContentFilter: ContentFilterConfig{
+			Keywords:        getStringSlice(conf, "content_filter.keywords"),
+			KeywordScore:    conf.GetFloat64("content_filter.keyword_score"),
+			MaxLinks:        conf.GetInt("content_filter.max_links"),
+			LinkScore:       conf.GetFloat64("content_filter.link_score"),
+			DuplicateWindow: conf.GetDuration("content_filter.duplicate_window"),
+			DuplicateScore:  conf.GetFloat64("content_filter.duplicate_score"),
+			VelocityWindow:  conf.GetDuration("content_filter.velocity_window"),
+			VelocityLimit:   conf.GetInt("content_filter.velocity_limit"),
+			VelocityScore:   conf.GetFloat64("content_filter.velocity_score"),
+			ModerateScore:   conf.GetFloat64("content_filter.moderate_score"),
+			RejectScore:     conf.GetFloat64("content_filter.reject_score"),
+		},
+		Sitemap: SitemapConfig{
+			ChunkSize:       conf.GetInt("sitemap.chunk_size"),
+			RefreshInterval: conf.GetDuration("sitemap.refresh_interval"),
+			RebuildInterval: conf.GetDuration("sitemap.rebuild_interval"),
+		},
+		Trash: TrashConfig{
+			Retention:     conf.GetDuration("trash.retention"),
+			PurgeInterval: conf.GetDuration("trash.purge_interval"),
+		},
+		Media: MediaConfig{
+			Driver:          conf.GetString("media.driver"),
+			LocalDir:        conf.GetString("media.local_dir"),
+			BaseUrl:         conf.GetString("media.base_url"),
+			MaxUploadSize:   conf.GetInt64("media.max_upload_size"),
+			OrphanTTL:       conf.GetDuration("media.orphan_ttl"),
+			CleanupInterval: conf.GetDuration("media.cleanup_interval"),
+			S3: S3Config{
+				Endpoint:  conf.GetString("media.s3.endpoint"),
+				Region:    conf.GetString("media.s3.region"),
+				Bucket:    conf.GetString("media.s3.bucket"),
+				AccessKey: conf.GetString("media.s3.access_key"),
+				SecretKey: conf.GetString("media.s3.secret_key"),
+			},
+			Images: ImageConfig{
+				Sizes:   conf.GetString("image.sizes"),
+				Formats: getStringSlice(conf, "image.formats"),
+				Quality: conf.GetInt("image.quality"),
+				Workers: conf.GetInt("image.workers"),
+			},
+		},
+	}
+
+	if cfg.Auth.GatewaySecretFile != "" {
+		secret, err := os.ReadFile(cfg.Auth.GatewaySecretFile)
+		if err != nil {
+			return Config{}, fmt.Errorf("auth.gateway_secret_file: %w", err)
+		}
+		cfg.Auth.GatewaySecret = strings.TrimSpace(string(secret))
+	}
+
+	return cfg, nil
+}
+
+// IsHelp reports whether err means the flags asked for the usage, which
+// was printed already.
+func IsHelp(err error) bool {
+	return errors.Is(err, pflag.ErrHelp)
+}
+
+func flagName(key string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(key)
+}
+
+// getIntSlice reads a list of integers, skipping anything that does not
+// parse.
+func getIntSlice(conf *viper.Viper, key string) []int {
+	result := make([]int, 0)
+	for _, part := range getStringSlice(conf, key) {
+		v, err := strconv.Atoi(part)
+		if err == nil {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// getStringSlice reads a list from the config file, or a comma separated
+// one from the environment or flags, dropping empty entries.
+func getStringSlice(conf *viper.Viper, key string) []string {
+	var parts []string
+	switch v := conf.Get(key).(type) {
+	case string:
+		parts = strings.Split(v, ",")
+	default:
+		parts = cast.ToStringSlice(v)
+	}
+
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Validate reports every setting that is missing or out of range, naming
+// them by their key.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: "+format, append([]interface{}{key}, args...)...))
+		}
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, key, "must be a positive duration, got %s", d)
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		check(false, key, "must be one of %s, got %q", strings.Join(allowed, ", "), value)
+	}
+
+	check(c.HttpPort != "", "http.port", "is required")
+	positive("http.read_timeout", c.Server.ReadTimeout)
+	positive("http.read_header_timeout", c.Server.ReadHeaderTimeout)
+	positive("http.write_timeout", c.Server.WriteTimeout)
+	positive("http.idle_timeout", c.Server.IdleTimeout)
+	positive("http.shutdown_timeout", c.Server.ShutdownTimeout)
+	check(c.Server.MaxHeaderBytes >= 4<<10, "http.max_header_bytes", "must be at least 4096, got %d", c.Server.MaxHeaderBytes)
+	check((c.Server.TLSCertFile == "") == (c.Server.TLSKeyFile == ""), "http.tls_cert_file",
+		"must be set together with http.tls_key_file")
+	check(c.Server.MaxBodyBytes > 0, "http.max_body_bytes", "must be positive, got %d", c.Server.MaxBodyBytes)
+	check(c.Server.HSTSMaxAge >= 0, "http.hsts_max_age", "must not be negative")
+	for _, proxy := range c.Server.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "http.trusted_proxies",
+			"%q is neither an ip address nor a CIDR range", proxy)
+	}
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+
+	oneOf("tracing.exporter", c.Tracing.Exporter, "none", "stdout", "otlp")
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio",
+		"must be between 0 and 1, got %v", c.Tracing.SampleRatio)
+	positive("health.check_timeout", c.Health.CheckTimeout)
+
+	check(c.Postgres.Host != "", "postgres.host", "is required")
+	check(c.Postgres.User != "", "postgres.user", "is required")
+	check(c.Postgres.Database != "", "postgres.database", "is required")
+	oneOf("postgres.ssl_mode", c.Postgres.SSLMode, "disable", "require", "verify-ca", "verify-full")
+	check(c.Postgres.MaxOpenConns > 0, "postgres.max_open_conns", "must be positive, got %d", c.Postgres.MaxOpenConns)
+	check(c.Postgres.MaxIdleConns >= 0 && c.Postgres.MaxIdleConns <= c.Postgres.MaxOpenConns, "postgres.max_idle_conns",
+		"must be between 0 and postgres.max_open_conns, got %d", c.Postgres.MaxIdleConns)
+	check(c.Postgres.ConnMaxLifetime >= 0, "postgres.conn_max_lifetime", "must not be negative")
+	check(c.Postgres.ConnMaxIdleTime >= 0, "postgres.conn_max_idle_time", "must not be negative")
+	check(c.Postgres.StatementTimeout >= 0, "postgres.statement_timeout", "must not be negative")
+	if len(c.Postgres.ReplicaHosts) > 0 {
+		positive("postgres.replica_max_lag", c.Postgres.ReplicaMaxLag)
+		positive("postgres.replica_stickiness", c.Postgres.ReplicaStickiness)
+		positive("postgres.replica_check_interval", c.Postgres.ReplicaCheckInterval)
+	}
+
+	for _, origin := range c.Cors.AllowedOrigins {
+		u, err := url.Parse(origin)
+		check(origin == "*" || err == nil && u.Scheme != "" && u.Host != "" && u.Path == "", "cors.allowed_origins",
+			"%q is neither * nor an origin like https://example.com", origin)
+	}
+	check(!c.Cors.AllowCredentials || !contains(c.Cors.AllowedOrigins, "*"), "cors.allow_credentials",
+		"cannot be used with the * origin")
+
+	if c.RateLimit.Enabled {
+		oneOf("rate_limit.key_by", c.RateLimit.KeyBy, "ip", "user")
+		check(c.RateLimit.ReadRate > 0, "rate_limit.read_rate", "must be positive, got %v", c.RateLimit.ReadRate)
+		check(c.RateLimit.ReadBurst > 0, "rate_limit.read_burst", "must be positive, got %d", c.RateLimit.ReadBurst)
+		check(c.RateLimit.WriteRate > 0, "rate_limit.write_rate", "must be positive, got %v", c.RateLimit.WriteRate)
+		check(c.RateLimit.WriteBurst > 0, "rate_limit.write_burst", "must be positive, got %d", c.RateLimit.WriteBurst)
+	}
+
+	if c.Cache.Enabled {
+		check(c.Cache.Size > 0, "cache.size", "must be positive, got %d", c.Cache.Size)
+		positive("cache.ttl", c.Cache.TTL)
+		positive("cache.list_ttl", c.Cache.ListTTL)
+	}
+	check(c.Cache.HttpMaxAge >= 0, "cache.http_max_age", "must not be negative")
+
+	check(c.Auth.GatewaySecret == "" || len(c.Auth.GatewaySecret) >= 16, "auth.gateway_secret",
+		"must be at least 16 characters long")
+
+	u, err := url.Parse(c.Site.Url)
+	check(err == nil && u.Scheme != "" && u.Host != "", "site.url", "must be an absolute url, got %q", c.Site.Url)
+	check(c.Feed.Items > 0 && c.Feed.Items <= c.Feed.MaxItems, "feed.items", "must be between 1 and feed.max_items")
+
+	positive("sitemap.refresh_interval", c.Sitemap.RefreshInterval)
+	positive("sitemap.rebuild_interval", c.Sitemap.RebuildInterval)
+	positive("trash.purge_interval", c.Trash.PurgeInterval)
+	positive("media.cleanup_interval", c.Media.CleanupInterval)
+
+	oneOf("media.driver", c.Media.Driver, "local", "s3")
+	if c.Media.Driver == "s3" {
+		check(c.Media.S3.Bucket != "", "media.s3.bucket", "is required by the s3 driver")
+		check(c.Media.S3.Region != "", "media.s3.region", "is required by the s3 driver")
+	}
+	check(c.Media.MaxUploadSize > 0, "media.max_upload_size", "must be positive, got %d", c.Media.MaxUploadSize)
+	check(c.Media.Images.Quality >= 1 && c.Media.Images.Quality <= 100, "image.quality",
+		"must be between 1 and 100, got %d", c.Media.Images.Quality)
+
+	return errors.Join(errs...)
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+CREATE TABLE if not exists "media"(
+    "id" serial PRIMARY KEY,
+    "object_key" VARCHAR(255) NOT NULL UNIQUE,
+    "url" VARCHAR(255) NOT NULL UNIQUE,
+    "content_type" VARCHAR(255) NOT NULL,
+    "size" BIGINT NOT NULL,
+    "user_id" INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE default current_timestamp
+);
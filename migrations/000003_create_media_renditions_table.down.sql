@@ -0,0 +1,3 @@
+drop table if exists media_renditions;
+
+ALTER TABLE "media" DROP COLUMN if exists "status";
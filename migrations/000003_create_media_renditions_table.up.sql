@@ -0,0 +1,15 @@
+ALTER TABLE "media" ADD COLUMN if not exists "status" VARCHAR(255) CHECK("status" IN('pending','ready','failed')) NOT NULL DEFAULT 'pending';
+
+CREATE TABLE if not exists "media_renditions"(
+    "id" serial PRIMARY KEY,
+    "media_id" INTEGER NOT NULL REFERENCES media(id) ON DELETE CASCADE,
+    "name" VARCHAR(255) NOT NULL,
+    "format" VARCHAR(255) NOT NULL,
+    "object_key" VARCHAR(255) NOT NULL UNIQUE,
+    "url" VARCHAR(255) NOT NULL,
+    "content_type" VARCHAR(255) NOT NULL,
+    "width" INTEGER NOT NULL,
+    "height" INTEGER NOT NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE default current_timestamp,
+    UNIQUE("media_id", "name", "format")
+);
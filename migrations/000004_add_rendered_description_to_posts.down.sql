@@ -0,0 +1,4 @@
+ALTER TABLE "posts" DROP COLUMN if exists "excerpt";
+ALTER TABLE "posts" DROP COLUMN if exists "reading_time";
+ALTER TABLE "posts" DROP COLUMN if exists "toc";
+ALTER TABLE "posts" DROP COLUMN if exists "description_html";
@@ -0,0 +1,5 @@
+ALTER TABLE "posts" ADD COLUMN if not exists "description_html" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "posts" ADD COLUMN if not exists "toc" JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE "posts" ADD COLUMN if not exists "reading_time" INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE "posts" ADD COLUMN if not exists "excerpt" TEXT NOT NULL DEFAULT '';
+-- existing posts are rendered by running "post render descriptions"
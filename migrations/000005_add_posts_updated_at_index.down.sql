@@ -0,0 +1 @@
+drop index if exists posts_updated_at_idx;
@@ -0,0 +1 @@
+CREATE INDEX if not exists "posts_updated_at_idx" ON "posts"("updated_at");
@@ -0,0 +1,10 @@
+drop index if exists comments_status_idx;
+
+ALTER TABLE "comments" DROP COLUMN if exists "moderated_at";
+ALTER TABLE "comments" DROP COLUMN if exists "moderated_by";
+ALTER TABLE "comments" DROP COLUMN if exists "status";
+
+ALTER TABLE "comments" ALTER COLUMN "updated_at" DROP DEFAULT;
+ALTER TABLE "comments" ALTER COLUMN "created_at" DROP DEFAULT;
+ALTER TABLE "comments" ALTER COLUMN "id" DROP DEFAULT;
+drop sequence if exists comments_id_seq;
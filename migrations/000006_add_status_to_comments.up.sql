@@ -0,0 +1,15 @@
+CREATE SEQUENCE if not exists "comments_id_seq" OWNED BY "comments"."id";
+SELECT setval('comments_id_seq', coalesce((SELECT max("id") FROM "comments"), 0) + 1, false);
+ALTER TABLE "comments" ALTER COLUMN "id" SET DEFAULT nextval('comments_id_seq');
+ALTER TABLE "comments" ALTER COLUMN "created_at" SET DEFAULT current_timestamp;
+ALTER TABLE "comments" ALTER COLUMN "updated_at" SET DEFAULT current_timestamp;
+
+ALTER TABLE "comments" ADD COLUMN if not exists "status" VARCHAR(255)
+    CHECK("status" IN('pending','approved','rejected','spam')) NOT NULL DEFAULT 'pending';
+ALTER TABLE "comments" ADD COLUMN if not exists "moderated_by" INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE "comments" ADD COLUMN if not exists "moderated_at" TIMESTAMP WITH TIME ZONE;
+
+-- comments written before moderation existed were already public
+UPDATE "comments" SET "status"='approved';
+
+CREATE INDEX if not exists "comments_status_idx" ON "comments"("status", "created_at");
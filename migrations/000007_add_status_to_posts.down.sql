@@ -0,0 +1,5 @@
+drop index if exists posts_status_idx;
+
+ALTER TABLE "posts" DROP COLUMN if exists "moderated_at";
+ALTER TABLE "posts" DROP COLUMN if exists "moderated_by";
+ALTER TABLE "posts" DROP COLUMN if exists "status";
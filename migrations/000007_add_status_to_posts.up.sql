@@ -0,0 +1,6 @@
+ALTER TABLE "posts" ADD COLUMN if not exists "status" VARCHAR(255)
+    CHECK("status" IN('pending','approved','rejected','spam')) NOT NULL DEFAULT 'approved';
+ALTER TABLE "posts" ADD COLUMN if not exists "moderated_by" INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE "posts" ADD COLUMN if not exists "moderated_at" TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX if not exists "posts_status_idx" ON "posts"("status", "created_at");
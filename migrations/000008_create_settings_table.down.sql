@@ -0,0 +1 @@
+DROP TABLE if exists "settings";
@@ -0,0 +1,5 @@
+CREATE TABLE if not exists "settings"(
+    "key" VARCHAR(255) PRIMARY KEY,
+    "value" JSONB NOT NULL,
+    "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,3 @@
+DROP TABLE if exists "report_actions";
+DROP TABLE if exists "report_entries";
+DROP TABLE if exists "reports";
@@ -0,0 +1,37 @@
+CREATE TABLE if not exists "reports"(
+    "id" serial PRIMARY KEY,
+    "target_type" VARCHAR(255) CHECK("target_type" IN('post','comment','user')) NOT NULL,
+    "target_id" INTEGER NOT NULL,
+    "status" VARCHAR(255) CHECK("status" IN('open','dismissed','removed','suspended')) NOT NULL DEFAULT 'open',
+    "report_count" INTEGER NOT NULL DEFAULT 0,
+    "hidden" BOOLEAN NOT NULL DEFAULT false,
+    "resolved_by" INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    "resolved_at" TIMESTAMP WITH TIME ZONE,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    "updated_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- all reports on a target are grouped into its one open report
+CREATE UNIQUE INDEX if not exists "reports_open_target_idx" ON "reports"("target_type", "target_id") WHERE "status"='open';
+CREATE INDEX if not exists "reports_status_idx" ON "reports"("status", "created_at");
+
+CREATE TABLE if not exists "report_entries"(
+    "id" serial PRIMARY KEY,
+    "report_id" INTEGER NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    "reporter_id" INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    "reason" VARCHAR(255) CHECK("reason" IN('spam','harassment','hate','violence','sexual','misinformation','other')) NOT NULL,
+    "details" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE("report_id", "reporter_id")
+);
+
+CREATE TABLE if not exists "report_actions"(
+    "id" serial PRIMARY KEY,
+    "report_id" INTEGER NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    "actor_id" INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    "action" VARCHAR(255) NOT NULL,
+    "note" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX if not exists "report_actions_report_id_idx" ON "report_actions"("report_id");
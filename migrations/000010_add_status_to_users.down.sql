@@ -0,0 +1,5 @@
+DROP TABLE if exists "user_status_history";
+
+ALTER TABLE "users" DROP COLUMN if exists "status_expires_at";
+ALTER TABLE "users" DROP COLUMN if exists "status_reason";
+ALTER TABLE "users" DROP COLUMN if exists "status";
@@ -0,0 +1,17 @@
+ALTER TABLE "users" ADD COLUMN if not exists "status" VARCHAR(255)
+    CHECK("status" IN('active','suspended','banned','deactivated')) NOT NULL DEFAULT 'active';
+ALTER TABLE "users" ADD COLUMN if not exists "status_reason" TEXT NOT NULL DEFAULT '';
+ALTER TABLE "users" ADD COLUMN if not exists "status_expires_at" TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE if not exists "user_status_history"(
+    "id" serial PRIMARY KEY,
+    "user_id" INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    "status" VARCHAR(255) CHECK("status" IN('active','suspended','banned','deactivated')) NOT NULL,
+    "reason" TEXT NOT NULL DEFAULT '',
+    "expires_at" TIMESTAMP WITH TIME ZONE,
+    "changed_by" INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    "report_id" INTEGER REFERENCES reports(id) ON DELETE SET NULL,
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX if not exists "user_status_history_user_id_idx" ON "user_status_history"("user_id");
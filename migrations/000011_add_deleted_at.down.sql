@@ -0,0 +1,20 @@
+-- rows still in the trash would come back to life, remove them for good
+DELETE FROM "likes" WHERE "deleted_at" IS NOT NULL;
+DELETE FROM "comments" WHERE "deleted_at" IS NOT NULL;
+DELETE FROM "posts" WHERE "deleted_at" IS NOT NULL;
+DELETE FROM "categories" WHERE "deleted_at" IS NOT NULL;
+DELETE FROM "users" u WHERE "deleted_at" IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM "posts" p WHERE p."user_id"=u."id");
+
+DROP INDEX if exists "users_phone_number_idx";
+DROP INDEX if exists "users_username_idx";
+DROP INDEX if exists "users_email_idx";
+ALTER TABLE "users" ADD CONSTRAINT "users_email_key" UNIQUE("email");
+ALTER TABLE "users" ADD CONSTRAINT "users_username_key" UNIQUE("username");
+ALTER TABLE "users" ADD CONSTRAINT "users_phone_number_key" UNIQUE("phone_number");
+
+ALTER TABLE "likes" DROP COLUMN if exists "deleted_at";
+ALTER TABLE "users" DROP COLUMN if exists "deleted_at";
+ALTER TABLE "categories" DROP COLUMN if exists "deleted_at";
+ALTER TABLE "comments" DROP COLUMN if exists "deleted_at";
+ALTER TABLE "posts" DROP COLUMN if exists "deleted_at";
@@ -0,0 +1,21 @@
+ALTER TABLE "posts" ADD COLUMN if not exists "deleted_at" TIMESTAMP WITH TIME ZONE;
+ALTER TABLE "comments" ADD COLUMN if not exists "deleted_at" TIMESTAMP WITH TIME ZONE;
+ALTER TABLE "categories" ADD COLUMN if not exists "deleted_at" TIMESTAMP WITH TIME ZONE;
+ALTER TABLE "users" ADD COLUMN if not exists "deleted_at" TIMESTAMP WITH TIME ZONE;
+ALTER TABLE "likes" ADD COLUMN if not exists "deleted_at" TIMESTAMP WITH TIME ZONE;
+
+-- only the trash listing and the purge look for deleted rows
+CREATE INDEX if not exists "posts_deleted_at_idx" ON "posts"("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX if not exists "comments_deleted_at_idx" ON "comments"("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX if not exists "categories_deleted_at_idx" ON "categories"("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX if not exists "users_deleted_at_idx" ON "users"("deleted_at") WHERE "deleted_at" IS NOT NULL;
+CREATE INDEX if not exists "likes_deleted_at_idx" ON "likes"("deleted_at") WHERE "deleted_at" IS NOT NULL;
+
+-- deleted users keep their email, username and phone number until they are
+-- purged, without keeping anyone else from signing up with them
+ALTER TABLE "users" DROP CONSTRAINT if exists "users_email_key";
+ALTER TABLE "users" DROP CONSTRAINT if exists "users_username_key";
+ALTER TABLE "users" DROP CONSTRAINT if exists "users_phone_number_key";
+CREATE UNIQUE INDEX if not exists "users_email_idx" ON "users"("email") WHERE "deleted_at" IS NULL;
+CREATE UNIQUE INDEX if not exists "users_username_idx" ON "users"("username") WHERE "deleted_at" IS NULL;
+CREATE UNIQUE INDEX if not exists "users_phone_number_idx" ON "users"("phone_number") WHERE "deleted_at" IS NULL;
@@ -0,0 +1,2 @@
+DROP TABLE if exists "audit_log";
+DROP FUNCTION if exists "audit_log_append_only"();
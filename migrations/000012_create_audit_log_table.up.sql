@@ -0,0 +1,27 @@
+CREATE TABLE if not exists "audit_log"(
+    "id" bigserial PRIMARY KEY,
+    -- no foreign key, entries outlive purged users
+    "actor_id" INTEGER,
+    "action" VARCHAR(64) NOT NULL,
+    "entity_type" VARCHAR(64) NOT NULL,
+    "entity_id" VARCHAR(255) NOT NULL,
+    "before" JSONB,
+    "after" JSONB,
+    "request_id" VARCHAR(255) NOT NULL DEFAULT '',
+    "ip" VARCHAR(64) NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX if not exists "audit_log_entity_idx" ON "audit_log"("entity_type", "entity_id", "created_at");
+CREATE INDEX if not exists "audit_log_actor_id_idx" ON "audit_log"("actor_id", "created_at");
+CREATE INDEX if not exists "audit_log_created_at_idx" ON "audit_log"("created_at");
+
+CREATE OR REPLACE FUNCTION "audit_log_append_only"() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER "audit_log_append_only"
+    BEFORE UPDATE OR DELETE ON "audit_log"
+    FOR EACH ROW EXECUTE PROCEDURE "audit_log_append_only"();
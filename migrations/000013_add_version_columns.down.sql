@@ -0,0 +1,4 @@
+ALTER TABLE "categories" DROP COLUMN if exists "version";
+ALTER TABLE "users" DROP COLUMN if exists "version";
+ALTER TABLE "comments" DROP COLUMN if exists "version";
+ALTER TABLE "posts" DROP COLUMN if exists "version";
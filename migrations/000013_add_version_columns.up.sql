@@ -0,0 +1,4 @@
+ALTER TABLE "posts" ADD COLUMN if not exists "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "comments" ADD COLUMN if not exists "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "users" ADD COLUMN if not exists "version" INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE "categories" ADD COLUMN if not exists "version" INTEGER NOT NULL DEFAULT 1;
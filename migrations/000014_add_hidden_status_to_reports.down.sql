@@ -0,0 +1 @@
+ALTER TABLE "reports" DROP COLUMN if exists "hidden_status";
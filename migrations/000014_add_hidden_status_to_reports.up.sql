@@ -0,0 +1,3 @@
+-- the moderation status a post or comment had before its report hid it,
+-- dismissing the report restores it
+ALTER TABLE "reports" ADD COLUMN if not exists "hidden_status" VARCHAR(255) NOT NULL DEFAULT '';
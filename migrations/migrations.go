@@ -0,0 +1,28 @@
+// Package migrations embeds the schema migrations applied with
+// golang-migrate, so the api knows which version it expects.
+package migrations
+
+import (
+	"embed"
+	"strconv"
+	"strings"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Latest returns the version of the newest migration, the one the schema
+// must be at for the api to run.
+func Latest() uint {
+	entries, _ := FS.ReadDir(".")
+
+	var latest uint
+	for _, e := range entries {
+		prefix, _, _ := strings.Cut(e.Name(), "_")
+		v, err := strconv.ParseUint(prefix, 10, 64)
+		if err == nil && uint(v) > latest {
+			latest = uint(v)
+		}
+	}
+	return latest
+}
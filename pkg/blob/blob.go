@@ -0,0 +1,26 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var ErrNotFound = errors.New("blob: object not found")
+
+type Object struct {
+	Key         string
+	ContentType string
+	Size        int64
+	ModifiedAt  time.Time
+}
+
+// Store is a flat key/value object store. Keys are slash separated paths
+// such as "2022/11/3f9a0c.jpg" and are never interpreted by the caller.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]*Object, error)
+}
@@ -0,0 +1,107 @@
+package blob_test
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/samandar2605/post/pkg/blob"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a tiny in-memory stand-in for an S3 bucket. It understands
+// just enough of the API (PUT/GET/DELETE object, ListObjectsV2) for Store.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+		type content struct {
+			Key  string
+			Size int64
+		}
+		var res struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []content
+		}
+		for k, v := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				res.Contents = append(res.Contents, content{Key: k, Size: int64(len(v))})
+			}
+		}
+		xml.NewEncoder(w).Encode(res)
+	case r.Method == http.MethodPut:
+		b, _ := io.ReadAll(r.Body)
+		f.objects[key] = b
+	case r.Method == http.MethodGet:
+		b, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func testStore(t *testing.T, s blob.Store) {
+	ctx := context.Background()
+
+	err := s.Put(ctx, "2022/11/a.txt", strings.NewReader("hello"), 5, "text/plain")
+	require.NoError(t, err)
+
+	rc, obj, err := s.Get(ctx, "2022/11/a.txt")
+	require.NoError(t, err)
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(b))
+	require.Equal(t, "2022/11/a.txt", obj.Key)
+
+	list, err := s.List(ctx, "2022/")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	require.NoError(t, s.Delete(ctx, "2022/11/a.txt"))
+
+	_, _, err = s.Get(ctx, "2022/11/a.txt")
+	require.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestLocalStore(t *testing.T) {
+	s, err := blob.NewLocal(t.TempDir())
+	require.NoError(t, err)
+
+	testStore(t, s)
+}
+
+func TestS3Store(t *testing.T) {
+	srv := httptest.NewServer(&fakeS3{objects: map[string][]byte{}})
+	defer srv.Close()
+
+	testStore(t, blob.NewS3(blob.S3Config{
+		Endpoint:  srv.URL,
+		Bucket:    "bucket",
+		AccessKey: "key",
+		SecretKey: "secret",
+	}, srv.Client()))
+}
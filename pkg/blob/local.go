@@ -0,0 +1,139 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type localStore struct {
+	root string
+}
+
+// NewLocal returns a Store that keeps objects as plain files under root.
+func NewLocal(root string) (Store, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &localStore{root: root}, nil
+}
+
+func (s *localStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", errors.New("blob: invalid key")
+	}
+	return filepath.Join(s.root, filepath.FromSlash(clean)), nil
+}
+
+func (s *localStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *localStore) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return f, &Object{
+		Key:         key,
+		ContentType: mime.TypeByExtension(path.Ext(key)),
+		Size:        info.Size(),
+		ModifiedAt:  info.ModTime(),
+	}, nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *localStore) List(ctx context.Context, prefix string) ([]*Object, error) {
+	result := make([]*Object, 0)
+
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		result = append(result, &Object{
+			Key:         key,
+			ContentType: mime.TypeByExtension(path.Ext(key)),
+			Size:        info.Size(),
+			ModifiedAt:  info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
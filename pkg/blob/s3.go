@@ -0,0 +1,280 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+type s3Store struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+// NewS3 returns a Store backed by any S3 compatible service (AWS S3, MinIO,
+// Ceph RGW ...). Requests use path-style addressing and AWS signature V4.
+func NewS3(cfg S3Config, client *http.Client) Store {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+
+	return &s3Store{cfg: cfg, client: client}
+}
+
+func (s *s3Store) objectURL(key string) string {
+	return s.cfg.Endpoint + "/" + s.cfg.Bucket + "/" + escapePath(key)
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	modified, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return resp.Body, &Object{
+		Key:         key,
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+		ModifiedAt:  modified,
+	}, nil
+}
+
+func (s *s3Store) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]*Object, error) {
+	result := make([]*Object, 0)
+	token := ""
+
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", prefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.cfg.Endpoint+"/"+s.cfg.Bucket+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var page listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range page.Contents {
+			result = append(result, &Object{
+				Key:        c.Key,
+				Size:       c.Size,
+				ModifiedAt: c.LastModified,
+			})
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return result, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+func (s *s3Store) do(req *http.Request) (*http.Response, error) {
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("blob: s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, body)
+	}
+
+	return resp, nil
+}
+
+// sign adds an AWS signature V4 Authorization header to req.
+func (s *s3Store) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		headers = append(headers, "content-type")
+		sort.Strings(headers)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := day + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), day)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, signedHeaders, signature,
+	))
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, v := range q[k] {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = uriEncode(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(strconv.FormatInt(int64(c)|0x100, 16)[1:]))
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+// Package cache keeps encoded values for a while so they need not be loaded
+// from the database again.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache stores values by key until their time to live passed. LRU keeps
+// them in the process; an implementation on e.g. Redis or memcached shares
+// them, and their invalidation, between the instances of the api.
+type Cache interface {
+	// Get returns the value of key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl, zero keeps it until it is
+	// evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// LRU is a Cache of at most size entries, the least recently used entry is
+// evicted to make room for a new one.
+type LRU struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if !e.expires.IsZero() && !time.Now().Before(e.expires) {
+		c.remove(el)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries kept, including expired ones that were
+// not looked up since.
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).key)
+}
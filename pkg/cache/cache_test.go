@@ -0,0 +1,49 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/cache"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := cache.NewLRU(2)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), 0))
+	_, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), 0))
+	require.Equal(t, 2, c.Len())
+	_, ok, _ = c.Get(ctx, "b")
+	require.False(t, ok)
+	v, ok, _ := c.Get(ctx, "a")
+	require.True(t, ok)
+	require.Equal(t, []byte("1"), v)
+
+	require.NoError(t, c.Delete(ctx, "a", "unknown"))
+	_, ok, _ = c.Get(ctx, "a")
+	require.False(t, ok)
+	require.Equal(t, 1, c.Len())
+}
+
+func TestLRUExpires(t *testing.T) {
+	c := cache.NewLRU(10)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Millisecond))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Hour))
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok, _ := c.Get(ctx, "a")
+	require.False(t, ok)
+	_, ok, _ = c.Get(ctx, "b")
+	require.True(t, ok)
+	require.Equal(t, 1, c.Len())
+}
@@ -0,0 +1,206 @@
+// Package contentfilter scores user submitted text for spam and decides
+// whether it is published, held for moderation or rejected.
+package contentfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Action string
+
+const (
+	Publish  Action = "publish"
+	Moderate Action = "moderate"
+	Reject   Action = "reject"
+)
+
+// Content is one submission to check.
+type Content struct {
+	Kind   string // "comment" or "post", only used for logging
+	UserId int    // 0 for anonymous submissions
+	Client string // ip address anonymous submissions are told apart by
+	Text   string
+	// Replaces is the text an edit replaces, resubmitting it unchanged is
+	// no duplicate.
+	Replaces string
+}
+
+// Result is what a single filter found. Every reason adds to the score.
+type Result struct {
+	Score   float64
+	Reasons []string
+}
+
+// Filter is one check run on every submission. Filters may keep state
+// between calls, e.g. to see duplicates, and must be safe for concurrent
+// use.
+type Filter interface {
+	Name() string
+	Check(c *Content, rules *Rules) Result
+}
+
+// Decision is the combined outcome of all filters.
+type Decision struct {
+	Action  Action
+	Score   float64
+	Reasons []string
+}
+
+// Duration is a time.Duration that is written as "10m" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Rules configure the built-in filters and the thresholds the total score is
+// compared against. A zero score disables the corresponding check.
+type Rules struct {
+	Keywords     []string `json:"keywords"`
+	Patterns     []string `json:"patterns"`
+	KeywordScore float64  `json:"keyword_score"`
+
+	// LinkScore is added for every link above MaxLinks.
+	MaxLinks  int     `json:"max_links"`
+	LinkScore float64 `json:"link_score"`
+
+	DuplicateWindow Duration `json:"duplicate_window"`
+	DuplicateScore  float64  `json:"duplicate_score"`
+
+	// VelocityLimit submissions per user are allowed in VelocityWindow.
+	VelocityWindow Duration `json:"velocity_window"`
+	VelocityLimit  int      `json:"velocity_limit"`
+	VelocityScore  float64  `json:"velocity_score"`
+
+	ModerateScore float64 `json:"moderate_score"`
+	RejectScore   float64 `json:"reject_score"`
+
+	patterns []*regexp.Regexp
+}
+
+// Validate checks the thresholds and compiles the patterns.
+func (r *Rules) Validate() error {
+	if r.ModerateScore <= 0 || r.RejectScore <= 0 {
+		return fmt.Errorf("moderate_score and reject_score must be positive")
+	}
+	if r.RejectScore < r.ModerateScore {
+		return fmt.Errorf("reject_score must not be lower than moderate_score")
+	}
+	if r.MaxLinks < 0 || r.VelocityLimit < 0 {
+		return fmt.Errorf("max_links and velocity_limit must not be negative")
+	}
+	if r.DuplicateWindow < 0 || r.VelocityWindow < 0 {
+		return fmt.Errorf("windows must not be negative")
+	}
+
+	patterns := make([]*regexp.Regexp, 0, len(r.Patterns))
+	for _, p := range r.Patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	r.patterns = patterns
+
+	return nil
+}
+
+// Engine runs all registered filters and turns their score into a decision.
+// Rules can be swapped at any time.
+type Engine struct {
+	mu      sync.RWMutex
+	rules   *Rules
+	filters []Filter
+}
+
+// New returns an engine with the built-in filters registered.
+func New(rules Rules) (*Engine, error) {
+	e := &Engine{}
+	if err := e.SetRules(rules); err != nil {
+		return nil, err
+	}
+	e.Register(Blocklist{})
+	e.Register(Links{})
+	e.Register(NewDuplicates())
+	e.Register(NewVelocity())
+	return e, nil
+}
+
+// Register adds f to the filters run by Check.
+func (e *Engine) Register(f Filter) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.filters = append(e.filters, f)
+}
+
+func (e *Engine) Rules() Rules {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return *e.rules
+}
+
+func (e *Engine) SetRules(rules Rules) error {
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = &rules
+	return nil
+}
+
+// Check scores c and logs the decision with its reasons.
+func (e *Engine) Check(c *Content) *Decision {
+	e.mu.RLock()
+	rules, filters := e.rules, e.filters
+	e.mu.RUnlock()
+
+	d := Decision{
+		Action:  Publish,
+		Reasons: make([]string, 0),
+	}
+	for _, f := range filters {
+		res := f.Check(c, rules)
+		d.Score += res.Score
+		for _, reason := range res.Reasons {
+			d.Reasons = append(d.Reasons, f.Name()+": "+reason)
+		}
+	}
+
+	switch {
+	case d.Score >= rules.RejectScore:
+		d.Action = Reject
+	case d.Score >= rules.ModerateScore:
+		d.Action = Moderate
+	}
+
+	slog.Info("content filter: decision",
+		"kind", c.Kind,
+		"user_id", c.UserId,
+		"action", d.Action,
+		"score", d.Score,
+		"reasons", strings.Join(d.Reasons, "; "),
+	)
+
+	return &d
+}
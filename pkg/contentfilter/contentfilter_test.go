@@ -0,0 +1,123 @@
+package contentfilter_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/contentfilter"
+	"github.com/stretchr/testify/require"
+)
+
+func testRules() contentfilter.Rules {
+	return contentfilter.Rules{
+		Keywords:        []string{"casino", "cheap pills"},
+		Patterns:        []string{`bit\.ly/\w+`},
+		KeywordScore:    1,
+		MaxLinks:        1,
+		LinkScore:       0.5,
+		DuplicateWindow: contentfilter.Duration(time.Hour),
+		DuplicateScore:  1,
+		VelocityWindow:  contentfilter.Duration(time.Minute),
+		VelocityLimit:   3,
+		VelocityScore:   1,
+		ModerateScore:   1,
+		RejectScore:     2,
+	}
+}
+
+func TestCheck(t *testing.T) {
+	e, err := contentfilter.New(testRules())
+	require.NoError(t, err)
+
+	d := e.Check(&contentfilter.Content{Kind: "comment", UserId: 1, Text: "Nice post, thanks!"})
+	require.Equal(t, contentfilter.Publish, d.Action)
+	require.Empty(t, d.Reasons)
+
+	// "casinos" is a different word
+	d = e.Check(&contentfilter.Content{Kind: "comment", UserId: 2, Text: "I like casinos"})
+	require.Equal(t, contentfilter.Publish, d.Action)
+
+	d = e.Check(&contentfilter.Content{Kind: "comment", UserId: 3, Text: "Best Casino in town"})
+	require.Equal(t, contentfilter.Moderate, d.Action)
+	require.Equal(t, []string{`blocklist: keyword "casino"`}, d.Reasons)
+
+	d = e.Check(&contentfilter.Content{Kind: "comment", UserId: 4, Text: "cheap  pills at http://a.com http://b.com http://bit.ly/xyz"})
+	require.Equal(t, contentfilter.Reject, d.Action)
+	require.Equal(t, 3.0, d.Score)
+	require.Len(t, d.Reasons, 3)
+}
+
+func TestCheckDuplicate(t *testing.T) {
+	e, err := contentfilter.New(testRules())
+	require.NoError(t, err)
+
+	d := e.Check(&contentfilter.Content{UserId: 1, Text: "Great article"})
+	require.Equal(t, contentfilter.Publish, d.Action)
+
+	d = e.Check(&contentfilter.Content{UserId: 2, Text: "  great   ARTICLE "})
+	require.Equal(t, contentfilter.Moderate, d.Action)
+	require.Contains(t, d.Reasons[0], "duplicate: same text")
+
+	// an edit keeping its text is no duplicate of itself
+	d = e.Check(&contentfilter.Content{UserId: 1, Text: "Great article", Replaces: "great article"})
+	require.Equal(t, contentfilter.Publish, d.Action)
+	d = e.Check(&contentfilter.Content{UserId: 1, Text: "Great article", Replaces: "Good article"})
+	require.Equal(t, contentfilter.Moderate, d.Action)
+}
+
+func TestCheckVelocity(t *testing.T) {
+	e, err := contentfilter.New(testRules())
+	require.NoError(t, err)
+
+	texts := []string{"one", "two", "three", "four"}
+	for i, text := range texts {
+		d := e.Check(&contentfilter.Content{UserId: 7, Text: text})
+		if i < 3 {
+			require.Equal(t, contentfilter.Publish, d.Action)
+		} else {
+			require.Equal(t, contentfilter.Moderate, d.Action)
+			require.Equal(t, []string{"velocity: 4 submissions in 1m0s, 3 allowed"}, d.Reasons)
+		}
+	}
+
+	d := e.Check(&contentfilter.Content{UserId: 8, Text: "five"})
+	require.Equal(t, contentfilter.Publish, d.Action)
+
+	// anonymous clients have a bucket each
+	for i, text := range texts {
+		d := e.Check(&contentfilter.Content{Client: "10.0.0.1", Text: "anonymous " + text})
+		require.Equal(t, i == 3, d.Action == contentfilter.Moderate)
+	}
+	d = e.Check(&contentfilter.Content{Client: "10.0.0.2", Text: "anonymous five"})
+	require.Equal(t, contentfilter.Publish, d.Action)
+}
+
+func TestSetRules(t *testing.T) {
+	e, err := contentfilter.New(testRules())
+	require.NoError(t, err)
+
+	rules := testRules()
+	rules.Patterns = []string{"("}
+	require.Error(t, e.SetRules(rules))
+
+	rules = testRules()
+	rules.RejectScore = 0.5
+	require.Error(t, e.SetRules(rules))
+
+	rules = testRules()
+	rules.Keywords = []string{"hello"}
+	require.NoError(t, e.SetRules(rules))
+	d := e.Check(&contentfilter.Content{Text: "hello there"})
+	require.Equal(t, contentfilter.Moderate, d.Action)
+}
+
+func TestRulesJSON(t *testing.T) {
+	b, err := json.Marshal(testRules())
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"duplicate_window":"1h0m0s"`)
+
+	var rules contentfilter.Rules
+	require.NoError(t, json.Unmarshal(b, &rules))
+	require.Equal(t, contentfilter.Duration(time.Hour), rules.DuplicateWindow)
+}
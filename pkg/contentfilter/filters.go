@@ -0,0 +1,181 @@
+package contentfilter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blocklist matches keywords case-insensitively as whole words and the
+// rule patterns as case-insensitive regular expressions.
+type Blocklist struct{}
+
+func (Blocklist) Name() string { return "blocklist" }
+
+func (Blocklist) Check(c *Content, rules *Rules) Result {
+	var res Result
+	if rules.KeywordScore == 0 {
+		return res
+	}
+
+	text := strings.Join(strings.Fields(strings.ToLower(c.Text)), " ")
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(text, isSeparator) {
+		words[w] = true
+	}
+	for _, k := range rules.Keywords {
+		k = strings.Join(strings.Fields(strings.ToLower(k)), " ")
+		if k == "" {
+			continue
+		}
+		// phrases can not be looked up word by word
+		if words[k] || (strings.Contains(k, " ") && strings.Contains(text, k)) {
+			res.Score += rules.KeywordScore
+			res.Reasons = append(res.Reasons, fmt.Sprintf("keyword %q", k))
+		}
+	}
+	for _, re := range rules.patterns {
+		if re.MatchString(c.Text) {
+			res.Score += rules.KeywordScore
+			res.Reasons = append(res.Reasons, fmt.Sprintf("pattern %q", re.String()[len("(?i)"):]))
+		}
+	}
+
+	return res
+}
+
+func isSeparator(r rune) bool {
+	return !(r == '\'' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r > 127)
+}
+
+var linkRegexp = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// Links penalizes every link above the allowed number.
+type Links struct{}
+
+func (Links) Name() string { return "links" }
+
+func (Links) Check(c *Content, rules *Rules) Result {
+	var res Result
+	if rules.LinkScore == 0 {
+		return res
+	}
+
+	n := len(linkRegexp.FindAllStringIndex(c.Text, -1))
+	if n > rules.MaxLinks {
+		res.Score = float64(n-rules.MaxLinks) * rules.LinkScore
+		res.Reasons = append(res.Reasons, fmt.Sprintf("%d links, %d allowed", n, rules.MaxLinks))
+	}
+	return res
+}
+
+// Duplicates flags text that was already submitted within the duplicate
+// window, by anyone. Text is compared after lowercasing and collapsing
+// whitespace. Edits that keep the text they replace are not checked. The history lives in memory, so every instance of the api
+// sees only its own submissions.
+type Duplicates struct {
+	mu   sync.Mutex
+	seen map[[sha256.Size]byte]time.Time
+}
+
+func NewDuplicates() *Duplicates {
+	return &Duplicates{
+		seen: make(map[[sha256.Size]byte]time.Time),
+	}
+}
+
+func (d *Duplicates) Name() string { return "duplicate" }
+
+func (d *Duplicates) Check(c *Content, rules *Rules) Result {
+	var res Result
+	window := time.Duration(rules.DuplicateWindow)
+	if rules.DuplicateScore == 0 || window == 0 {
+		return res
+	}
+
+	normalized := normalize(c.Text)
+	if normalized == "" || normalized == normalize(c.Replaces) {
+		return res
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for k, t := range d.seen {
+		if now.Sub(t) > window {
+			delete(d.seen, k)
+		}
+	}
+	if t, ok := d.seen[sum]; ok {
+		res.Score = rules.DuplicateScore
+		res.Reasons = append(res.Reasons, fmt.Sprintf("same text submitted %s ago", now.Sub(t).Round(time.Second)))
+	}
+	d.seen[sum] = now
+
+	return res
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// Velocity flags users submitting more than the limit within the velocity
+// window. Anonymous submissions are counted per client. Like Duplicates it
+// only keeps state in memory.
+type Velocity struct {
+	mu      sync.Mutex
+	clients map[string][]time.Time
+}
+
+func NewVelocity() *Velocity {
+	return &Velocity{
+		clients: make(map[string][]time.Time),
+	}
+}
+
+func (v *Velocity) Name() string { return "velocity" }
+
+func (v *Velocity) Check(c *Content, rules *Rules) Result {
+	var res Result
+	window := time.Duration(rules.VelocityWindow)
+	if rules.VelocityScore == 0 || window == 0 {
+		return res
+	}
+	now := time.Now()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for key, times := range v.clients {
+		recent := times[:0]
+		for _, t := range times {
+			if now.Sub(t) <= window {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(v.clients, key)
+		} else {
+			v.clients[key] = recent
+		}
+	}
+
+	key := "ip:" + c.Client
+	if c.UserId != 0 {
+		key = "user:" + strconv.Itoa(c.UserId)
+	}
+	times := append(v.clients[key], now)
+	v.clients[key] = times
+	if len(times) > rules.VelocityLimit {
+		res.Score = rules.VelocityScore
+		res.Reasons = append(res.Reasons, fmt.Sprintf("%d submissions in %s, %d allowed", len(times), window, rules.VelocityLimit))
+	}
+
+	return res
+}
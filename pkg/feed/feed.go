@@ -0,0 +1,194 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"time"
+)
+
+type Feed struct {
+	Title       string
+	Link        string
+	Self        string // url the feed itself is served from
+	Description string
+	Updated     time.Time
+	Items       []*Item
+}
+
+type Item struct {
+	Id        string
+	Title     string
+	Link      string
+	Author    string
+	Category  string
+	Summary   string
+	Content   string // HTML, left out of the feed when empty
+	Published time.Time
+	Updated   time.Time
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Content string     `xml:"xmlns:content,attr"`
+	Dc      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	AtomLink      atomLink  `xml:"atom:link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Guid        rssGuid `xml:"guid"`
+	Creator     string  `xml:"dc:creator,omitempty"`
+	Category    string  `xml:"category,omitempty"`
+	Description string  `xml:"description"`
+	Content     *cdata  `xml:"content:encoded,omitempty"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// RSS encodes f as an RSS 2.0 document.
+func RSS(f *Feed) ([]byte, error) {
+	doc := rss{
+		Version: "2.0",
+		Content: "http://purl.org/rss/1.0/modules/content/",
+		Dc:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.Link,
+			AtomLink:    atomLink{Href: f.Self, Rel: "self", Type: "application/rss+xml"},
+			Description: f.Description,
+			Items:       make([]rssItem, 0, len(f.Items)),
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, it := range f.Items {
+		item := rssItem{
+			Title:       it.Title,
+			Link:        it.Link,
+			Guid:        rssGuid{IsPermaLink: it.Id == it.Link, Value: it.Id},
+			Creator:     it.Author,
+			Category:    it.Category,
+			Description: it.Summary,
+			PubDate:     it.Published.UTC().Format(time.RFC1123Z),
+		}
+		if it.Content != "" {
+			item.Content = &cdata{Value: it.Content}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	return encode(doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Id      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Id        string        `xml:"id"`
+	Title     string        `xml:"title"`
+	Link      atomLink      `xml:"link"`
+	Author    *atomAuthor   `xml:"author,omitempty"`
+	Category  *atomCategory `xml:"category,omitempty"`
+	Published string        `xml:"published"`
+	Updated   string        `xml:"updated"`
+	Summary   atomText      `xml:"summary"`
+	Content   *atomText     `xml:"content,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom encodes f as an Atom 1.0 document.
+func Atom(f *Feed) ([]byte, error) {
+	doc := atomFeed{
+		Id:    f.Self,
+		Title: f.Title,
+		Links: []atomLink{
+			{Href: f.Link, Rel: "alternate", Type: "text/html"},
+			{Href: f.Self, Rel: "self", Type: "application/atom+xml"},
+		},
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Entries: make([]atomEntry, 0, len(f.Items)),
+	}
+
+	for _, it := range f.Items {
+		entry := atomEntry{
+			Id:        it.Id,
+			Title:     it.Title,
+			Link:      atomLink{Href: it.Link, Rel: "alternate"},
+			Published: it.Published.UTC().Format(time.RFC3339),
+			Updated:   it.Updated.UTC().Format(time.RFC3339),
+			Summary:   atomText{Type: "text", Value: it.Summary},
+		}
+		if it.Author != "" {
+			entry.Author = &atomAuthor{Name: it.Author}
+		}
+		if it.Category != "" {
+			entry.Category = &atomCategory{Term: it.Category}
+		}
+		if it.Content != "" {
+			entry.Content = &atomText{Type: "html", Value: it.Content}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+
+	return encode(doc)
+}
+
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
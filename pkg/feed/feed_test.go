@@ -0,0 +1,133 @@
+package feed_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/feed"
+	"github.com/stretchr/testify/require"
+)
+
+var updated = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testFeed() *feed.Feed {
+	return &feed.Feed{
+		Title:       "Blog",
+		Link:        "https://example.com",
+		Self:        "https://example.com/feeds/rss.xml",
+		Description: "Posts & news",
+		Updated:     updated,
+		Items: []*feed.Item{
+			{
+				Id:        "https://example.com/posts/1",
+				Title:     "Arrays <and> slices",
+				Link:      "https://example.com/posts/1",
+				Author:    "Ann Lee",
+				Category:  "Go",
+				Summary:   "About slices",
+				Content:   "<p>a[b[0]]>1</p>",
+				Published: updated.Add(-time.Hour),
+				Updated:   updated,
+			},
+			{
+				Id:        "https://example.com/posts/2",
+				Title:     "Excerpt only",
+				Link:      "https://example.com/posts/2",
+				Summary:   "Short",
+				Published: updated.Add(-2 * time.Hour),
+				Updated:   updated.Add(-2 * time.Hour),
+			},
+		},
+	}
+}
+
+func TestRSS(t *testing.T) {
+	body, err := feed.RSS(testFeed())
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(body), xml.Header))
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Title         string `xml:"title"`
+			Description   string `xml:"description"`
+			LastBuildDate string `xml:"lastBuildDate"`
+			Items         []struct {
+				Title   string `xml:"title"`
+				Guid    string `xml:"guid"`
+				Creator string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+				PubDate string `xml:"pubDate"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+
+	require.Equal(t, "2.0", doc.Version)
+	require.Equal(t, "Posts & news", doc.Channel.Description)
+	require.Equal(t, "Fri, 01 Mar 2024 12:00:00 +0000", doc.Channel.LastBuildDate)
+	require.Len(t, doc.Channel.Items, 2)
+
+	item := doc.Channel.Items[0]
+	require.Equal(t, "Arrays <and> slices", item.Title)
+	require.Equal(t, "https://example.com/posts/1", item.Guid)
+	require.Equal(t, "Ann Lee", item.Creator)
+	require.Equal(t, "Fri, 01 Mar 2024 11:00:00 +0000", item.PubDate)
+	// the "]]>" in the content must not end the CDATA section early
+	require.Equal(t, "<p>a[b[0]]>1</p>", item.Content)
+
+	require.Contains(t, string(body), "<content:encoded><![CDATA[<p>a[b[0]]")
+	require.Empty(t, doc.Channel.Items[1].Content)
+	require.Empty(t, doc.Channel.Items[1].Creator)
+}
+
+func TestAtom(t *testing.T) {
+	body, err := feed.Atom(testFeed())
+	require.NoError(t, err)
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Id      string   `xml:"id"`
+		Updated string   `xml:"updated"`
+		Links   []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Entries []struct {
+			Title  string `xml:"title"`
+			Author *struct {
+				Name string `xml:"name"`
+			} `xml:"author"`
+			Category *struct {
+				Term string `xml:"term,attr"`
+			} `xml:"category"`
+			Published string `xml:"published"`
+			Content   *struct {
+				Type  string `xml:"type,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"content"`
+		} `xml:"entry"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+
+	require.Equal(t, "https://example.com/feeds/rss.xml", doc.Id)
+	require.Equal(t, "2024-03-01T12:00:00Z", doc.Updated)
+	require.Len(t, doc.Links, 2)
+	require.Equal(t, "alternate", doc.Links[0].Rel)
+	require.Equal(t, "self", doc.Links[1].Rel)
+	require.Len(t, doc.Entries, 2)
+
+	entry := doc.Entries[0]
+	require.Equal(t, "Arrays <and> slices", entry.Title)
+	require.Equal(t, "Ann Lee", entry.Author.Name)
+	require.Equal(t, "Go", entry.Category.Term)
+	require.Equal(t, "2024-03-01T11:00:00Z", entry.Published)
+	require.Equal(t, "html", entry.Content.Type)
+	require.Equal(t, "<p>a[b[0]]>1</p>", entry.Content.Value)
+
+	require.Nil(t, doc.Entries[1].Author)
+	require.Nil(t, doc.Entries[1].Category)
+	require.Nil(t, doc.Entries[1].Content)
+}
@@ -0,0 +1,56 @@
+package feed
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// MaxSitemapUrls is the limit of urls (and of sitemaps in an index) that
+// the sitemaps.org protocol allows per file.
+const MaxSitemapUrls = 50000
+
+type SitemapUrl struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type urlset struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	Urls    []sitemapLoc `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap encodes a urlset document.
+func Sitemap(urls []SitemapUrl) ([]byte, error) {
+	doc := urlset{Urls: make([]sitemapLoc, 0, len(urls))}
+	for _, u := range urls {
+		doc.Urls = append(doc.Urls, newSitemapLoc(u))
+	}
+	return encode(doc)
+}
+
+// SitemapIndex encodes a sitemapindex document listing other sitemaps.
+func SitemapIndex(sitemaps []SitemapUrl) ([]byte, error) {
+	doc := sitemapIndex{Sitemaps: make([]sitemapLoc, 0, len(sitemaps))}
+	for _, s := range sitemaps {
+		doc.Sitemaps = append(doc.Sitemaps, newSitemapLoc(s))
+	}
+	return encode(doc)
+}
+
+func newSitemapLoc(u SitemapUrl) sitemapLoc {
+	loc := sitemapLoc{Loc: u.Loc}
+	if !u.LastMod.IsZero() {
+		loc.LastMod = u.LastMod.UTC().Format(time.RFC3339)
+	}
+	return loc
+}
@@ -0,0 +1,52 @@
+package feed_test
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/feed"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSitemap(t *testing.T) {
+	body, err := feed.Sitemap([]feed.SitemapUrl{
+		{Loc: "https://example.com/posts/1?a=1&b=2", LastMod: time.Date(2024, 3, 1, 14, 0, 0, 0, time.FixedZone("", 2*60*60))},
+		{Loc: "https://example.com/posts/2"},
+	})
+	require.NoError(t, err)
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+		Urls    []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"url"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	require.Len(t, doc.Urls, 2)
+	require.Equal(t, "https://example.com/posts/1?a=1&b=2", doc.Urls[0].Loc)
+	require.Equal(t, "2024-03-01T12:00:00Z", doc.Urls[0].LastMod)
+	require.NotContains(t, string(body), "<lastmod></lastmod>")
+}
+
+func TestSitemapIndex(t *testing.T) {
+	body, err := feed.SitemapIndex([]feed.SitemapUrl{
+		{Loc: "https://example.com/sitemaps/posts-1.xml", LastMod: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{Loc: "https://example.com/sitemaps/categories.xml"},
+	})
+	require.NoError(t, err)
+
+	var doc struct {
+		XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+		Sitemaps []struct {
+			Loc     string `xml:"loc"`
+			LastMod string `xml:"lastmod"`
+		} `xml:"sitemap"`
+	}
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	require.Len(t, doc.Sitemaps, 2)
+	require.Equal(t, "https://example.com/sitemaps/posts-1.xml", doc.Sitemaps[0].Loc)
+	require.Equal(t, "2024-03-01T12:00:00Z", doc.Sitemaps[0].LastMod)
+	require.Empty(t, doc.Sitemaps[1].LastMod)
+}
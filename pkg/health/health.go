@@ -0,0 +1,127 @@
+// Package health runs the checks that tell whether the api is ready to
+// serve, and remembers the outcome of each for status pages.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// State is the outcome of the last run of a check, along with when it last
+// succeeded and failed.
+type State struct {
+	Name      string
+	Healthy   bool
+	Error     string
+	Latency   time.Duration
+	CheckedAt time.Time
+
+	LastSuccess time.Time
+	LastFailure time.Time
+	// LastError is the error of the last failure, kept after the check
+	// recovered.
+	LastError string
+}
+
+type check struct {
+	name  string
+	fn    func(ctx context.Context) error
+	state State
+}
+
+type Checker struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	checks []*check
+}
+
+// New returns a Checker that gives every check at most timeout to finish.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a check, fn must give up once ctx is done.
+func (c *Checker) Register(name string, fn func(ctx context.Context) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, &check{name: name, fn: fn, state: State{Name: name}})
+}
+
+// Check runs all checks concurrently and reports whether they all passed.
+func (c *Checker) Check(ctx context.Context) ([]State, bool) {
+	c.mu.Lock()
+	checks := append([]*check(nil), c.checks...)
+	c.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, ch := range checks {
+		wg.Add(1)
+		go func(ch *check) {
+			defer wg.Done()
+			c.run(ctx, ch)
+		}(ch)
+	}
+	wg.Wait()
+
+	states := c.States()
+	healthy := true
+	for _, s := range states {
+		healthy = healthy && s.Healthy
+	}
+	return states, healthy
+}
+
+func (c *Checker) run(ctx context.Context, ch *check) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- ch.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &ch.state
+	s.Latency = time.Since(start)
+	s.CheckedAt = start
+	s.Healthy = err == nil
+	s.Error = ""
+	if err != nil {
+		s.Error = err.Error()
+		s.LastFailure = start
+		s.LastError = err.Error()
+	} else {
+		s.LastSuccess = start
+	}
+}
+
+// States returns the outcome of the last run of every check by name,
+// without running them.
+func (c *Checker) States() []State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	states := make([]State, 0, len(c.checks))
+	for _, ch := range c.checks {
+		states = append(states, ch.state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
@@ -0,0 +1,68 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	c := health.New(time.Second)
+
+	var dbErr error
+	c.Register("workers", func(ctx context.Context) error { return nil })
+	c.Register("database", func(ctx context.Context) error { return dbErr })
+
+	states, healthy := c.Check(context.Background())
+	require.True(t, healthy)
+	require.Len(t, states, 2)
+	require.Equal(t, "database", states[0].Name)
+	require.True(t, states[0].Healthy)
+	require.False(t, states[0].LastSuccess.IsZero())
+	require.True(t, states[0].LastFailure.IsZero())
+
+	dbErr = errors.New("connection refused")
+	states, healthy = c.Check(context.Background())
+	require.False(t, healthy)
+	require.False(t, states[0].Healthy)
+	require.Equal(t, "connection refused", states[0].Error)
+	require.True(t, states[1].Healthy)
+
+	dbErr = nil
+	states, healthy = c.Check(context.Background())
+	require.True(t, healthy)
+	require.Empty(t, states[0].Error)
+	require.Equal(t, "connection refused", states[0].LastError)
+	require.False(t, states[0].LastFailure.IsZero())
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c := health.New(10 * time.Millisecond)
+
+	block := make(chan struct{})
+	defer close(block)
+	c.Register("database", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+
+	start := time.Now()
+	states, healthy := c.Check(context.Background())
+	require.Less(t, time.Since(start), time.Second)
+	require.False(t, healthy)
+	require.Equal(t, "timed out after 10ms", states[0].Error)
+}
+
+func TestStates(t *testing.T) {
+	c := health.New(time.Second)
+	c.Register("database", func(ctx context.Context) error { return nil })
+
+	states := c.States()
+	require.Len(t, states, 1)
+	require.False(t, states[0].Healthy)
+	require.True(t, states[0].CheckedAt.IsZero())
+}
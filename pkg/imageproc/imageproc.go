@@ -0,0 +1,146 @@
+package imageproc
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // gif decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp" // webp decoder
+)
+
+type Size struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// DefaultSizes are used when no sizes are configured.
+var DefaultSizes = []Size{
+	{Name: "thumbnail", Width: 150, Height: 150},
+	{Name: "medium", Width: 600, Height: 400},
+	{Name: "large", Width: 1200, Height: 800},
+}
+
+// DefaultFormats are used when no formats are configured. They only name
+// formats the standard library can encode.
+var DefaultFormats = []string{"jpeg"}
+
+type Format struct {
+	Name        string
+	ContentType string
+	Ext         string
+	Encode      func(w io.Writer, img image.Image, quality int) error
+}
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]*Format{
+		"jpeg": {
+			Name:        "jpeg",
+			ContentType: "image/jpeg",
+			Ext:         ".jpg",
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+			},
+		},
+		"png": {
+			Name:        "png",
+			ContentType: "image/png",
+			Ext:         ".png",
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return png.Encode(w, img)
+			},
+		},
+	}
+)
+
+// RegisterFormat makes an output format available to Render. The standard
+// library has no WebP encoder, so "webp" is only produced once a build
+// registers one (for example a cgo libwebp binding). Until then "webp" is
+// best left out of the configured formats.
+func RegisterFormat(f *Format) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+	formats[f.Name] = f
+}
+
+// LookupFormat returns a registered output format by name.
+func LookupFormat(name string) (*Format, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	f, ok := formats[name]
+	return f, ok
+}
+
+// Decode reads an image and rotates it according to its EXIF orientation
+// tag. The decoded image carries no metadata, so anything encoded from it
+// is stripped of EXIF (camera, GPS location ...).
+func Decode(r io.Reader) (image.Image, error) {
+	return imaging.Decode(r, imaging.AutoOrientation(true))
+}
+
+// Render scales img to cover size and crops the overflow around the
+// center, so the aspect ratio of the source is never distorted. Images
+// smaller than size are not upscaled.
+func Render(img image.Image, size Size, format *Format, quality int) ([]byte, image.Point, error) {
+	b := img.Bounds()
+	width, height := size.Width, size.Height
+	if b.Dx() < width || b.Dy() < height {
+		scale := minFloat(float64(b.Dx())/float64(width), float64(b.Dy())/float64(height))
+		width = int(float64(width) * scale)
+		height = int(float64(height) * scale)
+	}
+	if width < 1 || height < 1 {
+		return nil, image.Point{}, fmt.Errorf("imageproc: image too small for %s", size.Name)
+	}
+
+	dst := imaging.Fill(img, width, height, imaging.Center, imaging.Lanczos)
+
+	var buf bytes.Buffer
+	if err := format.Encode(&buf, dst, quality); err != nil {
+		return nil, image.Point{}, err
+	}
+
+	return buf.Bytes(), image.Pt(width, height), nil
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// ParseSizes parses a list such as "thumbnail:150x150,medium:600x400".
+func ParseSizes(s string) ([]Size, error) {
+	result := make([]Size, 0)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var size Size
+		name, dim, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("imageproc: invalid size %q", part)
+		}
+		size.Name = name
+		if _, err := fmt.Sscanf(dim, "%dx%d", &size.Width, &size.Height); err != nil {
+			return nil, fmt.Errorf("imageproc: invalid size %q: %w", part, err)
+		}
+		if size.Width < 1 || size.Height < 1 {
+			return nil, fmt.Errorf("imageproc: invalid size %q", part)
+		}
+		result = append(result, size)
+	}
+
+	return result, nil
+}
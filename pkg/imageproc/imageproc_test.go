@@ -0,0 +1,61 @@
+package imageproc_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/samandar2605/post/pkg/imageproc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+	for x := 0; x < 800; x++ {
+		src.Set(x, 200, color.White)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+
+	img, err := imageproc.Decode(&buf)
+	require.NoError(t, err)
+
+	jpeg, ok := imageproc.LookupFormat("jpeg")
+	require.True(t, ok)
+
+	data, dim, err := imageproc.Render(img, imageproc.Size{Name: "thumbnail", Width: 150, Height: 150}, jpeg, 80)
+	require.NoError(t, err)
+	require.Equal(t, image.Pt(150, 150), dim)
+
+	out, format, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, "jpeg", format)
+	require.Equal(t, 150, out.Bounds().Dx())
+
+	// never upscale, keep the requested aspect ratio instead
+	_, dim, err = imageproc.Render(img, imageproc.Size{Name: "large", Width: 1200, Height: 800}, jpeg, 80)
+	require.NoError(t, err)
+	require.Equal(t, image.Pt(600, 400), dim)
+}
+
+func TestParseSizes(t *testing.T) {
+	sizes, err := imageproc.ParseSizes("thumbnail:150x150, medium:600x400")
+	require.NoError(t, err)
+	require.Equal(t, []imageproc.Size{
+		{Name: "thumbnail", Width: 150, Height: 150},
+		{Name: "medium", Width: 600, Height: 400},
+	}, sizes)
+
+	_, err = imageproc.ParseSizes("thumbnail")
+	require.Error(t, err)
+}
+
+func TestDefaultFormatsHaveEncoders(t *testing.T) {
+	for _, name := range imageproc.DefaultFormats {
+		_, ok := imageproc.LookupFormat(name)
+		require.True(t, ok, name)
+	}
+}
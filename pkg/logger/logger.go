@@ -0,0 +1,141 @@
+// Package logger sets up structured JSON logging. Attributes that look like
+// secrets are masked and the request id stored in a context is added to
+// every record logged with that context, along with its trace and span ids.
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of secrets in logs and config dumps.
+const Redacted = "[redacted]"
+
+// secretWords mark attribute keys and struct fields holding secrets, they
+// are matched against the lower case name without separators.
+var secretWords = []string{"password", "secret", "token", "accesskey", "apikey", "authorization"}
+
+// New returns a logger writing JSON lines to w. level is debug, info, warn
+// or error.
+func New(w io.Writer, level string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	h := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: l,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if IsSecret(a.Key) && a.Value.Kind() == slog.KindString && a.Value.String() != "" {
+				a.Value = slog.StringValue(Redacted)
+			}
+			return a
+		},
+	})
+	return slog.New(contextHandler{h}), nil
+}
+
+// IsSecret reports whether a key or field name refers to a secret.
+func IsSecret(name string) bool {
+	name = strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+	for _, w := range secretWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+	return false
+}
+
+type requestIdKey struct{}
+
+// WithRequestId stores the id of the request being served in ctx.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the id stored by WithRequestId, or "".
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request id and the trace of the context to
+// records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestId(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// Redact turns a struct into nested maps keyed by field name with the
+// non-empty secrets replaced by Redacted, for logging or printing
+// configuration. Durations are written the way they are configured.
+func Redact(v interface{}) interface{} {
+	return redact(reflect.ValueOf(v))
+}
+
+func redact(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		result := make(map[string]interface{}, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			field := v.Field(i)
+			if IsSecret(f.Name) && !field.IsZero() {
+				result[f.Name] = Redacted
+				continue
+			}
+			result[f.Name] = redact(field)
+		}
+		return result
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, v.Len())
+		for i := range result {
+			result[i] = redact(v.Index(i))
+		}
+		return result
+	}
+	return v.Interface()
+}
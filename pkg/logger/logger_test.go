@@ -0,0 +1,133 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/logger"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r map[string]interface{}
+		require.NoError(t, dec.Decode(&r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestNewLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(&buf, "warn")
+	require.NoError(t, err)
+
+	log.Info("hidden")
+	log.Warn("shown")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 1)
+	require.Equal(t, "shown", records[0]["msg"])
+
+	_, err = logger.New(&buf, "loud")
+	require.Error(t, err)
+}
+
+func TestRequestId(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(&buf, "info")
+	require.NoError(t, err)
+
+	ctx := logger.WithRequestId(context.Background(), "abc")
+	require.Equal(t, "abc", logger.RequestId(ctx))
+	require.Equal(t, "", logger.RequestId(context.Background()))
+
+	log.With("component", "api").InfoContext(ctx, "request")
+	log.Info("no request")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 2)
+	require.Equal(t, "abc", records[0]["request_id"])
+	require.Equal(t, "api", records[0]["component"])
+	require.NotContains(t, records[1], "request_id")
+}
+
+func TestSecretAttrsAreMasked(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(&buf, "info")
+	require.NoError(t, err)
+
+	log.Info("login", "user", "bob", "password", "hunter2", "api_key", "k", "token", "")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 1)
+	require.Equal(t, "bob", records[0]["user"])
+	require.Equal(t, logger.Redacted, records[0]["password"])
+	require.Equal(t, logger.Redacted, records[0]["api_key"])
+	require.Equal(t, "", records[0]["token"])
+}
+
+func TestRedact(t *testing.T) {
+	type db struct {
+		Host     string
+		Password string
+	}
+	type config struct {
+		Port          string
+		Timeout       time.Duration
+		Postgres      db
+		Replicas      []db
+		GatewaySecret string
+		Keywords      []string
+	}
+
+	got := logger.Redact(config{
+		Port:     "8000",
+		Timeout:  5 * time.Second,
+		Postgres: db{Host: "localhost", Password: "pg"},
+		Replicas: []db{{Host: "replica"}},
+		Keywords: []string{"spam"},
+	})
+
+	require.Equal(t, map[string]interface{}{
+		"Port":    "8000",
+		"Timeout": "5s",
+		"Postgres": map[string]interface{}{
+			"Host":     "localhost",
+			"Password": logger.Redacted,
+		},
+		"Replicas": []interface{}{
+			map[string]interface{}{"Host": "replica", "Password": ""},
+		},
+		"GatewaySecret": "",
+		"Keywords":      []interface{}{"spam"},
+	}, got)
+}
+
+func TestTraceIds(t *testing.T) {
+	var buf bytes.Buffer
+	log, err := logger.New(&buf, "info")
+	require.NoError(t, err)
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	}))
+
+	log.InfoContext(ctx, "traced")
+	log.Info("untraced")
+
+	records := decode(t, &buf)
+	require.Len(t, records, 2)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", records[0]["trace_id"])
+	require.Equal(t, "00f067aa0ba902b7", records[0]["span_id"])
+	require.NotContains(t, records[1], "trace_id")
+}
@@ -0,0 +1,150 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	wordsPerMinute   = 200
+	excerptMaxLength = 280
+)
+
+type TocEntry struct {
+	Level int
+	Title string
+	Id    string
+}
+
+type Document struct {
+	Html string
+	Toc  []TocEntry
+	// ReadingTime is the estimated reading time in minutes.
+	ReadingTime int
+	Excerpt     string
+}
+
+var (
+	md = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+
+	policy = newPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// fenced code blocks keep their language so the client can highlight them
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#.-]+$`)).OnElements("code")
+	// heading ids are the anchors the table of contents links to
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[\w-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	return p
+}
+
+// Render converts CommonMark + GFM source into sanitized HTML and collects
+// the metadata shown next to a post.
+func Render(source string) (*Document, error) {
+	src := []byte(source)
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	var buf bytes.Buffer
+	if err := md.Renderer().Render(&buf, src, doc); err != nil {
+		return nil, err
+	}
+
+	result := &Document{
+		Html: policy.Sanitize(buf.String()),
+		Toc:  make([]TocEntry, 0),
+	}
+
+	var (
+		words     int
+		paragraph string
+	)
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Heading:
+			id, _ := node.AttributeString("id")
+			idStr, _ := id.([]byte)
+			result.Toc = append(result.Toc, TocEntry{
+				Level: node.Level,
+				Title: plainText(node, src),
+				Id:    string(idStr),
+			})
+		case *ast.Paragraph:
+			if paragraph == "" {
+				paragraph = plainText(node, src)
+			}
+		case *ast.Text:
+			words += len(strings.Fields(string(node.Segment.Value(src))))
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := node.Lines()
+			for i := 0; i < lines.Len(); i++ {
+				line := lines.At(i)
+				words += len(strings.Fields(string(line.Value(src))))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.ReadingTime = (words + wordsPerMinute - 1) / wordsPerMinute
+	if result.ReadingTime < 1 {
+		result.ReadingTime = 1
+	}
+	result.Excerpt = truncate(paragraph, excerptMaxLength)
+
+	return result, nil
+}
+
+func plainText(n ast.Node, src []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := c.(type) {
+		case *ast.Text:
+			b.Write(t.Segment.Value(src))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(t.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.TrimSpace(b.String())
+}
+
+// truncate shortens s to at most max bytes, cutting at a word boundary.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	if i := strings.LastIndexByte(s[:cut], ' '); i > 0 {
+		cut = i
+	}
+	return strings.TrimRight(s[:cut], " ,.;:") + "…"
+}
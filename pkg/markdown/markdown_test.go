@@ -0,0 +1,42 @@
+package markdown_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/samandar2605/post/pkg/markdown"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	doc, err := markdown.Render("# Hello world\n\n" +
+		"First *paragraph* here.\n\n" +
+		"## Code\n\n" +
+		"```go\nfmt.Println(1)\n```\n\n" +
+		"| a | b |\n|---|---|\n| 1 | 2 |\n\n" +
+		"<script>alert(1)</script>\n\n" +
+		"[x](javascript:alert(1))\n")
+	require.NoError(t, err)
+
+	require.Contains(t, doc.Html, `<h1 id="hello-world">Hello world</h1>`)
+	require.Contains(t, doc.Html, `<code class="language-go">`)
+	require.Contains(t, doc.Html, `<table>`)
+	require.NotContains(t, doc.Html, "<script>")
+	require.NotContains(t, doc.Html, "javascript:")
+
+	require.Equal(t, []markdown.TocEntry{
+		{Level: 1, Title: "Hello world", Id: "hello-world"},
+		{Level: 2, Title: "Code", Id: "code"},
+	}, doc.Toc)
+	require.Equal(t, "First paragraph here.", doc.Excerpt)
+	require.Equal(t, 1, doc.ReadingTime)
+}
+
+func TestRenderLong(t *testing.T) {
+	doc, err := markdown.Render(strings.Repeat("word ", 450))
+	require.NoError(t, err)
+
+	require.Equal(t, 3, doc.ReadingTime)
+	require.True(t, strings.HasSuffix(doc.Excerpt, "…"))
+	require.LessOrEqual(t, len(doc.Excerpt), 283)
+}
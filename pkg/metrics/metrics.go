@@ -0,0 +1,150 @@
+// Package metrics collects Prometheus metrics of the api: HTTP requests,
+// repository calls, database pool usage and content created. A nil
+// *Metrics records nothing.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        prometheus.Gauge
+
+	calls        *prometheus.HistogramVec
+	callErrors   *prometheus.CounterVec
+	postsCreated prometheus.Counter
+	comments     prometheus.Counter
+	reactions    prometheus.Counter
+}
+
+// New registers all metrics, along with the Go runtime and process
+// collectors, on a new registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by route and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+		calls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "storage_call_duration_seconds",
+			Help:    "Latency of repository calls.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"repository", "method"}),
+		callErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "storage_call_errors_total",
+			Help: "Repository calls that failed, not counting missing rows.",
+		}, []string{"repository", "method"}),
+		postsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blog_posts_created_total",
+			Help: "Posts created.",
+		}),
+		comments: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blog_comments_created_total",
+			Help: "Comments created.",
+		}),
+		reactions: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blog_reactions_created_total",
+			Help: "Likes and other reactions created.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.calls,
+		m.callErrors,
+		m.postsCreated,
+		m.comments,
+		m.reactions,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports the connection pool stats of db, name tells pools
+// apart.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	if m == nil {
+		return nil
+	}
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RequestStarted counts a request as in flight and returns the function to
+// call once it was served. route is the matched route pattern rather than
+// the path, to keep the number of series bounded.
+func (m *Metrics) RequestStarted() func(method, route string, status int) {
+	if m == nil {
+		return func(string, string, int) {}
+	}
+
+	start := time.Now()
+	m.inFlight.Inc()
+	return func(method, route string, status int) {
+		m.inFlight.Dec()
+		m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+		m.requestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveCall times a repository call, it implements storage.Observer.
+func (m *Metrics) ObserveCall(repository, method string) func(err error) {
+	if m == nil {
+		return func(error) {}
+	}
+
+	start := time.Now()
+	return func(err error) {
+		m.calls.WithLabelValues(repository, method).Observe(time.Since(start).Seconds())
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			m.callErrors.WithLabelValues(repository, method).Inc()
+		}
+	}
+}
+
+func (m *Metrics) PostCreated() {
+	if m != nil {
+		m.postsCreated.Inc()
+	}
+}
+
+func (m *Metrics) CommentCreated() {
+	if m != nil {
+		m.comments.Inc()
+	}
+}
+
+func (m *Metrics) ReactionCreated() {
+	if m != nil {
+		m.reactions.Inc()
+	}
+}
@@ -0,0 +1,69 @@
+package metrics_test
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/samandar2605/post/pkg/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestRequests(t *testing.T) {
+	m := metrics.New()
+
+	done := m.RequestStarted()
+	require.Contains(t, scrape(t, m), "http_requests_in_flight 1")
+	done("GET", "/v1/post/:id", 200)
+
+	body := scrape(t, m)
+	require.Contains(t, body, "http_requests_in_flight 0")
+	require.Contains(t, body, `http_requests_total{method="GET",route="/v1/post/:id",status="200"} 1`)
+	require.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/v1/post/:id"} 1`)
+}
+
+func TestObserveCall(t *testing.T) {
+	m := metrics.New()
+
+	m.ObserveCall("post", "Get")(nil)
+	m.ObserveCall("post", "Get")(sql.ErrNoRows)
+	m.ObserveCall("post", "GetAll")(errors.New("connection refused"))
+
+	body := scrape(t, m)
+	require.Contains(t, body, `storage_call_duration_seconds_count{method="Get",repository="post"} 2`)
+	require.Contains(t, body, `storage_call_errors_total{method="GetAll",repository="post"} 1`)
+	require.NotContains(t, body, `storage_call_errors_total{method="Get",repository="post"}`)
+}
+
+func TestBusinessCounters(t *testing.T) {
+	m := metrics.New()
+
+	m.PostCreated()
+	m.CommentCreated()
+	m.CommentCreated()
+	m.ReactionCreated()
+
+	body := scrape(t, m)
+	require.Contains(t, body, "blog_posts_created_total 1")
+	require.Contains(t, body, "blog_comments_created_total 2")
+	require.Contains(t, body, "blog_reactions_created_total 1")
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *metrics.Metrics
+
+	m.RequestStarted()("GET", "/", 200)
+	m.ObserveCall("post", "Get")(nil)
+	m.PostCreated()
+	require.NoError(t, m.RegisterDB(nil, "primary"))
+}
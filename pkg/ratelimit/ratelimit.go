@@ -0,0 +1,114 @@
+// Package ratelimit limits how fast clients may send requests with token
+// buckets: a client may send Burst requests at once and Rate requests per
+// second after that.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Window is how long an empty bucket takes to fill up again.
+func (l Limit) Window() time.Duration {
+	return seconds(float64(l.Burst) / l.Rate)
+}
+
+type Result struct {
+	Allowed bool
+	// Remaining is how many requests may be sent right away.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed, zero when
+	// this one was.
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets of all clients. Memory keeps them in the process;
+// when several instances of the api run behind a load balancer a shared
+// implementation, e.g. on Redis, makes them enforce one limit together.
+type Store interface {
+	// Take removes a token from the bucket of key, which holds limit, as
+	// of now.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// Memory is a Store for a single instance. Buckets that filled up again are
+// dropped from time to time.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket)}
+}
+
+const sweepInterval = time.Minute
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	burst := float64(limit.Burst)
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		m.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*limit.Rate)
+		b.updated = now
+	}
+
+	var res Result
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = seconds((burst - b.tokens) / limit.Rate)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// sweep drops the buckets that are full by now, they are recreated full.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
+
+// Len returns the number of buckets kept.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
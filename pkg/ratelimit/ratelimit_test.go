@@ -0,0 +1,61 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTake(t *testing.T) {
+	m := ratelimit.NewMemory()
+	limit := ratelimit.Limit{Rate: 2, Burst: 3}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	for i := 2; i >= 0; i-- {
+		res, err := m.Take(ctx, "ip:1.2.3.4", limit, now)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, i, res.Remaining)
+	}
+
+	res, err := m.Take(ctx, "ip:1.2.3.4", limit, now)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+	require.Equal(t, 1500*time.Millisecond, res.Reset)
+
+	res, err = m.Take(ctx, "ip:5.6.7.8", limit, now)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+
+	res, err = m.Take(ctx, "ip:1.2.3.4", limit, now.Add(500*time.Millisecond))
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	require.Zero(t, res.RetryAfter)
+}
+
+func TestBucketsAreSwept(t *testing.T) {
+	m := ratelimit.NewMemory()
+	limit := ratelimit.Limit{Rate: 1, Burst: 5}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	_, err := m.Take(ctx, "a", limit, now)
+	require.NoError(t, err)
+	_, err = m.Take(ctx, "b", limit, now.Add(59500*time.Millisecond))
+	require.NoError(t, err)
+	require.Equal(t, 2, m.Len())
+
+	_, err = m.Take(ctx, "c", limit, now.Add(60*time.Second))
+	require.NoError(t, err)
+	require.Equal(t, 2, m.Len())
+}
+
+func TestWindow(t *testing.T) {
+	require.Equal(t, 5*time.Second, ratelimit.Limit{Rate: 2, Burst: 10}.Window())
+}
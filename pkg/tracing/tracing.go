@@ -0,0 +1,109 @@
+// Package tracing sets up OpenTelemetry tracing of the api. Spans are
+// exported over OTLP/HTTP or written to stdout for local runs, and trace
+// context is propagated with the W3C traceparent and baggage headers.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type Config struct {
+	// Exporter is "otlp", "stdout" or "none". With "none" no spans are
+	// recorded, incoming trace context is still passed on.
+	Exporter    string
+	ServiceName string
+	// Endpoint is the url of the OTLP/HTTP collector, e.g.
+	// http://localhost:4318. When empty the OTEL_EXPORTER_OTLP_* variables
+	// or the exporter defaults apply.
+	Endpoint string
+	// SampleRatio is the fraction of traces started here that are sampled,
+	// traces started by a caller follow its decision.
+	SampleRatio float64
+	// Writer receives the spans of the stdout exporter, os.Stdout if nil.
+	Writer io.Writer
+}
+
+// New installs the global tracer provider and propagator. The returned
+// function flushes pending spans and stops the exporter.
+func New(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case "none", "":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		w := cfg.Writer
+		if w == nil {
+			w = os.Stdout
+		}
+		e, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, err
+		}
+		exporter = e
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		e, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		exporter = e
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+var (
+	sqlStringRegex = regexp.MustCompile(`'(?:[^']|'')*'`)
+	// sqlNumberRegex matches numbers that are not part of an identifier;
+	// placeholders like $1 are matched too and put back as they were.
+	sqlNumberRegex = regexp.MustCompile(`\$?\b\d+(?:\.\d+)?\b`)
+)
+
+// SanitizeSQL replaces the string and number literals of a statement with
+// ? so that no values end up in traces, and collapses whitespace.
+func SanitizeSQL(query string) string {
+	query = sqlStringRegex.ReplaceAllString(query, "?")
+	query = sqlNumberRegex.ReplaceAllStringFunc(query, func(s string) string {
+		if strings.HasPrefix(s, "$") {
+			return s
+		}
+		return "?"
+	})
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -0,0 +1,62 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/samandar2605/post/pkg/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStdoutExporter(t *testing.T) {
+	var buf bytes.Buffer
+	shutdown, err := tracing.New(context.Background(), tracing.Config{
+		Exporter:    "stdout",
+		ServiceName: "post-test",
+		SampleRatio: 1,
+		Writer:      &buf,
+	})
+	require.NoError(t, err)
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	_, span := otel.Tracer("test").Start(ctx, "GET /v1/post/:id")
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	span.End()
+
+	require.NoError(t, shutdown(context.Background()))
+	require.Contains(t, buf.String(), "GET /v1/post/:id")
+	require.Contains(t, buf.String(), "post-test")
+}
+
+func TestNoneExporter(t *testing.T) {
+	shutdown, err := tracing.New(context.Background(), tracing.Config{Exporter: "none"})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	header := http.Header{}
+	header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header))
+	require.True(t, trace.SpanContextFromContext(ctx).IsRemote())
+}
+
+func TestUnknownExporter(t *testing.T) {
+	_, err := tracing.New(context.Background(), tracing.Config{Exporter: "zipkin"})
+	require.Error(t, err)
+}
+
+func TestSanitizeSQL(t *testing.T) {
+	require.Equal(t,
+		"SELECT id FROM posts WHERE title ILIKE $1 AND status = ? AND views > ? LIMIT ?",
+		tracing.SanitizeSQL(`SELECT id FROM posts
+			WHERE title ILIKE $1 AND status = 'it''s' AND views > 10.5 LIMIT 20`),
+	)
+	require.Equal(t, "UPDATE users2 SET x=$12", tracing.SanitizeSQL("UPDATE users2 SET x=$12"))
+}
@@ -0,0 +1,446 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/samandar2605/post/pkg/cache"
+	"github.com/samandar2605/post/storage/repo"
+	"golang.org/x/sync/singleflight"
+)
+
+type CacheOptions struct {
+	// TTL is how long a single post or category is kept.
+	TTL time.Duration
+	// ListTTL is how long a page of posts or categories is kept.
+	ListTTL time.Duration
+}
+
+// WithCache decorates strg so that posts and categories are read through c.
+// Writes made through the returned storage evict what they changed once
+// their transaction committed; pages are evicted all at once by moving
+// their collection to a new generation. Concurrent loads of the same key
+// are coalesced into one query. Reads inside WithTx bypass the cache.
+//
+// With an in-process cache every instance of the api only sees its own
+// writes, others serve what they cached until it expires.
+func WithCache(strg StorageI, c cache.Cache, opts CacheOptions) StorageI {
+	return &cachedStorage{
+		next: strg,
+		rc:   &readCache{c: c, ttl: opts.TTL, listTTL: opts.ListTTL},
+		ctx:  context.Background(),
+	}
+}
+
+type readCache struct {
+	c       cache.Cache
+	ttl     time.Duration
+	listTTL time.Duration
+	loads   singleflight.Group
+	gen     atomic.Int64
+	// evictions counts the evictions run, a load that saw it change may
+	// have read what was evicted meanwhile.
+	evictions atomic.Int64
+}
+
+const (
+	postsCollection      = "posts"
+	categoriesCollection = "categories"
+)
+
+type cachedStorage struct {
+	next StorageI
+	rc   *readCache
+	ctx  context.Context
+	// evictions is set inside WithTx, evictions are run after the
+	// transaction committed then.
+	evictions *[]func()
+}
+
+func (s *cachedStorage) WithTx(fn func(strg StorageI) error) error {
+	if s.evictions != nil {
+		return s.next.WithTx(func(tx StorageI) error {
+			return fn(&cachedStorage{next: tx, rc: s.rc, ctx: s.ctx, evictions: s.evictions})
+		})
+	}
+
+	var evictions []func()
+	err := s.next.WithTx(func(tx StorageI) error {
+		return fn(&cachedStorage{next: tx, rc: s.rc, ctx: s.ctx, evictions: &evictions})
+	})
+	if err != nil {
+		return err
+	}
+	for _, evict := range evictions {
+		evict()
+	}
+	return nil
+}
+
+func (s *cachedStorage) WithContext(ctx context.Context) StorageI {
+	return &cachedStorage{next: s.next.WithContext(ctx), rc: s.rc, ctx: ctx}
+}
+
+func (s *cachedStorage) Category() repo.CategoryStorageI {
+	return &cachedCategory{next: s.next.Category(), s: s}
+}
+
+func (s *cachedStorage) Comment() repo.CommentStorageI {
+	return s.next.Comment()
+}
+
+func (s *cachedStorage) User() repo.UserStorageI {
+	return &cachedUser{UserStorageI: s.next.User(), s: s}
+}
+
+func (s *cachedStorage) Post() repo.PostStorageI {
+	return &cachedPost{next: s.next.Post(), s: s}
+}
+
+func (s *cachedStorage) Like() repo.LikeStorageI {
+	return s.next.Like()
+}
+
+func (s *cachedStorage) Media() repo.MediaStorageI {
+	return s.next.Media()
+}
+
+func (s *cachedStorage) Sitemap() repo.SitemapStorageI {
+	return s.next.Sitemap()
+}
+
+func (s *cachedStorage) Setting() repo.SettingStorageI {
+	return s.next.Setting()
+}
+
+func (s *cachedStorage) Report() repo.ReportStorageI {
+	return s.next.Report()
+}
+
+func (s *cachedStorage) Trash() repo.TrashStorageI {
+	return &cachedTrash{TrashStorageI: s.next.Trash(), s: s}
+}
+
+func (s *cachedStorage) Audit() repo.AuditStorageI {
+	return s.next.Audit()
+}
+
+// evict removes keys and, when collection is set, all cached pages of it,
+// right away or once the transaction committed.
+func (s *cachedStorage) evict(collection string, keys ...string) {
+	evict := func() {
+		s.rc.evictions.Add(1)
+		for _, key := range keys {
+			s.rc.loads.Forget(key)
+		}
+		if collection != "" {
+			// a missing generation is replaced by a new one
+			keys = append(keys, generationKey(collection))
+		}
+		// a failed eviction leaves the entries until they expire
+		_ = s.rc.c.Delete(s.ctx, keys...)
+	}
+	if s.evictions != nil {
+		*s.evictions = append(*s.evictions, evict)
+		return
+	}
+	evict()
+}
+
+func generationKey(collection string) string {
+	return collection + ":generation"
+}
+
+// listKey is the key of a page of collection, within its current
+// generation.
+func (s *cachedStorage) listKey(collection string, query interface{}) (string, error) {
+	q, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+
+	key := generationKey(collection)
+	gen, ok, err := s.rc.c.Get(s.ctx, key)
+	if err != nil || !ok {
+		gen = []byte(strconv.FormatInt(time.Now().UnixNano(), 36) + "." + strconv.FormatInt(s.rc.gen.Add(1), 36))
+		if err == nil {
+			_ = s.rc.c.Set(s.ctx, key, gen, 0)
+		}
+	}
+	return collection + ":" + string(gen) + ":" + string(q), nil
+}
+
+// ttlFunc returns how long a value loaded from strg may be kept.
+type ttlFunc func(strg StorageI) (time.Duration, error)
+
+func fixedTTL(ttl time.Duration) ttlFunc {
+	return func(StorageI) (time.Duration, error) {
+		return ttl, nil
+	}
+}
+
+// load returns the value cached under key, or loads it with fn and caches
+// it for as long as ttl tells. Callers loading the same key at the same
+// time share one call of fn. Errors of the cache are treated as misses, a
+// value whose ttl can not be told is returned without caching it.
+//
+// Values are loaded from the primary: a replica that is behind would put
+// what was just evicted back into the cache. For the same reason a value is
+// not kept when an eviction ran while it was loaded; Forget only keeps new
+// callers from sharing a load that is already running.
+func load[T any](s *cachedStorage, key string, ttl ttlFunc, fn func(strg StorageI) (*T, error)) (*T, error) {
+	if s.evictions != nil {
+		return fn(s.next)
+	}
+
+	if data, ok, err := s.rc.c.Get(s.ctx, key); err == nil && ok {
+		v := new(T)
+		if err := json.Unmarshal(data, v); err == nil {
+			return v, nil
+		}
+	}
+
+	data, err, _ := s.rc.loads.Do(key, func() (interface{}, error) {
+		evictions := s.rc.evictions.Load()
+		strg := s.next.WithContext(ReadPrimary(s.ctx))
+		v, err := fn(strg)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		keep, err := ttl(strg)
+		if err != nil {
+			return data, nil
+		}
+		_ = s.rc.c.Set(s.ctx, key, data, keep)
+		if s.rc.evictions.Load() != evictions {
+			// the eviction may have deleted key before it was set
+			_ = s.rc.c.Delete(s.ctx, key)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// every caller decodes its own copy
+	v := new(T)
+	return v, json.Unmarshal(data.([]byte), v)
+}
+
+func loadList[T any](s *cachedStorage, collection string, query interface{}, ttl ttlFunc, fn func(strg StorageI) (*T, error)) (*T, error) {
+	if s.evictions != nil {
+		return fn(s.next)
+	}
+	key, err := s.listKey(collection, query)
+	if err != nil {
+		return nil, err
+	}
+	return load(s, key, ttl, fn)
+}
+
+// untilBanExpiry is the ttl of pages that leave out posts of banned
+// authors. It is capped at the end of the next timed ban, a page would keep
+// hiding the posts of the author after it ended otherwise; no eviction
+// runs when a ban simply expires.
+func (s *cachedStorage) untilBanExpiry(strg StorageI) (time.Duration, error) {
+	expiresAt, err := strg.User().GetNextBanExpiry()
+	if err != nil || expiresAt == nil {
+		return s.rc.listTTL, err
+	}
+	// the ban may have ended since it was queried, zero would keep the
+	// page for good
+	ttl := max(time.Until(*expiresAt), time.Millisecond)
+	if s.rc.listTTL > 0 {
+		ttl = min(ttl, s.rc.listTTL)
+	}
+	return ttl, nil
+}
+
+func postKey(id int) string {
+	return "post:" + strconv.Itoa(id)
+}
+
+func categoryKey(id int) string {
+	return "category:" + strconv.Itoa(id)
+}
+
+type cachedPost struct {
+	next repo.PostStorageI
+	s    *cachedStorage
+}
+
+func (c *cachedPost) Create(p *repo.Post) (*repo.Post, error) {
+	post, err := c.next.Create(p)
+	if err == nil {
+		c.s.evict(postsCollection)
+	}
+	return post, err
+}
+
+func (c *cachedPost) Get(id int) (*repo.Post, error) {
+	return load(c.s, postKey(id), fixedTTL(c.s.rc.ttl), func(strg StorageI) (*repo.Post, error) {
+		return strg.Post().Get(id)
+	})
+}
+
+func (c *cachedPost) GetAll(param repo.GetPostQuery) (*repo.GetAllPostResult, error) {
+	ttl := fixedTTL(c.s.rc.listTTL)
+	if param.HideBannedAuthors {
+		ttl = c.s.untilBanExpiry
+	}
+	return loadList(c.s, postsCollection, param, ttl, func(strg StorageI) (*repo.GetAllPostResult, error) {
+		return strg.Post().GetAll(param)
+	})
+}
+
+func (c *cachedPost) Update(usr *repo.Post) (*repo.Post, error) {
+	post, err := c.next.Update(usr)
+	if err == nil {
+		c.s.evict(postsCollection, postKey(usr.Id))
+	}
+	return post, err
+}
+
+func (c *cachedPost) Patch(id int, p *repo.PostPatch) (*repo.Post, error) {
+	post, err := c.next.Patch(id, p)
+	if err == nil {
+		c.s.evict(postsCollection, postKey(id))
+	}
+	return post, err
+}
+
+func (c *cachedPost) Delete(id, version int) error {
+	err := c.next.Delete(id, version)
+	if err == nil {
+		c.s.evict(postsCollection, postKey(id))
+	}
+	return err
+}
+
+func (c *cachedPost) UpdateStatus(ids []int, status string, moderatorId int) (int, error) {
+	n, err := c.next.UpdateStatus(ids, status, moderatorId)
+	if err == nil {
+		keys := make([]string, 0, len(ids))
+		for _, id := range ids {
+			keys = append(keys, postKey(id))
+		}
+		c.s.evict(postsCollection, keys...)
+	}
+	return n, err
+}
+
+func (c *cachedPost) GetUnrendered(afterId, limit int) ([]*repo.Post, error) {
+	return c.next.GetUnrendered(afterId, limit)
+}
+
+func (c *cachedPost) SetRendered(p *repo.Post) error {
+	err := c.next.SetRendered(p)
+	if err == nil {
+		c.s.evict(postsCollection, postKey(p.Id))
+	}
+	return err
+}
+
+type cachedCategory struct {
+	next repo.CategoryStorageI
+	s    *cachedStorage
+}
+
+func (c *cachedCategory) Create(u *repo.Category) (*repo.Category, error) {
+	category, err := c.next.Create(u)
+	if err == nil {
+		c.s.evict(categoriesCollection)
+	}
+	return category, err
+}
+
+func (c *cachedCategory) Get(id int) (*repo.Category, error) {
+	return load(c.s, categoryKey(id), fixedTTL(c.s.rc.ttl), func(strg StorageI) (*repo.Category, error) {
+		return strg.Category().Get(id)
+	})
+}
+
+func (c *cachedCategory) GetAll(param repo.GetCategoryQuery) (*repo.GetAllCategoriesResult, error) {
+	return loadList(c.s, categoriesCollection, param, fixedTTL(c.s.rc.listTTL), func(strg StorageI) (*repo.GetAllCategoriesResult, error) {
+		return strg.Category().GetAll(param)
+	})
+}
+
+func (c *cachedCategory) GetByIds(ids []int) (map[int]*repo.Category, error) {
+	return c.next.GetByIds(ids)
+}
+
+func (c *cachedCategory) Update(category repo.Category) (*repo.Category, error) {
+	updated, err := c.next.Update(category)
+	if err == nil {
+		c.s.evict(categoriesCollection, categoryKey(category.Id))
+	}
+	return updated, err
+}
+
+func (c *cachedCategory) Patch(id int, p *repo.CategoryPatch) (*repo.Category, error) {
+	category, err := c.next.Patch(id, p)
+	if err == nil {
+		c.s.evict(categoriesCollection, categoryKey(id))
+	}
+	return category, err
+}
+
+func (c *cachedCategory) Delete(id, version int) error {
+	err := c.next.Delete(id, version)
+	if err == nil {
+		c.s.evict(categoriesCollection, categoryKey(id))
+	}
+	return err
+}
+
+// cachedUser evicts the pages of posts when an author is banned, deleted
+// or let back in, they leave out posts of banned authors. Bans that end on
+// their own are covered by untilBanExpiry.
+type cachedUser struct {
+	repo.UserStorageI
+	s *cachedStorage
+}
+
+func (c *cachedUser) Delete(id, version int) error {
+	err := c.UserStorageI.Delete(id, version)
+	if err == nil {
+		c.s.evict(postsCollection)
+	}
+	return err
+}
+
+func (c *cachedUser) SetStatus(change *repo.UserStatusChange) error {
+	err := c.UserStorageI.SetStatus(change)
+	if err == nil {
+		c.s.evict(postsCollection)
+	}
+	return err
+}
+
+type cachedTrash struct {
+	repo.TrashStorageI
+	s *cachedStorage
+}
+
+func (c *cachedTrash) Restore(itemType string, id int) error {
+	err := c.TrashStorageI.Restore(itemType, id)
+	if err != nil {
+		return err
+	}
+	switch itemType {
+	case repo.TrashTypePost:
+		c.s.evict(postsCollection, postKey(id))
+	case repo.TrashTypeCategory:
+		c.s.evict(categoriesCollection, categoryKey(id))
+	case repo.TrashTypeUser:
+		c.s.evict(postsCollection)
+	}
+	return nil
+}
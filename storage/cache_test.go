@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/cache"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStorage struct {
+	StorageI
+	posts *fakePosts
+	users *fakeUsers
+}
+
+func (s *fakeStorage) Post() repo.PostStorageI {
+	return s.posts
+}
+
+func (s *fakeStorage) User() repo.UserStorageI {
+	return s.users
+}
+
+func (s *fakeStorage) WithTx(fn func(strg StorageI) error) error {
+	return fn(s)
+}
+
+func (s *fakeStorage) WithContext(ctx context.Context) StorageI {
+	return s
+}
+
+// fakePosts holds one post. Get reads it and then waits for release when
+// that is set, as a slow query would.
+type fakePosts struct {
+	repo.PostStorageI
+	mu      sync.Mutex
+	post    repo.Post
+	loads   int
+	started chan struct{}
+	release chan struct{}
+}
+
+func (p *fakePosts) Get(id int) (*repo.Post, error) {
+	p.mu.Lock()
+	p.loads++
+	post := p.post
+	p.mu.Unlock()
+
+	if p.release != nil {
+		p.started <- struct{}{}
+		<-p.release
+	}
+	return &post, nil
+}
+
+func (p *fakePosts) GetAll(param repo.GetPostQuery) (*repo.GetAllPostResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loads++
+	post := p.post
+	return &repo.GetAllPostResult{Post: []*repo.Post{&post}, Count: 1}, nil
+}
+
+func (p *fakePosts) Update(post *repo.Post) (*repo.Post, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.post = *post
+	return post, nil
+}
+
+func (p *fakePosts) loadCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loads
+}
+
+// fakeUsers has one timed ban, ending at banExpiry when that is set.
+type fakeUsers struct {
+	repo.UserStorageI
+	banExpiry *time.Time
+}
+
+func (u *fakeUsers) GetNextBanExpiry() (*time.Time, error) {
+	return u.banExpiry, nil
+}
+
+func newCachedStorage() (StorageI, *fakePosts) {
+	strg, posts, _ := newCachedStorageWithUsers()
+	return strg, posts
+}
+
+func newCachedStorageWithUsers() (StorageI, *fakePosts, *fakeUsers) {
+	posts := &fakePosts{post: repo.Post{Id: 1, Title: "old"}}
+	users := &fakeUsers{}
+	strg := WithCache(&fakeStorage{posts: posts, users: users}, cache.NewLRU(100), CacheOptions{
+		TTL:     time.Minute,
+		ListTTL: time.Minute,
+	})
+	return strg, posts, users
+}
+
+func getTitle(t *testing.T, strg StorageI) string {
+	post, err := strg.Post().Get(1)
+	require.NoError(t, err)
+	return post.Title
+}
+
+func TestCacheEvictsOnUpdate(t *testing.T) {
+	strg, posts := newCachedStorage()
+
+	require.Equal(t, "old", getTitle(t, strg))
+	require.Equal(t, "old", getTitle(t, strg))
+	require.Equal(t, 1, posts.loadCount())
+
+	_, err := strg.Post().Update(&repo.Post{Id: 1, Title: "new"})
+	require.NoError(t, err)
+	require.Equal(t, "new", getTitle(t, strg))
+	require.Equal(t, 2, posts.loadCount())
+}
+
+func TestCacheEvictsAfterCommit(t *testing.T) {
+	strg, posts := newCachedStorage()
+	require.Equal(t, "old", getTitle(t, strg))
+
+	err := strg.WithTx(func(tx StorageI) error {
+		_, err := tx.Post().Update(&repo.Post{Id: 1, Title: "new"})
+		require.NoError(t, err)
+		// other requests keep reading the committed post until then
+		require.Equal(t, "old", getTitle(t, strg))
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, posts.loadCount())
+	require.Equal(t, "new", getTitle(t, strg))
+
+	rollback := errors.New("rollback")
+	err = strg.WithTx(func(tx StorageI) error {
+		_, err := tx.Post().Update(&repo.Post{Id: 1, Title: "newer"})
+		require.NoError(t, err)
+		return rollback
+	})
+	require.ErrorIs(t, err, rollback)
+	require.Equal(t, "new", getTitle(t, strg))
+	require.Equal(t, 2, posts.loadCount())
+}
+
+func TestCacheCoalescesLoads(t *testing.T) {
+	strg, posts := newCachedStorage()
+	posts.started = make(chan struct{}, 1)
+	posts.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	titles := make([]string, 5)
+	for i := range titles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			titles[i] = getTitle(t, strg)
+		}()
+	}
+	<-posts.started
+	close(posts.release)
+	wg.Wait()
+
+	require.Equal(t, 1, posts.loadCount())
+	require.Equal(t, []string{"old", "old", "old", "old", "old"}, titles)
+}
+
+func TestCacheDropsLoadRacingEviction(t *testing.T) {
+	strg, posts := newCachedStorage()
+	posts.started = make(chan struct{}, 1)
+	posts.release = make(chan struct{})
+
+	done := make(chan string)
+	go func() {
+		done <- getTitle(t, strg)
+	}()
+	// the load read the old post, the update commits before it returns
+	<-posts.started
+	_, err := strg.Post().Update(&repo.Post{Id: 1, Title: "new"})
+	require.NoError(t, err)
+	close(posts.release)
+	require.Equal(t, "old", <-done)
+
+	require.Equal(t, "new", getTitle(t, strg))
+	require.Equal(t, 2, posts.loadCount())
+}
+
+func TestCachePagesExpireWithBans(t *testing.T) {
+	strg, posts, users := newCachedStorageWithUsers()
+	expiry := time.Now().Add(50 * time.Millisecond)
+	users.banExpiry = &expiry
+
+	getAll := func(hideBanned bool) {
+		_, err := strg.Post().GetAll(repo.GetPostQuery{Page: 1, Limit: 10, HideBannedAuthors: hideBanned})
+		require.NoError(t, err)
+	}
+	getAll(true)
+	getAll(true)
+	getAll(false)
+	getAll(false)
+	require.Equal(t, 2, posts.loadCount())
+
+	// the page leaving out banned authors is reloaded once the ban ended,
+	// the others are kept for the list ttl
+	time.Sleep(time.Until(expiry) + 10*time.Millisecond)
+	users.banExpiry = nil
+	getAll(true)
+	getAll(false)
+	require.Equal(t, 3, posts.loadCount())
+	getAll(true)
+	require.Equal(t, 3, posts.loadCount())
+}
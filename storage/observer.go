@@ -0,0 +1,590 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// Observer is told about every repository call made through a storage
+// returned by WithObserver, e.g. to record metrics. ObserveCall is called
+// before the call and returns a function that receives its error once the
+// call returned.
+type Observer interface {
+	ObserveCall(repository, method string) func(err error)
+}
+
+// WithObserver decorates strg so that o observes all repository calls,
+// including those made inside WithTx.
+func WithObserver(strg StorageI, o Observer) StorageI {
+	return &observedStorage{next: strg, o: o}
+}
+
+type observedStorage struct {
+	next StorageI
+	o    Observer
+}
+
+func (s *observedStorage) WithTx(fn func(strg StorageI) error) error {
+	done := s.o.ObserveCall("storage", "WithTx")
+	err := s.next.WithTx(func(tx StorageI) error {
+		return fn(&observedStorage{next: tx, o: s.o})
+	})
+	done(err)
+	return err
+}
+
+func (s *observedStorage) WithContext(ctx context.Context) StorageI {
+	return &observedStorage{next: s.next.WithContext(ctx), o: s.o}
+}
+
+func (s *observedStorage) Category() repo.CategoryStorageI {
+	return &observedCategory{next: s.next.Category(), o: s.o}
+}
+
+func (s *observedStorage) Comment() repo.CommentStorageI {
+	return &observedComment{next: s.next.Comment(), o: s.o}
+}
+
+func (s *observedStorage) User() repo.UserStorageI {
+	return &observedUser{next: s.next.User(), o: s.o}
+}
+
+func (s *observedStorage) Post() repo.PostStorageI {
+	return &observedPost{next: s.next.Post(), o: s.o}
+}
+
+func (s *observedStorage) Like() repo.LikeStorageI {
+	return &observedLike{next: s.next.Like(), o: s.o}
+}
+
+func (s *observedStorage) Media() repo.MediaStorageI {
+	return &observedMedia{next: s.next.Media(), o: s.o}
+}
+
+func (s *observedStorage) Sitemap() repo.SitemapStorageI {
+	return &observedSitemap{next: s.next.Sitemap(), o: s.o}
+}
+
+func (s *observedStorage) Setting() repo.SettingStorageI {
+	return &observedSetting{next: s.next.Setting(), o: s.o}
+}
+
+func (s *observedStorage) Report() repo.ReportStorageI {
+	return &observedReport{next: s.next.Report(), o: s.o}
+}
+
+func (s *observedStorage) Trash() repo.TrashStorageI {
+	return &observedTrash{next: s.next.Trash(), o: s.o}
+}
+
+func (s *observedStorage) Audit() repo.AuditStorageI {
+	return &observedAudit{next: s.next.Audit(), o: s.o}
+}
+
+// observe runs fn as a call of method on repository.
+func observe[T any](o Observer, repository, method string, fn func() (T, error)) (T, error) {
+	done := o.ObserveCall(repository, method)
+	v, err := fn()
+	done(err)
+	return v, err
+}
+
+func observeErr(o Observer, repository, method string, fn func() error) error {
+	done := o.ObserveCall(repository, method)
+	err := fn()
+	done(err)
+	return err
+}
+
+type observedCategory struct {
+	next repo.CategoryStorageI
+	o    Observer
+}
+
+func (s *observedCategory) Create(u *repo.Category) (*repo.Category, error) {
+	return observe(s.o, "category", "Create", func() (*repo.Category, error) {
+		return s.next.Create(u)
+	})
+}
+
+func (s *observedCategory) Get(id int) (*repo.Category, error) {
+	return observe(s.o, "category", "Get", func() (*repo.Category, error) {
+		return s.next.Get(id)
+	})
+}
+
+func (s *observedCategory) GetAll(param repo.GetCategoryQuery) (*repo.GetAllCategoriesResult, error) {
+	return observe(s.o, "category", "GetAll", func() (*repo.GetAllCategoriesResult, error) {
+		return s.next.GetAll(param)
+	})
+}
+
+func (s *observedCategory) GetByIds(ids []int) (map[int]*repo.Category, error) {
+	return observe(s.o, "category", "GetByIds", func() (map[int]*repo.Category, error) {
+		return s.next.GetByIds(ids)
+	})
+}
+
+func (s *observedCategory) Update(category repo.Category) (*repo.Category, error) {
+	return observe(s.o, "category", "Update", func() (*repo.Category, error) {
+		return s.next.Update(category)
+	})
+}
+
+func (s *observedCategory) Patch(id int, p *repo.CategoryPatch) (*repo.Category, error) {
+	return observe(s.o, "category", "Patch", func() (*repo.Category, error) {
+		return s.next.Patch(id, p)
+	})
+}
+
+func (s *observedCategory) Delete(id, version int) error {
+	return observeErr(s.o, "category", "Delete", func() error {
+		return s.next.Delete(id, version)
+	})
+}
+
+type observedComment struct {
+	next repo.CommentStorageI
+	o    Observer
+}
+
+func (s *observedComment) Create(comment *repo.Comment) (*repo.Comment, error) {
+	return observe(s.o, "comment", "Create", func() (*repo.Comment, error) {
+		return s.next.Create(comment)
+	})
+}
+
+func (s *observedComment) Get(id int) (*repo.Comment, error) {
+	return observe(s.o, "comment", "Get", func() (*repo.Comment, error) {
+		return s.next.Get(id)
+	})
+}
+
+func (s *observedComment) GetAll(param repo.GetCommentQuery) (*repo.GetAllCommentsResult, error) {
+	return observe(s.o, "comment", "GetAll", func() (*repo.GetAllCommentsResult, error) {
+		return s.next.GetAll(param)
+	})
+}
+
+func (s *observedComment) Update(cr *repo.Comment) (*repo.Comment, error) {
+	return observe(s.o, "comment", "Update", func() (*repo.Comment, error) {
+		return s.next.Update(cr)
+	})
+}
+
+func (s *observedComment) Patch(id int, p *repo.CommentPatch) (*repo.Comment, error) {
+	return observe(s.o, "comment", "Patch", func() (*repo.Comment, error) {
+		return s.next.Patch(id, p)
+	})
+}
+
+func (s *observedComment) Delete(id, version int) error {
+	return observeErr(s.o, "comment", "Delete", func() error {
+		return s.next.Delete(id, version)
+	})
+}
+
+func (s *observedComment) UpdateStatus(ids []int, status string, moderatorId int) (int, error) {
+	return observe(s.o, "comment", "UpdateStatus", func() (int, error) {
+		return s.next.UpdateStatus(ids, status, moderatorId)
+	})
+}
+
+func (s *observedComment) CountByUser(userId int, status string) (int, error) {
+	return observe(s.o, "comment", "CountByUser", func() (int, error) {
+		return s.next.CountByUser(userId, status)
+	})
+}
+
+type observedUser struct {
+	next repo.UserStorageI
+	o    Observer
+}
+
+func (s *observedUser) Create(u *repo.User) (*repo.User, error) {
+	return observe(s.o, "user", "Create", func() (*repo.User, error) {
+		return s.next.Create(u)
+	})
+}
+
+func (s *observedUser) Get(id int) (*repo.User, error) {
+	return observe(s.o, "user", "Get", func() (*repo.User, error) {
+		return s.next.Get(id)
+	})
+}
+
+func (s *observedUser) GetByIds(ids []int) (map[int]*repo.User, error) {
+	return observe(s.o, "user", "GetByIds", func() (map[int]*repo.User, error) {
+		return s.next.GetByIds(ids)
+	})
+}
+
+func (s *observedUser) GetAll(param repo.GetUserQuery) (*repo.GetAllUsersResult, error) {
+	return observe(s.o, "user", "GetAll", func() (*repo.GetAllUsersResult, error) {
+		return s.next.GetAll(param)
+	})
+}
+
+func (s *observedUser) Update(usr *repo.User) (*repo.User, error) {
+	return observe(s.o, "user", "Update", func() (*repo.User, error) {
+		return s.next.Update(usr)
+	})
+}
+
+func (s *observedUser) Patch(id int, p *repo.UserPatch) (*repo.User, error) {
+	return observe(s.o, "user", "Patch", func() (*repo.User, error) {
+		return s.next.Patch(id, p)
+	})
+}
+
+func (s *observedUser) Delete(id, version int) error {
+	return observeErr(s.o, "user", "Delete", func() error {
+		return s.next.Delete(id, version)
+	})
+}
+
+func (s *observedUser) SetStatus(change *repo.UserStatusChange) error {
+	return observeErr(s.o, "user", "SetStatus", func() error {
+		return s.next.SetStatus(change)
+	})
+}
+
+func (s *observedUser) GetNextBanExpiry() (*time.Time, error) {
+	return observe(s.o, "user", "GetNextBanExpiry", func() (*time.Time, error) {
+		return s.next.GetNextBanExpiry()
+	})
+}
+
+func (s *observedUser) GetStatusHistory(userId int) ([]*repo.UserStatusChange, error) {
+	return observe(s.o, "user", "GetStatusHistory", func() ([]*repo.UserStatusChange, error) {
+		return s.next.GetStatusHistory(userId)
+	})
+}
+
+type observedPost struct {
+	next repo.PostStorageI
+	o    Observer
+}
+
+func (s *observedPost) Create(p *repo.Post) (*repo.Post, error) {
+	return observe(s.o, "post", "Create", func() (*repo.Post, error) {
+		return s.next.Create(p)
+	})
+}
+
+func (s *observedPost) Get(id int) (*repo.Post, error) {
+	return observe(s.o, "post", "Get", func() (*repo.Post, error) {
+		return s.next.Get(id)
+	})
+}
+
+func (s *observedPost) GetAll(param repo.GetPostQuery) (*repo.GetAllPostResult, error) {
+	return observe(s.o, "post", "GetAll", func() (*repo.GetAllPostResult, error) {
+		return s.next.GetAll(param)
+	})
+}
+
+func (s *observedPost) Update(usr *repo.Post) (*repo.Post, error) {
+	return observe(s.o, "post", "Update", func() (*repo.Post, error) {
+		return s.next.Update(usr)
+	})
+}
+
+func (s *observedPost) Patch(id int, p *repo.PostPatch) (*repo.Post, error) {
+	return observe(s.o, "post", "Patch", func() (*repo.Post, error) {
+		return s.next.Patch(id, p)
+	})
+}
+
+func (s *observedPost) Delete(id, version int) error {
+	return observeErr(s.o, "post", "Delete", func() error {
+		return s.next.Delete(id, version)
+	})
+}
+
+func (s *observedPost) UpdateStatus(ids []int, status string, moderatorId int) (int, error) {
+	return observe(s.o, "post", "UpdateStatus", func() (int, error) {
+		return s.next.UpdateStatus(ids, status, moderatorId)
+	})
+}
+
+func (s *observedPost) GetUnrendered(afterId, limit int) ([]*repo.Post, error) {
+	return observe(s.o, "post", "GetUnrendered", func() ([]*repo.Post, error) {
+		return s.next.GetUnrendered(afterId, limit)
+	})
+}
+
+func (s *observedPost) SetRendered(p *repo.Post) error {
+	return observeErr(s.o, "post", "SetRendered", func() error {
+		return s.next.SetRendered(p)
+	})
+}
+
+type observedLike struct {
+	next repo.LikeStorageI
+	o    Observer
+}
+
+func (s *observedLike) Create(l *repo.Like) (*repo.Like, error) {
+	return observe(s.o, "like", "Create", func() (*repo.Like, error) {
+		return s.next.Create(l)
+	})
+}
+
+func (s *observedLike) Get(id int) (*repo.Like, error) {
+	return observe(s.o, "like", "Get", func() (*repo.Like, error) {
+		return s.next.Get(id)
+	})
+}
+
+func (s *observedLike) GetAll(param repo.GetLikesQuery) (*repo.GetAllLikesResult, error) {
+	return observe(s.o, "like", "GetAll", func() (*repo.GetAllLikesResult, error) {
+		return s.next.GetAll(param)
+	})
+}
+
+func (s *observedLike) Update(usr *repo.Like) (*repo.Like, error) {
+	return observe(s.o, "like", "Update", func() (*repo.Like, error) {
+		return s.next.Update(usr)
+	})
+}
+
+func (s *observedLike) Delete(id int) error {
+	return observeErr(s.o, "like", "Delete", func() error {
+		return s.next.Delete(id)
+	})
+}
+
+func (s *observedLike) CountByPosts(postIds []int) (map[int]map[string]int, error) {
+	return observe(s.o, "like", "CountByPosts", func() (map[int]map[string]int, error) {
+		return s.next.CountByPosts(postIds)
+	})
+}
+
+type observedMedia struct {
+	next repo.MediaStorageI
+	o    Observer
+}
+
+func (s *observedMedia) Create(m *repo.Media) (*repo.Media, error) {
+	return observe(s.o, "media", "Create", func() (*repo.Media, error) {
+		return s.next.Create(m)
+	})
+}
+
+func (s *observedMedia) Get(id int) (*repo.Media, error) {
+	return observe(s.o, "media", "Get", func() (*repo.Media, error) {
+		return s.next.Get(id)
+	})
+}
+
+func (s *observedMedia) GetByKey(key string) (*repo.Media, error) {
+	return observe(s.o, "media", "GetByKey", func() (*repo.Media, error) {
+		return s.next.GetByKey(key)
+	})
+}
+
+func (s *observedMedia) KeyExists(key string) (bool, error) {
+	return observe(s.o, "media", "KeyExists", func() (bool, error) {
+		return s.next.KeyExists(key)
+	})
+}
+
+func (s *observedMedia) GetOrphans(createdBefore time.Time, limit int) ([]*repo.Media, error) {
+	return observe(s.o, "media", "GetOrphans", func() ([]*repo.Media, error) {
+		return s.next.GetOrphans(createdBefore, limit)
+	})
+}
+
+func (s *observedMedia) GetPending(limit int) ([]*repo.Media, error) {
+	return observe(s.o, "media", "GetPending", func() ([]*repo.Media, error) {
+		return s.next.GetPending(limit)
+	})
+}
+
+func (s *observedMedia) UpdateStatus(id int, status string) error {
+	return observeErr(s.o, "media", "UpdateStatus", func() error {
+		return s.next.UpdateStatus(id, status)
+	})
+}
+
+func (s *observedMedia) Delete(id int) error {
+	return observeErr(s.o, "media", "Delete", func() error {
+		return s.next.Delete(id)
+	})
+}
+
+func (s *observedMedia) CreateRendition(r *repo.MediaRendition) (*repo.MediaRendition, error) {
+	return observe(s.o, "media", "CreateRendition", func() (*repo.MediaRendition, error) {
+		return s.next.CreateRendition(r)
+	})
+}
+
+func (s *observedMedia) GetRenditions(mediaId int) ([]*repo.MediaRendition, error) {
+	return observe(s.o, "media", "GetRenditions", func() ([]*repo.MediaRendition, error) {
+		return s.next.GetRenditions(mediaId)
+	})
+}
+
+func (s *observedMedia) GetRenditionsByUrls(urls []string) (map[string][]*repo.MediaRendition, error) {
+	return observe(s.o, "media", "GetRenditionsByUrls", func() (map[string][]*repo.MediaRendition, error) {
+		return s.next.GetRenditionsByUrls(urls)
+	})
+}
+
+type observedSitemap struct {
+	next repo.SitemapStorageI
+	o    Observer
+}
+
+func (s *observedSitemap) GetPostMaxId() (int, error) {
+	return observe(s.o, "sitemap", "GetPostMaxId", func() (int, error) {
+		return s.next.GetPostMaxId()
+	})
+}
+
+func (s *observedSitemap) GetPosts(fromId int, toId int) ([]*repo.SitemapEntry, error) {
+	return observe(s.o, "sitemap", "GetPosts", func() ([]*repo.SitemapEntry, error) {
+		return s.next.GetPosts(fromId, toId)
+	})
+}
+
+func (s *observedSitemap) GetChangedPostChunks(since time.Time, chunkSize int) ([]int, error) {
+	return observe(s.o, "sitemap", "GetChangedPostChunks", func() ([]int, error) {
+		return s.next.GetChangedPostChunks(since, chunkSize)
+	})
+}
+
+func (s *observedSitemap) GetCategories() ([]*repo.SitemapEntry, error) {
+	return observe(s.o, "sitemap", "GetCategories", func() ([]*repo.SitemapEntry, error) {
+		return s.next.GetCategories()
+	})
+}
+
+func (s *observedSitemap) GetAuthors() ([]*repo.SitemapEntry, error) {
+	return observe(s.o, "sitemap", "GetAuthors", func() ([]*repo.SitemapEntry, error) {
+		return s.next.GetAuthors()
+	})
+}
+
+type observedSetting struct {
+	next repo.SettingStorageI
+	o    Observer
+}
+
+func (s *observedSetting) Get(key string) ([]byte, error) {
+	return observe(s.o, "setting", "Get", func() ([]byte, error) {
+		return s.next.Get(key)
+	})
+}
+
+func (s *observedSetting) Set(key string, value []byte) error {
+	return observeErr(s.o, "setting", "Set", func() error {
+		return s.next.Set(key, value)
+	})
+}
+
+type observedReport struct {
+	next repo.ReportStorageI
+	o    Observer
+}
+
+func (s *observedReport) Add(targetType string, targetId int, entry *repo.ReportEntry) (*repo.Report, bool, error) {
+	var v0 *repo.Report
+	var v1 bool
+	err := observeErr(s.o, "report", "Add", func() (err error) {
+		v0, v1, err = s.next.Add(targetType, targetId, entry)
+		return err
+	})
+	return v0, v1, err
+}
+
+func (s *observedReport) Get(id int) (*repo.Report, error) {
+	return observe(s.o, "report", "Get", func() (*repo.Report, error) {
+		return s.next.Get(id)
+	})
+}
+
+func (s *observedReport) GetAll(param repo.GetReportsQuery) (*repo.GetAllReportsResult, error) {
+	return observe(s.o, "report", "GetAll", func() (*repo.GetAllReportsResult, error) {
+		return s.next.GetAll(param)
+	})
+}
+
+func (s *observedReport) GetEntries(reportId int) ([]*repo.ReportEntry, error) {
+	return observe(s.o, "report", "GetEntries", func() ([]*repo.ReportEntry, error) {
+		return s.next.GetEntries(reportId)
+	})
+}
+
+func (s *observedReport) GetActions(reportId int) ([]*repo.ReportAction, error) {
+	return observe(s.o, "report", "GetActions", func() ([]*repo.ReportAction, error) {
+		return s.next.GetActions(reportId)
+	})
+}
+
+func (s *observedReport) Hide(id int, previousStatus string) error {
+	return observeErr(s.o, "report", "Hide", func() error {
+		return s.next.Hide(id, previousStatus)
+	})
+}
+
+func (s *observedReport) Resolve(id int, status string, actorId int, note string) error {
+	return observeErr(s.o, "report", "Resolve", func() error {
+		return s.next.Resolve(id, status, actorId, note)
+	})
+}
+
+func (s *observedReport) IsHidden(targetType string, targetId int) (bool, error) {
+	return observe(s.o, "report", "IsHidden", func() (bool, error) {
+		return s.next.IsHidden(targetType, targetId)
+	})
+}
+
+func (s *observedReport) GetHidden(targetType string, targetIds []int) (map[int]bool, error) {
+	return observe(s.o, "report", "GetHidden", func() (map[int]bool, error) {
+		return s.next.GetHidden(targetType, targetIds)
+	})
+}
+
+type observedTrash struct {
+	next repo.TrashStorageI
+	o    Observer
+}
+
+func (s *observedTrash) GetAll(param repo.GetTrashQuery) (*repo.GetAllTrashResult, error) {
+	return observe(s.o, "trash", "GetAll", func() (*repo.GetAllTrashResult, error) {
+		return s.next.GetAll(param)
+	})
+}
+
+func (s *observedTrash) Restore(itemType string, id int) error {
+	return observeErr(s.o, "trash", "Restore", func() error {
+		return s.next.Restore(itemType, id)
+	})
+}
+
+func (s *observedTrash) Purge(deletedBefore time.Time) (map[string]int, error) {
+	return observe(s.o, "trash", "Purge", func() (map[string]int, error) {
+		return s.next.Purge(deletedBefore)
+	})
+}
+
+type observedAudit struct {
+	next repo.AuditStorageI
+	o    Observer
+}
+
+func (s *observedAudit) Add(e *repo.AuditEntry) error {
+	return observeErr(s.o, "audit", "Add", func() error {
+		return s.next.Add(e)
+	})
+}
+
+func (s *observedAudit) GetAll(param repo.GetAuditQuery) (*repo.GetAllAuditResult, error) {
+	return observe(s.o, "audit", "GetAll", func() (*repo.GetAllAuditResult, error) {
+		return s.next.GetAll(param)
+	})
+}
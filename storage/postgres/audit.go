@@ -0,0 +1,150 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type auditRepo struct {
+	db DB
+}
+
+func NewAudit(db DB) repo.AuditStorageI {
+	return &auditRepo{db: db}
+}
+
+func (ar *auditRepo) Add(e *repo.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log(
+			actor_id,
+			action,
+			entity_type,
+			entity_id,
+			before,
+			after,
+			request_id,
+			ip
+		) values ($1,$2,$3,$4,$5,$6,$7,$8)
+		RETURNING id,created_at
+	`
+	return ar.db.QueryRow(
+		query,
+		nullInt(e.ActorId),
+		e.Action,
+		e.EntityType,
+		e.EntityId,
+		nullJson(e.Before),
+		nullJson(e.After),
+		e.RequestId,
+		e.Ip,
+	).Scan(
+		&e.Id,
+		&e.CreatedAt,
+	)
+}
+
+func (ar *auditRepo) GetAll(param repo.GetAuditQuery) (*repo.GetAllAuditResult, error) {
+	result := repo.GetAllAuditResult{
+		Entries: make([]*repo.AuditEntry, 0),
+	}
+
+	offset := (param.Page - 1) * param.Limit
+
+	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
+	filter := " WHERE true "
+	args := make([]interface{}, 0)
+	if param.ActorId > 0 {
+		args = append(args, param.ActorId)
+		filter += fmt.Sprintf(" AND actor_id=$%d ", len(args))
+	}
+	if param.Action != "" {
+		args = append(args, param.Action)
+		filter += fmt.Sprintf(" AND action=$%d ", len(args))
+	}
+	if param.EntityType != "" {
+		args = append(args, param.EntityType)
+		filter += fmt.Sprintf(" AND entity_type=$%d ", len(args))
+	}
+	if param.EntityId != "" {
+		args = append(args, param.EntityId)
+		filter += fmt.Sprintf(" AND entity_id=$%d ", len(args))
+	}
+	if !param.From.IsZero() {
+		args = append(args, param.From)
+		filter += fmt.Sprintf(" AND created_at >= $%d ", len(args))
+	}
+	if !param.To.IsZero() {
+		args = append(args, param.To)
+		filter += fmt.Sprintf(" AND created_at < $%d ", len(args))
+	}
+
+	query := `
+		SELECT
+			id,
+			actor_id,
+			action,
+			entity_type,
+			entity_id,
+			before,
+			after,
+			request_id,
+			ip,
+			created_at
+		FROM audit_log
+		` + filter + `
+		ORDER BY created_at desc, id desc
+		` + limit
+
+	rows, err := ar.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			e       repo.AuditEntry
+			actorId sql.NullInt64
+			before  []byte
+			after   []byte
+		)
+		if err := rows.Scan(
+			&e.Id,
+			&actorId,
+			&e.Action,
+			&e.EntityType,
+			&e.EntityId,
+			&before,
+			&after,
+			&e.RequestId,
+			&e.Ip,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		e.ActorId = int(actorId.Int64)
+		e.Before = before
+		e.After = after
+		result.Entries = append(result.Entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryCount := `SELECT count(1) FROM audit_log ` + filter
+	err = ar.db.QueryRow(queryCount, args...).Scan(&result.Count)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// nullJson stores empty snapshots as NULL rather than as invalid json.
+func nullJson(v []byte) interface{} {
+	if len(v) == 0 {
+		return nil
+	}
+	return string(v)
+}
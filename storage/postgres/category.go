@@ -2,17 +2,18 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/samandar2605/post/storage/repo"
 )
 
 type categoryRepo struct {
-	db *sqlx.DB
+	db DB
 }
 
-func NewCategory(db *sqlx.DB) repo.CategoryStorageI {
+func NewCategory(db DB) repo.CategoryStorageI {
 	return &categoryRepo{
 		db: db,
 	}
@@ -21,7 +22,7 @@ func NewCategory(db *sqlx.DB) repo.CategoryStorageI {
 func (cr *categoryRepo) Create(category *repo.Category) (*repo.Category, error) {
 	query := `
 		INSERT INTO categories(title) VALUES($1)
-		RETURNING id, created_at
+		RETURNING id, version, created_at
 	`
 
 	row := cr.db.QueryRow(
@@ -31,6 +32,7 @@ func (cr *categoryRepo) Create(category *repo.Category) (*repo.Category, error)
 
 	err := row.Scan(
 		&category.Id,
+		&category.Version,
 		&category.CreatedAt,
 	)
 	if err != nil {
@@ -47,15 +49,17 @@ func (cr *categoryRepo) Get(id int) (*repo.Category, error) {
 		SELECT
 			id,
 			title,
+			version,
 			created_at
 		FROM categories
-		WHERE id=$1
+		WHERE id=$1 AND deleted_at IS NULL
 	`
 
 	row := cr.db.QueryRow(query, id)
 	err := row.Scan(
 		&result.Id,
 		&result.Title,
+		&result.Version,
 		&result.CreatedAt,
 	)
 	if err != nil {
@@ -65,6 +69,43 @@ func (cr *categoryRepo) Get(id int) (*repo.Category, error) {
 	return &result, nil
 }
 
+func (cr *categoryRepo) GetByIds(ids []int) (map[int]*repo.Category, error) {
+	result := make(map[int]*repo.Category, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT
+			id,
+			title,
+			version,
+			created_at
+		FROM categories
+		WHERE id = ANY($1) AND deleted_at IS NULL
+	`
+	rows, err := cr.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var category repo.Category
+		if err := rows.Scan(
+			&category.Id,
+			&category.Title,
+			&category.Version,
+			&category.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result[category.Id] = &category
+	}
+
+	return result, rows.Err()
+}
+
 func (cr *categoryRepo) GetAll(param repo.GetCategoryQuery) (*repo.GetAllCategoriesResult, error) {
 	result := repo.GetAllCategoriesResult{
 		Categories: make([]*repo.Category, 0),
@@ -73,24 +114,25 @@ func (cr *categoryRepo) GetAll(param repo.GetCategoryQuery) (*repo.GetAllCategor
 	offset := (param.Page - 1) * param.Limit
 
 	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
-	filter := ""
+	filter := " WHERE deleted_at IS NULL "
+	args := make([]interface{}, 0)
 	if param.Search != "" {
-		str := "%" + param.Search + "%"
-		filter += fmt.Sprintf(` 
-			where title ILIKE '%s'`, str)
+		args = append(args, "%"+param.Search+"%")
+		filter += fmt.Sprintf(" AND title ILIKE $%d ", len(args))
 	}
 
 	query := `
 		SELECT 
 			id,
 			title,
+			version,
 			created_at
 		FROM categories
 		` + filter + `
 		ORDER BY created_at desc
 		` + limit
 
-	rows, err := cr.db.Query(query)
+	rows, err := cr.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +143,7 @@ func (cr *categoryRepo) GetAll(param repo.GetCategoryQuery) (*repo.GetAllCategor
 		if err := rows.Scan(
 			&Categ.Id,
 			&Categ.Title,
+			&Categ.Version,
 			&Categ.CreatedAt,
 		); err != nil {
 			return nil, err
@@ -108,7 +151,7 @@ func (cr *categoryRepo) GetAll(param repo.GetCategoryQuery) (*repo.GetAllCategor
 		result.Categories = append(result.Categories, &Categ)
 	}
 	queryCount := `SELECT count(1) FROM categories ` + filter
-	err = cr.db.QueryRow(queryCount).Scan(&result.Count)
+	err = cr.db.QueryRow(queryCount, args...).Scan(&result.Count)
 	if err != nil {
 		return nil, err
 	}
@@ -118,10 +161,18 @@ func (cr *categoryRepo) GetAll(param repo.GetCategoryQuery) (*repo.GetAllCategor
 func (cr *categoryRepo) Update(category repo.Category) (*repo.Category, error) {
 	query := `
 		update categories set
-			title=$1
-		where id=$2
+			title=$1,
+			version=version+1
+		where id=$2 AND deleted_at IS NULL AND version=$3
+		RETURNING version, created_at
 	`
-	_, err := cr.db.Exec(query, category.Title, category.Id)
+	err := cr.db.QueryRow(query, category.Title, category.Id, category.Version).Scan(
+		&category.Version,
+		&category.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(cr.db, "categories", category.Id)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -129,8 +180,35 @@ func (cr *categoryRepo) Update(category repo.Category) (*repo.Category, error) {
 	return &category, nil
 }
 
-func (ur *categoryRepo) Delete(id int) error {
-	res, err := ur.db.Exec("delete from categories where id=$1", id)
+func (cr *categoryRepo) Patch(id int, p *repo.CategoryPatch) (*repo.Category, error) {
+	var set setClause
+	if p.Title != nil {
+		set.add("title", *p.Title)
+	}
+
+	var result repo.Category
+	query, args := set.query("categories", id, p.Version, "id, title, version, created_at")
+	err := cr.db.QueryRow(query, args...).Scan(
+		&result.Id,
+		&result.Title,
+		&result.Version,
+		&result.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(cr.db, "categories", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (ur *categoryRepo) Delete(id, version int) error {
+	res, err := ur.db.Exec(
+		"update categories set deleted_at=now(), version=version+1 where id=$1 AND deleted_at IS NULL AND version=$2",
+		id,
+		version,
+	)
 	if err != nil {
 		return err
 	}
@@ -139,7 +217,7 @@ func (ur *categoryRepo) Delete(id int) error {
 		return err
 	}
 	if rows == 0 {
-		return sql.ErrNoRows
+		return updateMissed(ur.db, "categories", id)
 	}
 	return nil
 }
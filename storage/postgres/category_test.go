@@ -1,6 +1,7 @@
 package postgres_test
 
 import (
+	"database/sql"
 	"testing"
 
 	"github.com/bxcodec/faker/v4"
@@ -28,4 +29,40 @@ func TestGetCategory(t *testing.T) {
 
 func TestCreateCategory(t *testing.T) {
 	createCategory(t)
-}
\ No newline at end of file
+}
+func TestGetCategoriesByIds(t *testing.T) {
+	c1 := createCategory(t)
+	c2 := createCategory(t)
+
+	categories, err := strg.Category().GetByIds([]int{c1.Id, c2.Id, -1})
+	require.NoError(t, err)
+	require.Len(t, categories, 2)
+	require.Equal(t, c1.Title, categories[c1.Id].Title)
+	require.Equal(t, c2.Title, categories[c2.Id].Title)
+}
+
+func TestUpdateCategoryVersionConflict(t *testing.T) {
+	c := createCategory(t)
+
+	updated, err := strg.Category().Update(repo.Category{Id: c.Id, Title: faker.Sentence(), Version: c.Version})
+	require.NoError(t, err)
+	require.Equal(t, c.Version+1, updated.Version)
+
+	_, err = strg.Category().Update(repo.Category{Id: c.Id, Title: faker.Sentence(), Version: c.Version})
+	require.ErrorIs(t, err, repo.ErrVersionConflict)
+}
+
+func TestDeleteCategoryVersionConflict(t *testing.T) {
+	c := createCategory(t)
+
+	_, err := strg.Category().Update(repo.Category{Id: c.Id, Title: faker.Sentence(), Version: c.Version})
+	require.NoError(t, err)
+
+	err = strg.Category().Delete(c.Id, c.Version)
+	require.ErrorIs(t, err, repo.ErrVersionConflict)
+
+	require.NoError(t, strg.Category().Delete(c.Id, c.Version+1))
+
+	err = strg.Category().Delete(c.Id, c.Version+2)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -2,78 +2,109 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/samandar2605/post/storage/repo"
 )
 
 type commentRepo struct {
-	db *sqlx.DB
+	db DB
 }
 
-func NewComment(db *sqlx.DB) repo.CommentStorageI {
+func NewComment(db DB) repo.CommentStorageI {
 	return &commentRepo{db: db}
 }
 
 func (cr *commentRepo) Create(comment *repo.Comment) (*repo.Comment, error) {
+	if comment.Status == "" {
+		comment.Status = repo.CommentStatusPending
+	}
+
 	query := `
 		INSERT INTO comments(
 			post_id,
 			user_id,
 			description,
-			created_at
-		) values ($1,$2,$3,$4)
+			status,
+			created_at,
+			updated_at
+		) values ($1,$2,$3,$4,$5,$5)
 		RETURNING
 			id,
-			created_at
+			version,
+			created_at,
+			updated_at
 	`
 	result := cr.db.QueryRow(
 		query,
 		comment.PostId,
 		comment.UserId,
 		comment.Description,
+		comment.Status,
 		time.Now(),
 	)
 	if err := result.Scan(
 		&comment.Id,
+		&comment.Version,
 		&comment.CreatedAt,
+		&comment.UpdatedAt,
 	); err != nil {
 		return nil, err
 	}
 	return comment, nil
 }
 
-func (cr *commentRepo) Get(id int) (*repo.Comment, error) {
-	var Comment repo.Comment
-	query := `
-		SELECT
+const commentColumns = `
 			id,
 			post_id,
 			user_id,
 			description,
-			created_at
-		FROM comments
-		WHERE id=$1
-	`
+			status,
+			moderated_by,
+			moderated_at,
+			version,
+			created_at,
+			updated_at
+`
 
-	result := cr.db.QueryRow(
-		query,
-		id,
+func scanComment(row interface{ Scan(...interface{}) error }) (*repo.Comment, error) {
+	var (
+		comment     repo.Comment
+		moderatedBy sql.NullInt64
+		moderatedAt sql.NullTime
 	)
-	if err := result.Scan(
-		&Comment.Id,
-		&Comment.PostId,
-		&Comment.UserId,
-		&Comment.Description,
-		&Comment.CreatedAt,
+	if err := row.Scan(
+		&comment.Id,
+		&comment.PostId,
+		&comment.UserId,
+		&comment.Description,
+		&comment.Status,
+		&moderatedBy,
+		&moderatedAt,
+		&comment.Version,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
 	); err != nil {
 		return nil, err
 	}
+	comment.ModeratedBy = int(moderatedBy.Int64)
+	if moderatedAt.Valid {
+		comment.ModeratedAt = &moderatedAt.Time
+	}
 
-	return &Comment, nil
+	return &comment, nil
+}
+
+func (cr *commentRepo) Get(id int) (*repo.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM comments
+		WHERE id=$1 AND deleted_at IS NULL
+	`
+	return scanComment(cr.db.QueryRow(query, id))
 }
 
 func (cr *commentRepo) GetAll(param repo.GetCommentQuery) (*repo.GetAllCommentsResult, error) {
@@ -84,52 +115,46 @@ func (cr *commentRepo) GetAll(param repo.GetCommentQuery) (*repo.GetAllCommentsR
 	offset := (param.Page - 1) * param.Limit
 
 	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
-	filter := ""
+	filter := " WHERE deleted_at IS NULL "
+	args := make([]interface{}, 0)
 	if param.PostId > 0 {
-		filter += fmt.Sprintf("where post_id=%d", param.PostId)
+		args = append(args, param.PostId)
+		filter += fmt.Sprintf(" AND post_id=$%d ", len(args))
 	}
 	if param.UserId > 0 {
-		if filter == "" {
-			filter += fmt.Sprintf("where user_id=%d", param.PostId)
-		} else {
-			filter += fmt.Sprintf("or user_id=%d", param.PostId)
-		}
+		args = append(args, param.UserId)
+		filter += fmt.Sprintf(" AND user_id=$%d ", len(args))
 	}
+	if param.Status != "" {
+		args = append(args, param.Status)
+		filter += fmt.Sprintf(" AND status=$%d ", len(args))
+	}
+	if param.HideBannedAuthors {
+		filter += notBannedAuthor("comments.user_id")
+	}
+
 	query := `
-		SELECT 
-			id,
-			post_id,
-			user_id,
-			description,
-			created_at,
-			updated_at
+		SELECT ` + commentColumns + `
 		FROM comments
 		` + filter + `
 		ORDER BY created_at desc
 		` + limit
 
-	rows, err := cr.db.Query(query)
+	rows, err := cr.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 	for rows.Next() {
-		var Comment repo.Comment
-		if err := rows.Scan(
-			&Comment.Id,
-			&Comment.PostId,
-			&Comment.UserId,
-			&Comment.Description,
-			&Comment.CreatedAt,
-			&Comment.UpdatedAt,
-		); err != nil {
+		comment, err := scanComment(rows)
+		if err != nil {
 			return nil, err
 		}
-		result.Comments = append(result.Comments, &Comment)
+		result.Comments = append(result.Comments, comment)
 	}
 	queryCount := `SELECT count(1) FROM comments ` + filter
-	err = cr.db.QueryRow(queryCount).Scan(&result.Count)
+	err = cr.db.QueryRow(queryCount, args...).Scan(&result.Count)
 	if err != nil {
 		return nil, err
 	}
@@ -138,38 +163,72 @@ func (cr *commentRepo) GetAll(param repo.GetCommentQuery) (*repo.GetAllCommentsR
 
 func (cr *commentRepo) Update(comment *repo.Comment) (*repo.Comment, error) {
 	query := `
-		update comments set 
-			post_id =$1,
-			user_id =$2,
-			description =$3,
-			updated_at =$4,
-		where id=$5
-		RETURNING 
-			id,
+		update comments set
+			post_id=$1,
+			description=$2,
+			status=coalesce(nullif($3, ''), status),
+			updated_at=$4,
+			version=version+1
+		where id=$5 AND deleted_at IS NULL AND version=$6
+		RETURNING
+			status,
+			version,
 			created_at,
 			updated_at
 	`
 	result := cr.db.QueryRow(
 		query,
 		comment.PostId,
-		comment.UserId,
 		comment.Description,
+		comment.Status,
 		time.Now(),
+		comment.Id,
+		comment.Version,
 	)
 
-	if err := result.Scan(
-		&comment.Id,
+	err := result.Scan(
+		&comment.Status,
+		&comment.Version,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
-	); err != nil {
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(cr.db, "comments", comment.Id)
+	}
+	if err != nil {
 		return nil, err
 	}
 
 	return comment, nil
 }
 
-func (cr *commentRepo) Delete(id int) error {
-	res, err := cr.db.Exec("delete from comments where id=$1", id)
+func (cr *commentRepo) Patch(id int, p *repo.CommentPatch) (*repo.Comment, error) {
+	var set setClause
+	set.add("updated_at", time.Now())
+	if p.Description != nil {
+		set.add("description", *p.Description)
+	}
+	if p.Status != nil {
+		set.add("status", *p.Status)
+	}
+
+	query, args := set.query("comments", id, p.Version, commentColumns)
+	comment, err := scanComment(cr.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(cr.db, "comments", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (cr *commentRepo) Delete(id, version int) error {
+	res, err := cr.db.Exec(
+		"update comments set deleted_at=now(), version=version+1 where id=$1 AND deleted_at IS NULL AND version=$2",
+		id,
+		version,
+	)
 	if err != nil {
 		return err
 	}
@@ -178,7 +237,46 @@ func (cr *commentRepo) Delete(id int) error {
 		return err
 	}
 	if rows == 0 {
-		return sql.ErrNoRows
+		return updateMissed(cr.db, "comments", id)
 	}
 	return nil
 }
+
+func (cr *commentRepo) UpdateStatus(ids []int, status string, moderatorId int) (int, error) {
+	query := `
+		update comments set
+			status=$1,
+			moderated_by=$2,
+			moderated_at=$3,
+			version=version+1
+		where id = ANY($4) AND deleted_at IS NULL
+	`
+	res, err := cr.db.Exec(
+		query,
+		status,
+		nullInt(moderatorId),
+		time.Now(),
+		pq.Array(ids),
+	)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(rows), nil
+}
+
+func (cr *commentRepo) CountByUser(userId int, status string) (int, error) {
+	var count int
+	err := cr.db.QueryRow(
+		"SELECT count(1) FROM comments WHERE user_id=$1 AND status=$2 AND deleted_at IS NULL",
+		userId,
+		status,
+	).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
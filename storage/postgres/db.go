@@ -0,0 +1,142 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// DB is implemented by both *sqlx.DB and *sqlx.Tx, so the same repositories
+// work on their own or inside a transaction shared by several of them.
+type DB interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// txn is a transaction a repository method runs its statements in. When the
+// repository already works inside a transaction it joins it, leaving commit
+// and rollback to whoever started it.
+type txn struct {
+	DB
+	tx    *sqlx.Tx
+	owned bool
+}
+
+func begin(db DB) (*txn, error) {
+	if t, ok := db.(*tracedDB); ok {
+		tx, err := begin(t.next)
+		if err != nil {
+			return nil, err
+		}
+		tx.DB = &tracedDB{next: tx.DB, ctx: t.ctx}
+		return tx, nil
+	}
+	if s, ok := db.(*Session); ok {
+		s.Wrote()
+		return begin(s.r.primary)
+	}
+	if tx, ok := db.(*sqlx.Tx); ok {
+		return &txn{DB: tx, tx: tx}, nil
+	}
+	tx, err := db.(*sqlx.DB).Beginx()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{DB: tx, tx: tx, owned: true}, nil
+}
+
+func (t *txn) Commit() error {
+	if !t.owned {
+		return nil
+	}
+	return t.tx.Commit()
+}
+
+func (t *txn) Rollback() error {
+	if !t.owned {
+		return nil
+	}
+	return t.tx.Rollback()
+}
+
+// updateMissed tells why a compare-and-swap update of id in table matched no
+// row: sql.ErrNoRows if the row is gone, repo.ErrVersionConflict if it was
+// changed concurrently.
+func updateMissed(db DB, table string, id int) error {
+	var exists bool
+	err := db.QueryRow(
+		"SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id=$1 AND deleted_at IS NULL)",
+		id,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return repo.ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
+
+// uniqueFields names the column of the unique indexes writes may clash
+// with.
+var uniqueFields = map[string]string{
+	"users_email_idx":        "email",
+	"users_username_idx":     "username",
+	"users_phone_number_idx": "phone_number",
+}
+
+// duplicate turns a unique violation into a *repo.DuplicateError and
+// returns other errors as they are.
+func duplicate(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+	field, ok := uniqueFields[pqErr.Constraint]
+	if !ok {
+		field = pqErr.Constraint
+	}
+	return &repo.DuplicateError{Field: field}
+}
+
+// setClause collects the columns of a partial update.
+type setClause struct {
+	sql  string
+	args []interface{}
+}
+
+func (s *setClause) add(column string, value interface{}) {
+	s.args = append(s.args, value)
+	if s.sql != "" {
+		s.sql += ", "
+	}
+	s.sql += fmt.Sprintf("%s=$%d", column, len(s.args))
+}
+
+// query builds the update of row id in table, guarded by version like the
+// Update methods, returning columns.
+func (s *setClause) query(table string, id, version int, columns string) (string, []interface{}) {
+	set := "version=version+1"
+	if s.sql != "" {
+		set = s.sql + ", " + set
+	}
+	args := append(s.args, id, version)
+	query := fmt.Sprintf(`
+		update %s set %s
+		where id=$%d AND deleted_at IS NULL AND version=$%d
+		RETURNING %s`, table, set, len(args)-1, len(args), columns)
+	return query, args
+}
+
+// SchemaVersion returns the version golang-migrate last migrated the
+// database to and whether that migration failed half way.
+func SchemaVersion(ctx context.Context, db *sqlx.DB) (version uint, dirty bool, err error) {
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	return version, dirty, err
+}
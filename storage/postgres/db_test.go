@@ -0,0 +1,21 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicate(t *testing.T) {
+	var dup *repo.DuplicateError
+	err := duplicate(&pq.Error{Code: "23505", Constraint: "users_email_idx"})
+	require.ErrorAs(t, err, &dup)
+	require.Equal(t, "email", dup.Field)
+
+	other := &pq.Error{Code: "23503", Constraint: "posts_user_id_fkey"}
+	require.Equal(t, error(other), duplicate(other))
+	require.False(t, errors.As(duplicate(errors.New("connection refused")), &dup))
+}
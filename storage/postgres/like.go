@@ -4,15 +4,15 @@ import (
 	"database/sql"
 	"fmt"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/samandar2605/post/storage/repo"
 )
 
 type likeRepo struct {
-	db *sqlx.DB
+	db DB
 }
 
-func NewLike(db *sqlx.DB) repo.LikeStorageI {
+func NewLike(db DB) repo.LikeStorageI {
 	return &likeRepo{db: db}
 }
 
@@ -48,7 +48,7 @@ func (cr *likeRepo) Get(id int) (*repo.Like, error) {
 			user_id,
 			status
 		FROM likes
-		WHERE id=$1
+		WHERE id=$1 AND deleted_at IS NULL
 	`
 
 	result := cr.db.QueryRow(
@@ -75,16 +75,15 @@ func (cr *likeRepo) GetAll(param repo.GetLikesQuery) (*repo.GetAllLikesResult, e
 	offset := (param.Page - 1) * param.Limit
 
 	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
-	filter := ""
+	filter := " WHERE deleted_at IS NULL "
+	args := make([]interface{}, 0)
 	if param.PostId > 0 {
-		filter += fmt.Sprintf("where post_id=%d", param.PostId)
+		args = append(args, param.PostId)
+		filter += fmt.Sprintf(" AND post_id=$%d ", len(args))
 	}
 	if param.UserId > 0 {
-		if filter == "" {
-			filter += fmt.Sprintf("where user_id=%d", param.PostId)
-		} else {
-			filter += fmt.Sprintf("or user_id=%d", param.PostId)
-		}
+		args = append(args, param.UserId)
+		filter += fmt.Sprintf(" AND user_id=$%d ", len(args))
 	}
 	query := `
 		SELECT 
@@ -97,7 +96,7 @@ func (cr *likeRepo) GetAll(param repo.GetLikesQuery) (*repo.GetAllLikesResult, e
 		ORDER BY post_id desc
 		` + limit
 
-	rows, err := cr.db.Query(query)
+	rows, err := cr.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +115,7 @@ func (cr *likeRepo) GetAll(param repo.GetLikesQuery) (*repo.GetAllLikesResult, e
 		result.Like = append(result.Like, &like)
 	}
 	queryCount := `SELECT count(1) FROM likes ` + filter
-	err = cr.db.QueryRow(queryCount).Scan(&result.Count)
+	err = cr.db.QueryRow(queryCount, args...).Scan(&result.Count)
 	if err != nil {
 		return nil, err
 	}
@@ -129,7 +128,7 @@ func (cr *likeRepo) Update(like *repo.Like) (*repo.Like, error) {
 			post_id =$1,
 			user_id =$2,
 			status=$3
-		where id=$4
+		where id=$4 AND deleted_at IS NULL
 		RETURNING id
 	`
 	result := cr.db.QueryRow(
@@ -137,6 +136,7 @@ func (cr *likeRepo) Update(like *repo.Like) (*repo.Like, error) {
 		like.PostId,
 		like.UserId,
 		like.Status,
+		like.Id,
 	)
 
 	if err := result.Scan(
@@ -149,7 +149,10 @@ func (cr *likeRepo) Update(like *repo.Like) (*repo.Like, error) {
 }
 
 func (cr *likeRepo) Delete(id int) error {
-	res, err := cr.db.Exec("delete from likes where id=$1", id)
+	res, err := cr.db.Exec(
+		"update likes set deleted_at=now() where id=$1 AND deleted_at IS NULL",
+		id,
+	)
 	if err != nil {
 		return err
 	}
@@ -162,3 +165,42 @@ func (cr *likeRepo) Delete(id int) error {
 	}
 	return nil
 }
+
+func (cr *likeRepo) CountByPosts(postIds []int) (map[int]map[string]int, error) {
+	result := make(map[int]map[string]int, len(postIds))
+	if len(postIds) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT
+			post_id,
+			status,
+			count(*)
+		FROM likes
+		WHERE post_id = ANY($1) AND deleted_at IS NULL
+		GROUP BY post_id, status
+	`
+	rows, err := cr.db.Query(query, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			postId int
+			status string
+			count  int
+		)
+		if err := rows.Scan(&postId, &status, &count); err != nil {
+			return nil, err
+		}
+		if result[postId] == nil {
+			result[postId] = make(map[string]int)
+		}
+		result[postId][status] = count
+	}
+
+	return result, rows.Err()
+}
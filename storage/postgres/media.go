@@ -0,0 +1,317 @@
+package postgres
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type mediaRepo struct {
+	db DB
+}
+
+func NewMedia(db DB) repo.MediaStorageI {
+	return &mediaRepo{db: db}
+}
+
+func (mr *mediaRepo) Create(m *repo.Media) (*repo.Media, error) {
+	query := `
+		INSERT INTO media(
+			object_key,
+			url,
+			content_type,
+			size,
+			user_id
+		) values ($1,$2,$3,$4,$5)
+		RETURNING id,status,created_at
+	`
+	row := mr.db.QueryRow(
+		query,
+		m.ObjectKey,
+		m.Url,
+		m.ContentType,
+		m.Size,
+		nullInt(m.UserId),
+	)
+	if err := row.Scan(
+		&m.Id,
+		&m.Status,
+		&m.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+const mediaColumns = `
+			id,
+			object_key,
+			url,
+			content_type,
+			size,
+			user_id,
+			status,
+			created_at
+`
+
+func scanMedia(row interface{ Scan(...interface{}) error }) (*repo.Media, error) {
+	var (
+		m      repo.Media
+		userId sql.NullInt64
+	)
+	if err := row.Scan(
+		&m.Id,
+		&m.ObjectKey,
+		&m.Url,
+		&m.ContentType,
+		&m.Size,
+		&userId,
+		&m.Status,
+		&m.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+	m.UserId = int(userId.Int64)
+
+	return &m, nil
+}
+
+func (mr *mediaRepo) Get(id int) (*repo.Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE id=$1`
+	return scanMedia(mr.db.QueryRow(query, id))
+}
+
+func (mr *mediaRepo) GetByKey(key string) (*repo.Media, error) {
+	query := `SELECT ` + mediaColumns + ` FROM media WHERE object_key=$1`
+	return scanMedia(mr.db.QueryRow(query, key))
+}
+
+func (mr *mediaRepo) KeyExists(key string) (bool, error) {
+	var exists bool
+
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM media WHERE object_key=$1)
+			OR EXISTS (SELECT 1 FROM media_renditions WHERE object_key=$1)
+	`
+	err := mr.db.QueryRow(query, key).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+func (mr *mediaRepo) GetOrphans(createdBefore time.Time, limit int) ([]*repo.Media, error) {
+	query := `
+		SELECT ` + mediaColumns + `
+		FROM media m
+		WHERE m.created_at < $1
+			AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.image_url = m.url)
+			AND NOT EXISTS (SELECT 1 FROM users u WHERE u.profile_image_url = m.url)
+		ORDER BY m.created_at
+		LIMIT $2
+	`
+	return mr.list(query, createdBefore, limit)
+}
+
+func (mr *mediaRepo) GetPending(limit int) ([]*repo.Media, error) {
+	query := `
+		SELECT ` + mediaColumns + `
+		FROM media
+		WHERE status=$1
+		ORDER BY created_at
+		LIMIT $2
+	`
+	return mr.list(query, repo.MediaStatusPending, limit)
+}
+
+func (mr *mediaRepo) list(query string, args ...interface{}) ([]*repo.Media, error) {
+	rows, err := mr.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*repo.Media, 0)
+	for rows.Next() {
+		m, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
+func (mr *mediaRepo) UpdateStatus(id int, status string) error {
+	res, err := mr.db.Exec("update media set status=$1 where id=$2", status, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (mr *mediaRepo) Delete(id int) error {
+	res, err := mr.db.Exec("delete from media where id=$1", id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (mr *mediaRepo) CreateRendition(r *repo.MediaRendition) (*repo.MediaRendition, error) {
+	query := `
+		INSERT INTO media_renditions(
+			media_id,
+			name,
+			format,
+			object_key,
+			url,
+			content_type,
+			width,
+			height
+		) values ($1,$2,$3,$4,$5,$6,$7,$8)
+		ON CONFLICT (media_id, name, format) DO UPDATE SET
+			object_key=EXCLUDED.object_key,
+			url=EXCLUDED.url,
+			content_type=EXCLUDED.content_type,
+			width=EXCLUDED.width,
+			height=EXCLUDED.height
+		RETURNING id,created_at
+	`
+	row := mr.db.QueryRow(
+		query,
+		r.MediaId,
+		r.Name,
+		r.Format,
+		r.ObjectKey,
+		r.Url,
+		r.ContentType,
+		r.Width,
+		r.Height,
+	)
+	if err := row.Scan(
+		&r.Id,
+		&r.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+const renditionColumns = `
+			r.id,
+			r.media_id,
+			r.name,
+			r.format,
+			r.object_key,
+			r.url,
+			r.content_type,
+			r.width,
+			r.height,
+			r.created_at
+`
+
+func scanRendition(row interface{ Scan(...interface{}) error }, dest ...interface{}) (*repo.MediaRendition, error) {
+	var r repo.MediaRendition
+	if err := row.Scan(append([]interface{}{
+		&r.Id,
+		&r.MediaId,
+		&r.Name,
+		&r.Format,
+		&r.ObjectKey,
+		&r.Url,
+		&r.ContentType,
+		&r.Width,
+		&r.Height,
+		&r.CreatedAt,
+	}, dest...)...); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (mr *mediaRepo) GetRenditions(mediaId int) ([]*repo.MediaRendition, error) {
+	query := `
+		SELECT ` + renditionColumns + `
+		FROM media_renditions r
+		WHERE r.media_id=$1
+		ORDER BY r.width, r.format
+	`
+	rows, err := mr.db.Query(query, mediaId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*repo.MediaRendition, 0)
+	for rows.Next() {
+		r, err := scanRendition(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+
+	return result, rows.Err()
+}
+
+func (mr *mediaRepo) GetRenditionsByUrls(urls []string) (map[string][]*repo.MediaRendition, error) {
+	result := make(map[string][]*repo.MediaRendition)
+	if len(urls) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT ` + renditionColumns + `, m.url
+		FROM media_renditions r
+		JOIN media m ON m.id = r.media_id
+		WHERE m.url = ANY($1)
+		ORDER BY r.width, r.format
+	`
+	rows, err := mr.db.Query(query, pq.Array(urls))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var url string
+		r, err := scanRendition(rows, &url)
+		if err != nil {
+			return nil, err
+		}
+		result[url] = append(result[url], r)
+	}
+
+	return result, rows.Err()
+}
+
+func nullInt(v int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: v != 0}
+}
+
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
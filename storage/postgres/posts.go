@@ -2,46 +2,69 @@ package postgres
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/samandar2605/post/storage/repo"
 )
 
 type postRepo struct {
-	db *sqlx.DB
+	db DB
 }
 
-func NewPost(db *sqlx.DB) repo.PostStorageI {
+func NewPost(db DB) repo.PostStorageI {
 	return &postRepo{db: db}
 }
 
 func (pr *postRepo) Create(p *repo.Post) (*repo.Post, error) {
+	if p.Status == "" {
+		p.Status = repo.PostStatusApproved
+	}
+
 	query := `
 		INSERT INTO posts(
 			title,
 			description,
+			description_html,
+			toc,
+			reading_time,
+			excerpt,
 			image_url,
 			user_id,
 			category_id,
 			views_count,
-		)values($1,$2,$3,$4,$5,$6)
-		RETURNING id,created_at
+			status
+		)values($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		RETURNING id,version,created_at,updated_at
 	`
+	toc, err := json.Marshal(p.Toc)
+	if err != nil {
+		return nil, err
+	}
+
 	row := pr.db.QueryRow(
 		query,
 		p.Title,
 		p.Description,
+		p.DescriptionHtml,
+		toc,
+		p.ReadingTime,
+		p.Excerpt,
 		p.ImageUrl,
 		p.UserId,
 		p.CategoryId,
 		p.ViewsCount,
+		p.Status,
 	)
 
 	if err := row.Scan(
 		&p.Id,
+		&p.Version,
 		&p.CreatedAt,
+		&p.UpdatedAt,
 	); err != nil {
 		return nil, err
 	}
@@ -49,41 +72,74 @@ func (pr *postRepo) Create(p *repo.Post) (*repo.Post, error) {
 	return p, nil
 }
 
-func (pr *postRepo) Get(id int) (*repo.Post, error) {
-	var Post repo.Post
-
-	query := `
-		SELECT 
+const postColumns = `
 			id,
 			title,
 			description,
+			description_html,
+			toc,
+			reading_time,
+			excerpt,
 			image_url,
 			user_id,
 			category_id,
 			views_count,
+			status,
+			moderated_by,
+			moderated_at,
+			version,
 			created_at,
 			updated_at
-		from posts
-		where id=$1
-	`
-	row := pr.db.QueryRow(query, id)
+`
+
+func scanPost(row interface{ Scan(...interface{}) error }) (*repo.Post, error) {
+	var (
+		Post        repo.Post
+		toc         []byte
+		moderatedBy sql.NullInt64
+		moderatedAt sql.NullTime
+	)
 	if err := row.Scan(
 		&Post.Id,
 		&Post.Title,
 		&Post.Description,
+		&Post.DescriptionHtml,
+		&toc,
+		&Post.ReadingTime,
+		&Post.Excerpt,
 		&Post.ImageUrl,
 		&Post.UserId,
 		&Post.CategoryId,
 		&Post.ViewsCount,
+		&Post.Status,
+		&moderatedBy,
+		&moderatedAt,
+		&Post.Version,
 		&Post.CreatedAt,
 		&Post.UpdatedAt,
 	); err != nil {
 		return nil, err
 	}
+	if err := json.Unmarshal(toc, &Post.Toc); err != nil {
+		return nil, err
+	}
+	Post.ModeratedBy = int(moderatedBy.Int64)
+	if moderatedAt.Valid {
+		Post.ModeratedAt = &moderatedAt.Time
+	}
 
 	return &Post, nil
 }
 
+func (pr *postRepo) Get(id int) (*repo.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		from posts
+		where id=$1 AND deleted_at IS NULL
+	`
+	return scanPost(pr.db.QueryRow(query, id))
+}
+
 func (pr *postRepo) GetAll(param repo.GetPostQuery) (*repo.GetAllPostResult, error) {
 	result := repo.GetAllPostResult{
 		Post: make([]*repo.Post, 0),
@@ -92,54 +148,50 @@ func (pr *postRepo) GetAll(param repo.GetPostQuery) (*repo.GetAllPostResult, err
 	offset := (param.Page - 1) * param.Limit
 
 	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
-	filter := ""
+	filter := " WHERE deleted_at IS NULL "
+	args := make([]interface{}, 0)
 	if param.Search != "" {
-		str := "%" + param.Search + "%"
-		filter += fmt.Sprintf(`
-			where title ILIKE '%s'`, str)
+		args = append(args, "%"+param.Search+"%")
+		filter += fmt.Sprintf(" AND title ILIKE $%d ", len(args))
+	}
+	if param.CategoryId > 0 {
+		args = append(args, param.CategoryId)
+		filter += fmt.Sprintf(" AND category_id=$%d ", len(args))
+	}
+	if param.UserId > 0 {
+		args = append(args, param.UserId)
+		filter += fmt.Sprintf(" AND user_id=$%d ", len(args))
+	}
+	if param.Status != "" {
+		args = append(args, param.Status)
+		filter += fmt.Sprintf(" AND status=$%d ", len(args))
+	}
+	if param.HideBannedAuthors {
+		filter += notBannedAuthor("posts.user_id")
 	}
 
 	query := `
-		SELECT 
-			id,
-			title,
-			description,
-			image_url,
-			user_id,
-			category_id,
-			views_count,
-			created_at,
-			updated_at
+		SELECT ` + postColumns + `
 		FROM posts
 		` + filter + `
 		ORDER BY created_at desc
 		` + limit
 
-	rows, err := pr.db.Query(query)
+	rows, err := pr.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 	for rows.Next() {
-		var Post repo.Post
-		if err := rows.Scan(
-			&Post.Id,
-			&Post.Title,
-			&Post.Description,
-			&Post.ImageUrl,
-			&Post.UserId,
-			&Post.CategoryId,
-			&Post.ViewsCount,
-			&Post.CreatedAt,
-			&Post.UpdatedAt,
-		); err != nil {
+		Post, err := scanPost(rows)
+		if err != nil {
 			return nil, err
 		}
-		result.Post = append(result.Post, &Post)
+		result.Post = append(result.Post, Post)
 	}
 	queryCount := `SELECT count(1) FROM posts ` + filter
-	err = pr.db.QueryRow(queryCount).Scan(&result.Count)
+	err = pr.db.QueryRow(queryCount, args...).Scan(&result.Count)
 	if err != nil {
 		return nil, err
 	}
@@ -148,36 +200,98 @@ func (pr *postRepo) GetAll(param repo.GetPostQuery) (*repo.GetAllPostResult, err
 
 func (pr *postRepo) Update(post *repo.Post) (*repo.Post, error) {
 	query := `
-		update users set 
+		update posts set 
 			title=$1,
 			description=$2,
-			image_url=$3,
-			user_id=$4,
-			category_id=$5,
-			views_count=$6,
-			updated_at=$7
-		where id=$8
+			description_html=$3,
+			toc=$4,
+			reading_time=$5,
+			excerpt=$6,
+			image_url=$7,
+			user_id=$8,
+			category_id=$9,
+			views_count=$10,
+			updated_at=$11,
+			version=version+1
+		where id=$12 AND deleted_at IS NULL AND version=$13
+		RETURNING status,version,created_at,updated_at
 	`
-	_, err := pr.db.Exec(
+	toc, err := json.Marshal(post.Toc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pr.db.QueryRow(
 		query,
 		post.Title,
 		post.Description,
+		post.DescriptionHtml,
+		toc,
+		post.ReadingTime,
+		post.Excerpt,
 		post.ImageUrl,
 		post.UserId,
 		post.CategoryId,
 		post.ViewsCount,
 		time.Now(),
 		post.Id,
+		post.Version,
+	).Scan(
+		&post.Status,
+		&post.Version,
+		&post.CreatedAt,
+		&post.UpdatedAt,
 	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(pr.db, "posts", post.Id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
 
+func (pr *postRepo) Patch(id int, p *repo.PostPatch) (*repo.Post, error) {
+	var set setClause
+	set.add("updated_at", time.Now())
+	if p.Title != nil {
+		set.add("title", *p.Title)
+	}
+	if p.Description != nil {
+		toc, err := json.Marshal(p.Toc)
+		if err != nil {
+			return nil, err
+		}
+		set.add("description", *p.Description)
+		set.add("description_html", p.DescriptionHtml)
+		set.add("toc", toc)
+		set.add("reading_time", p.ReadingTime)
+		set.add("excerpt", p.Excerpt)
+	}
+	if p.ImageUrl != nil {
+		set.add("image_url", *p.ImageUrl)
+	}
+	if p.CategoryId != nil {
+		set.add("category_id", *p.CategoryId)
+	}
+
+	query, args := set.query("posts", id, p.Version, postColumns)
+	post, err := scanPost(pr.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(pr.db, "posts", id)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return post, nil
 }
 
-func (ur *postRepo) Delete(id int) error {
-	res, err := ur.db.Exec("delete from posts where id=$1", id)
+func (ur *postRepo) Delete(id, version int) error {
+	res, err := ur.db.Exec(
+		"update posts set deleted_at=now(), updated_at=now(), version=version+1 where id=$1 AND deleted_at IS NULL AND version=$2",
+		id,
+		version,
+	)
 	if err != nil {
 		return err
 	}
@@ -186,7 +300,86 @@ func (ur *postRepo) Delete(id int) error {
 		return err
 	}
 	if rows == 0 {
-		return sql.ErrNoRows
+		return updateMissed(ur.db, "posts", id)
 	}
 	return nil
 }
+
+func (pr *postRepo) UpdateStatus(ids []int, status string, moderatorId int) (int, error) {
+	query := `
+		update posts set
+			status=$1,
+			moderated_by=$2,
+			moderated_at=$3,
+			updated_at=$3,
+			version=version+1
+		where id = ANY($4) AND deleted_at IS NULL
+	`
+	res, err := pr.db.Exec(
+		query,
+		status,
+		nullInt(moderatorId),
+		time.Now(),
+		pq.Array(ids),
+	)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(rows), nil
+}
+
+func (pr *postRepo) GetUnrendered(afterId, limit int) ([]*repo.Post, error) {
+	query := `
+		SELECT ` + postColumns + `
+		FROM posts
+		WHERE id > $1 AND description <> '' AND description_html = ''
+		ORDER BY id
+		LIMIT $2
+	`
+	rows, err := pr.db.Query(query, afterId, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]*repo.Post, 0)
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+func (pr *postRepo) SetRendered(p *repo.Post) error {
+	query := `
+		update posts set
+			description_html=$1,
+			toc=$2,
+			reading_time=$3,
+			excerpt=$4,
+			version=version+1
+		where id=$5 AND description=$6
+	`
+	toc, err := json.Marshal(p.Toc)
+	if err != nil {
+		return err
+	}
+
+	_, err = pr.db.Exec(
+		query,
+		p.DescriptionHtml,
+		toc,
+		p.ReadingTime,
+		p.Excerpt,
+		p.Id,
+		p.Description,
+	)
+	return err
+}
@@ -0,0 +1,248 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ReplicaOptions struct {
+	// MaxLag is how far a replica may fall behind the primary and still
+	// serve reads.
+	MaxLag time.Duration
+	// Stickiness is how long the reads of a session go to the primary
+	// after it wrote, so that users see their own writes.
+	Stickiness time.Duration
+	// CheckInterval is how often the lag of the replicas is measured,
+	// CheckTimeout bounds one measurement.
+	CheckInterval time.Duration
+	CheckTimeout  time.Duration
+}
+
+// DefaultReplicaOptions are used for the options left zero.
+var DefaultReplicaOptions = ReplicaOptions{
+	MaxLag:        5 * time.Second,
+	Stickiness:    5 * time.Second,
+	CheckInterval: 5 * time.Second,
+	CheckTimeout:  2 * time.Second,
+}
+
+// lagQuery measures how far a replica is behind. A replica that replayed
+// everything it received is not behind, however old its last transaction.
+const lagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() OR pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END`
+
+type replica struct {
+	db     *sqlx.DB
+	usable atomic.Bool
+}
+
+// Replicas sends reads to the replicas in turn and everything else to the
+// primary. Replicas are checked in the background every CheckInterval
+// while statements are run; those that are down or lag more than MaxLag
+// are skipped until they caught up, and reads fall back to the primary
+// when none is left.
+type Replicas struct {
+	primary  *sqlx.DB
+	replicas []*replica
+	opts     ReplicaOptions
+
+	next      atomic.Uint64
+	checking  atomic.Bool
+	checkedAt atomic.Int64
+
+	mu        sync.Mutex
+	writes    map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewReplicas routes the statements of primary and replicas. Replicas are
+// not read from until their first check passed.
+func NewReplicas(primary *sqlx.DB, replicas []*sqlx.DB, opts ReplicaOptions) *Replicas {
+	if opts.MaxLag <= 0 {
+		opts.MaxLag = DefaultReplicaOptions.MaxLag
+	}
+	if opts.Stickiness <= 0 {
+		opts.Stickiness = DefaultReplicaOptions.Stickiness
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = DefaultReplicaOptions.CheckInterval
+	}
+	if opts.CheckTimeout <= 0 {
+		opts.CheckTimeout = DefaultReplicaOptions.CheckTimeout
+	}
+
+	r := &Replicas{
+		primary: primary,
+		opts:    opts,
+		writes:  make(map[string]time.Time),
+	}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+func (r *Replicas) Primary() *sqlx.DB {
+	return r.primary
+}
+
+// Session returns the DB the statements of session run on. Reads of a
+// session that wrote within Stickiness go to the primary.
+func (r *Replicas) Session(session string) *Session {
+	return &Session{r: r, key: session}
+}
+
+// Check measures the lag of every replica and returns the errors of those
+// that cannot serve reads.
+func (r *Replicas) Check(ctx context.Context) error {
+	r.checkedAt.Store(time.Now().UnixNano())
+
+	errs := make([]error, len(r.replicas))
+	var wg sync.WaitGroup
+	for i, rep := range r.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, r.opts.CheckTimeout)
+			defer cancel()
+
+			var lag float64
+			err := rep.db.QueryRowContext(ctx, lagQuery).Scan(&lag)
+			if err == nil && lag > r.opts.MaxLag.Seconds() {
+				err = fmt.Errorf("lags %.1fs behind the primary", lag)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("replica %d: %w", i+1, err)
+			}
+			rep.usable.Store(err == nil)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// checkSoon starts a check in the background once the last one is
+// CheckInterval old.
+func (r *Replicas) checkSoon() {
+	if time.Since(time.Unix(0, r.checkedAt.Load())) < r.opts.CheckInterval || !r.checking.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer r.checking.Store(false)
+		r.Check(context.Background())
+	}()
+}
+
+// reader returns the next usable replica, nil if there is none.
+func (r *Replicas) reader() *sqlx.DB {
+	r.checkSoon()
+	usable := make([]*sqlx.DB, 0, len(r.replicas))
+	for _, rep := range r.replicas {
+		if rep.usable.Load() {
+			usable = append(usable, rep.db)
+		}
+	}
+	if len(usable) == 0 {
+		return nil
+	}
+	return usable[r.next.Add(1)%uint64(len(usable))]
+}
+
+const sweepInterval = time.Minute
+
+func (r *Replicas) wrote(session string) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writes[session] = now
+	if now.Sub(r.lastSweep) >= sweepInterval {
+		for key, t := range r.writes {
+			if now.Sub(t) >= r.opts.Stickiness {
+				delete(r.writes, key)
+			}
+		}
+		r.lastSweep = now
+	}
+}
+
+func (r *Replicas) sticky(session string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.writes[session]
+	return ok && time.Since(t) < r.opts.Stickiness
+}
+
+// Session is the DB of one session, see Replicas.Session.
+type Session struct {
+	r   *Replicas
+	key string
+}
+
+// Wrote makes the reads of the session go to the primary for a while, for
+// writes that did not run through Exec, Query or QueryRow.
+func (s *Session) Wrote() {
+	if len(s.r.replicas) > 0 {
+		s.r.wrote(s.key)
+	}
+}
+
+// reader returns the replica to run query on, nil for the primary.
+func (s *Session) reader(query string) *sqlx.DB {
+	if len(s.r.replicas) == 0 {
+		return nil
+	}
+	if !isRead(query) {
+		s.Wrote()
+		return nil
+	}
+	if s.r.sticky(s.key) {
+		return nil
+	}
+	return s.r.reader()
+}
+
+func (s *Session) Exec(query string, args ...interface{}) (sql.Result, error) {
+	s.Wrote()
+	return s.r.primary.Exec(query, args...)
+}
+
+// Query and QueryRow retry reads that failed on a replica on the primary.
+func (s *Session) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if db := s.reader(query); db != nil {
+		if rows, err := db.Query(query, args...); err == nil {
+			return rows, nil
+		}
+	}
+	return s.r.primary.Query(query, args...)
+}
+
+func (s *Session) QueryRow(query string, args ...interface{}) *sql.Row {
+	if db := s.reader(query); db != nil {
+		if row := db.QueryRow(query, args...); row.Err() == nil {
+			return row
+		}
+	}
+	return s.r.primary.QueryRow(query, args...)
+}
+
+// isRead tells whether query only reads and may run on a replica.
+func isRead(query string) bool {
+	if statementName(query) != "SELECT" {
+		return false
+	}
+	upper := strings.ToUpper(query)
+	return !strings.Contains(upper, " FOR UPDATE") && !strings.Contains(upper, " FOR SHARE")
+}
@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeServer is a database of the fake driver. It answers the lag query
+// with lag and every other query with one row, or fails when down.
+type fakeServer struct {
+	mu      sync.Mutex
+	lag     float64
+	down    bool
+	queries []string
+}
+
+func (s *fakeServer) set(lag float64, down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lag, s.down = lag, down
+}
+
+// ran returns the queries other than the lag query run so far.
+func (s *fakeServer) ran() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+var (
+	fakeServersMu sync.Mutex
+	fakeServers   = map[string]*fakeServer{}
+)
+
+func init() {
+	sql.Register("replicas_test", fakeDriver{})
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeServersMu.Lock()
+	defer fakeServersMu.Unlock()
+	return fakeConn{fakeServers[name]}, nil
+}
+
+type fakeConn struct {
+	s *fakeServer
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{s: c.s, query: query}, nil
+}
+
+func (c fakeConn) Close() error {
+	return nil
+}
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	s     *fakeServer
+	query string
+}
+
+func (st fakeStmt) Close() error {
+	return nil
+}
+
+func (st fakeStmt) NumInput() int {
+	return -1
+}
+
+func (st fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if _, err := st.Query(args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (st fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	if st.s.down {
+		return nil, errors.New("connection refused")
+	}
+	if st.query == lagQuery {
+		return &fakeRows{value: st.s.lag}, nil
+	}
+	st.s.queries = append(st.s.queries, st.query)
+	return &fakeRows{value: int64(1)}, nil
+}
+
+type fakeRows struct {
+	value driver.Value
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"v"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+func newFakeDB(t *testing.T, name string) (*sqlx.DB, *fakeServer) {
+	s := &fakeServer{}
+	name = t.Name() + "/" + name
+	fakeServersMu.Lock()
+	fakeServers[name] = s
+	fakeServersMu.Unlock()
+
+	db, err := sql.Open("replicas_test", name)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return sqlx.NewDb(db, "postgres"), s
+}
+
+// newTestReplicas returns replicas of one primary and one replica, checked
+// once. Tests check them again themselves.
+func newTestReplicas(t *testing.T, opts ReplicaOptions) (*Replicas, *fakeServer, *fakeServer) {
+	primaryDB, primary := newFakeDB(t, "primary")
+	replicaDB, replica := newFakeDB(t, "replica")
+	opts.CheckInterval = time.Hour
+	r := NewReplicas(primaryDB, []*sqlx.DB{replicaDB}, opts)
+	require.NoError(t, r.Check(context.Background()))
+	return r, primary, replica
+}
+
+func queryRow(t *testing.T, db *Session, query string) {
+	var v int
+	require.NoError(t, db.QueryRow(query).Scan(&v))
+}
+
+func TestReplicasRouteReads(t *testing.T) {
+	r, primary, replica := newTestReplicas(t, ReplicaOptions{})
+	session := r.Session("")
+
+	queryRow(t, session, "SELECT 1")
+	queryRow(t, session, "SELECT 2 FOR UPDATE")
+	queryRow(t, session, "UPDATE posts SET views_count = views_count + 1 RETURNING 1")
+	_, err := session.Exec("DELETE FROM posts")
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"SELECT 1"}, replica.ran())
+	require.Equal(t, []string{
+		"SELECT 2 FOR UPDATE",
+		"UPDATE posts SET views_count = views_count + 1 RETURNING 1",
+		"DELETE FROM posts",
+	}, primary.ran())
+}
+
+func TestReplicasStickiness(t *testing.T) {
+	r, primary, replica := newTestReplicas(t, ReplicaOptions{Stickiness: 50 * time.Millisecond})
+	writer, reader := r.Session("user:1"), r.Session("user:2")
+
+	_, err := writer.Exec("UPDATE posts SET title = 'x'")
+	require.NoError(t, err)
+	queryRow(t, writer, "SELECT 1")
+	queryRow(t, reader, "SELECT 2")
+	require.Equal(t, []string{"UPDATE posts SET title = 'x'", "SELECT 1"}, primary.ran())
+	require.Equal(t, []string{"SELECT 2"}, replica.ran())
+
+	time.Sleep(60 * time.Millisecond)
+	queryRow(t, writer, "SELECT 3")
+	require.Equal(t, []string{"SELECT 2", "SELECT 3"}, replica.ran())
+}
+
+func TestReplicasLagFallback(t *testing.T) {
+	r, primary, replica := newTestReplicas(t, ReplicaOptions{MaxLag: 5 * time.Second})
+	session := r.Session("")
+
+	replica.set(10, false)
+	require.ErrorContains(t, r.Check(context.Background()), "replica 1: lags 10.0s behind the primary")
+	queryRow(t, session, "SELECT 1")
+	require.Equal(t, []string{"SELECT 1"}, primary.ran())
+
+	replica.set(0, false)
+	require.NoError(t, r.Check(context.Background()))
+	queryRow(t, session, "SELECT 2")
+	require.Equal(t, []string{"SELECT 2"}, replica.ran())
+}
+
+func TestReplicasRetryOnPrimary(t *testing.T) {
+	r, primary, replica := newTestReplicas(t, ReplicaOptions{})
+	session := r.Session("")
+
+	// the replica went down since it was checked
+	replica.set(0, true)
+	queryRow(t, session, "SELECT 1")
+	rows, err := session.Query("SELECT 2")
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	require.Equal(t, []string{"SELECT 1", "SELECT 2"}, primary.ran())
+	require.Empty(t, replica.ran())
+}
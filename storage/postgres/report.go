@@ -0,0 +1,393 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type reportRepo struct {
+	db DB
+}
+
+func NewReport(db DB) repo.ReportStorageI {
+	return &reportRepo{db: db}
+}
+
+func (rr *reportRepo) Add(targetType string, targetId int, entry *repo.ReportEntry) (*repo.Report, bool, error) {
+	tx, err := begin(rr.db)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// the no-op update locks the open report so concurrent reports on the
+	// same target are counted one after another
+	query := `
+		INSERT INTO reports(
+			target_type,
+			target_id
+		) values ($1,$2)
+		ON CONFLICT (target_type, target_id) WHERE status='open'
+		DO UPDATE SET updated_at=reports.updated_at
+		RETURNING id
+	`
+	if err := tx.QueryRow(query, targetType, targetId).Scan(&entry.ReportId); err != nil {
+		return nil, false, err
+	}
+
+	query = `
+		INSERT INTO report_entries(
+			report_id,
+			reporter_id,
+			reason,
+			details
+		) values ($1,$2,$3,$4)
+		ON CONFLICT (report_id, reporter_id) DO NOTHING
+		RETURNING id,created_at
+	`
+	err = tx.QueryRow(
+		query,
+		entry.ReportId,
+		entry.ReporterId,
+		entry.Reason,
+		entry.Details,
+	).Scan(
+		&entry.Id,
+		&entry.CreatedAt,
+	)
+	added := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	if added {
+		_, err = tx.Exec(
+			"UPDATE reports SET report_count=report_count+1, updated_at=$2 WHERE id=$1",
+			entry.ReportId,
+			time.Now(),
+		)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	report, err := scanReport(tx.QueryRow(`SELECT `+reportColumns+` FROM reports WHERE id=$1`, entry.ReportId))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return report, added, tx.Commit()
+}
+
+const reportColumns = `
+			id,
+			target_type,
+			target_id,
+			status,
+			report_count,
+			hidden,
+			hidden_status,
+			resolved_by,
+			resolved_at,
+			created_at,
+			updated_at
+`
+
+func scanReport(row interface{ Scan(...interface{}) error }) (*repo.Report, error) {
+	var (
+		r          repo.Report
+		resolvedBy sql.NullInt64
+		resolvedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&r.Id,
+		&r.TargetType,
+		&r.TargetId,
+		&r.Status,
+		&r.ReportCount,
+		&r.Hidden,
+		&r.HiddenStatus,
+		&resolvedBy,
+		&resolvedAt,
+		&r.CreatedAt,
+		&r.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+	r.ResolvedBy = int(resolvedBy.Int64)
+	if resolvedAt.Valid {
+		r.ResolvedAt = &resolvedAt.Time
+	}
+
+	return &r, nil
+}
+
+func (rr *reportRepo) Get(id int) (*repo.Report, error) {
+	query := `
+		SELECT ` + reportColumns + `
+		FROM reports
+		WHERE id=$1
+	`
+	return scanReport(rr.db.QueryRow(query, id))
+}
+
+func (rr *reportRepo) GetAll(param repo.GetReportsQuery) (*repo.GetAllReportsResult, error) {
+	result := repo.GetAllReportsResult{
+		Reports: make([]*repo.Report, 0),
+	}
+
+	offset := (param.Page - 1) * param.Limit
+
+	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
+	filter := " WHERE true "
+	args := make([]interface{}, 0)
+	if param.Status != "" {
+		args = append(args, param.Status)
+		filter += fmt.Sprintf(" AND status=$%d ", len(args))
+	}
+	if param.TargetType != "" {
+		args = append(args, param.TargetType)
+		filter += fmt.Sprintf(" AND target_type=$%d ", len(args))
+	}
+
+	// the most reported targets need attention first
+	query := `
+		SELECT ` + reportColumns + `
+		FROM reports
+		` + filter + `
+		ORDER BY report_count desc, created_at
+		` + limit
+
+	rows, err := rr.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		r, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		result.Reports = append(result.Reports, r)
+	}
+	queryCount := `SELECT count(1) FROM reports ` + filter
+	err = rr.db.QueryRow(queryCount, args...).Scan(&result.Count)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (rr *reportRepo) GetEntries(reportId int) ([]*repo.ReportEntry, error) {
+	query := `
+		SELECT
+			id,
+			report_id,
+			reporter_id,
+			reason,
+			details,
+			created_at
+		FROM report_entries
+		WHERE report_id=$1
+		ORDER BY created_at
+	`
+	rows, err := rr.db.Query(query, reportId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*repo.ReportEntry, 0)
+	for rows.Next() {
+		var e repo.ReportEntry
+		if err := rows.Scan(
+			&e.Id,
+			&e.ReportId,
+			&e.ReporterId,
+			&e.Reason,
+			&e.Details,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, &e)
+	}
+
+	return result, rows.Err()
+}
+
+func (rr *reportRepo) GetActions(reportId int) ([]*repo.ReportAction, error) {
+	query := `
+		SELECT
+			id,
+			report_id,
+			actor_id,
+			action,
+			note,
+			created_at
+		FROM report_actions
+		WHERE report_id=$1
+		ORDER BY created_at, id
+	`
+	rows, err := rr.db.Query(query, reportId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*repo.ReportAction, 0)
+	for rows.Next() {
+		var (
+			a       repo.ReportAction
+			actorId sql.NullInt64
+		)
+		if err := rows.Scan(
+			&a.Id,
+			&a.ReportId,
+			&actorId,
+			&a.Action,
+			&a.Note,
+			&a.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		a.ActorId = int(actorId.Int64)
+		result = append(result, &a)
+	}
+
+	return result, rows.Err()
+}
+
+func (rr *reportRepo) Hide(id int, previousStatus string) error {
+	tx, err := begin(rr.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"UPDATE reports SET hidden=true, hidden_status=$2, updated_at=$3 WHERE id=$1 AND NOT hidden",
+		id,
+		previousStatus,
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	if err := addReportAction(tx, id, 0, "hidden", "report threshold reached"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (rr *reportRepo) Resolve(id int, status string, actorId int, note string) error {
+	tx, err := begin(rr.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE reports SET
+			status=$2,
+			resolved_by=$3,
+			resolved_at=$4,
+			updated_at=$4
+		WHERE id=$1 AND status='open'
+	`
+	res, err := tx.Exec(query, id, status, nullInt(actorId), time.Now())
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	if err := addReportAction(tx, id, actorId, status, note); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func addReportAction(tx DB, reportId, actorId int, action, note string) error {
+	query := `
+		INSERT INTO report_actions(
+			report_id,
+			actor_id,
+			action,
+			note
+		) values ($1,$2,$3,$4)
+	`
+	_, err := tx.Exec(query, reportId, nullInt(actorId), action, note)
+	return err
+}
+
+func (rr *reportRepo) IsHidden(targetType string, targetId int) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM reports
+			WHERE target_type=$1 AND target_id=$2 AND status='open' AND hidden
+		)
+	`
+	var hidden bool
+	if err := rr.db.QueryRow(query, targetType, targetId).Scan(&hidden); err != nil {
+		return false, err
+	}
+	return hidden, nil
+}
+
+func (rr *reportRepo) GetHidden(targetType string, targetIds []int) (map[int]bool, error) {
+	result := make(map[int]bool)
+	if len(targetIds) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT target_id FROM reports
+		WHERE target_type=$1 AND target_id = ANY($2) AND status='open' AND hidden
+	`
+	rows, err := rr.db.Query(query, targetType, pq.Array(targetIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		result[id] = true
+	}
+	return result, rows.Err()
+}
+
+// notHidden filters out rows whose column is the id of a targetType that an
+// open report hides.
+func notHidden(targetType, column string) string {
+	return ` AND NOT EXISTS (
+		SELECT 1 FROM reports r
+		WHERE r.target_type='` + targetType + `' AND r.target_id=` + column + `
+			AND r.status='open' AND r.hidden
+	) `
+}
@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type settingRepo struct {
+	db DB
+}
+
+func NewSetting(db DB) repo.SettingStorageI {
+	return &settingRepo{db: db}
+}
+
+func (sr *settingRepo) Get(key string) ([]byte, error) {
+	var value []byte
+	err := sr.db.QueryRow("SELECT value FROM settings WHERE key=$1", key).Scan(&value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func (sr *settingRepo) Set(key string, value []byte) error {
+	query := `
+		INSERT INTO settings(
+			key,
+			value
+		) values ($1,$2)
+		ON CONFLICT (key) DO UPDATE SET
+			value=excluded.value,
+			updated_at=current_timestamp
+	`
+	_, err := sr.db.Exec(query, key, value)
+	return err
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type sitemapRepo struct {
+	db DB
+}
+
+func NewSitemap(db DB) repo.SitemapStorageI {
+	return &sitemapRepo{db: db}
+}
+
+func (sr *sitemapRepo) GetPostMaxId() (int, error) {
+	var id int
+	err := sr.db.QueryRow("SELECT coalesce(max(id), 0) FROM posts").Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (sr *sitemapRepo) GetPosts(fromId, toId int) ([]*repo.SitemapEntry, error) {
+	query := `
+		SELECT
+			id,
+			updated_at
+		FROM posts
+		WHERE id >= $1 AND id < $2 AND status='approved' AND deleted_at IS NULL
+		` + notBannedAuthor("posts.user_id") + `
+		ORDER BY id
+	`
+	return sr.list(query, fromId, toId)
+}
+
+func (sr *sitemapRepo) GetChangedPostChunks(since time.Time, chunkSize int) ([]int, error) {
+	query := `
+		SELECT DISTINCT (id-1)/$2
+		FROM posts
+		WHERE updated_at > $1
+	`
+	rows, err := sr.db.Query(query, since, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]int, 0)
+	for rows.Next() {
+		var chunk int
+		if err := rows.Scan(&chunk); err != nil {
+			return nil, err
+		}
+		result = append(result, chunk)
+	}
+
+	return result, rows.Err()
+}
+
+func (sr *sitemapRepo) GetCategories() ([]*repo.SitemapEntry, error) {
+	query := `
+		SELECT
+			c.id,
+			max(p.updated_at)
+		FROM categories c
+		JOIN posts p ON p.category_id = c.id AND p.status='approved' AND p.deleted_at IS NULL
+		WHERE c.deleted_at IS NULL ` + notBannedAuthor("p.user_id") + `
+		GROUP BY c.id
+		ORDER BY c.id
+	`
+	return sr.list(query)
+}
+
+func (sr *sitemapRepo) GetAuthors() ([]*repo.SitemapEntry, error) {
+	query := `
+		SELECT
+			u.id,
+			max(p.updated_at)
+		FROM users u
+		JOIN posts p ON p.user_id = u.id AND p.status='approved' AND p.deleted_at IS NULL
+		WHERE u.deleted_at IS NULL ` + notBannedAuthor("p.user_id") + `
+		GROUP BY u.id
+		ORDER BY u.id
+	`
+	return sr.list(query)
+}
+
+func (sr *sitemapRepo) list(query string, args ...interface{}) ([]*repo.SitemapEntry, error) {
+	rows, err := sr.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*repo.SitemapEntry, 0)
+	for rows.Next() {
+		var e repo.SitemapEntry
+		if err := rows.Scan(
+			&e.Id,
+			&e.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		result = append(result, &e)
+	}
+
+	return result, rows.Err()
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/samandar2605/post/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/samandar2605/post/storage/postgres")
+
+// Traced returns db with every statement run as a span, a child of the span
+// in the context returned by ctx at the time of the statement. The sanitized
+// statement is recorded as db.statement.
+//
+// A span ends when the statement returns. For Query and QueryRow that is
+// before the rows are read, so the time spent fetching them, which is most
+// of a long list query, is not part of the span. *sql.Rows can not be
+// wrapped without changing the DB interface every repository scans through.
+func Traced(db DB, ctx func() context.Context) DB {
+	return &tracedDB{next: db, ctx: ctx}
+}
+
+type tracedDB struct {
+	next DB
+	ctx  func() context.Context
+}
+
+func (t *tracedDB) start(query string) trace.Span {
+	_, span := tracer.Start(t.ctx(), statementName(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", tracing.SanitizeSQL(query)),
+		),
+	)
+	return span
+}
+
+func end(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (t *tracedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	span := t.start(query)
+	res, err := t.next.Exec(query, args...)
+	end(span, err)
+	return res, err
+}
+
+func (t *tracedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	span := t.start(query)
+	rows, err := t.next.Query(query, args...)
+	end(span, err)
+	return rows, err
+}
+
+func (t *tracedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	span := t.start(query)
+	row := t.next.QueryRow(query, args...)
+	end(span, row.Err())
+	return row
+}
+
+// statementName names the span of a statement after its first keyword, the
+// statement itself is too long and varied for a name.
+func statementName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "sql"
+	}
+	return strings.ToUpper(fields[0])
+}
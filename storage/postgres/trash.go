@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type trashTable struct {
+	name string
+	// title labels a row in the trash listing
+	title string
+}
+
+var trashTables = map[string]trashTable{
+	repo.TrashTypePost:     {name: "posts", title: "title"},
+	repo.TrashTypeComment:  {name: "comments", title: "left(description, 100)"},
+	repo.TrashTypeCategory: {name: "categories", title: "title"},
+	repo.TrashTypeUser:     {name: "users", title: "username"},
+	repo.TrashTypeLike:     {name: "likes", title: "status || ' on post ' || post_id"},
+}
+
+type trashRepo struct {
+	db DB
+}
+
+func NewTrash(db DB) repo.TrashStorageI {
+	return &trashRepo{db: db}
+}
+
+func (tr *trashRepo) GetAll(param repo.GetTrashQuery) (*repo.GetAllTrashResult, error) {
+	result := repo.GetAllTrashResult{
+		Items: make([]*repo.TrashItem, 0),
+	}
+
+	offset := (param.Page - 1) * param.Limit
+
+	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
+	items := ""
+	for _, t := range repo.TrashTypes {
+		if param.Type != "" && param.Type != t {
+			continue
+		}
+		if items != "" {
+			items += " UNION ALL "
+		}
+		table := trashTables[t]
+		items += fmt.Sprintf(`
+			SELECT '%s' AS type, id, %s AS title, deleted_at
+			FROM %s
+			WHERE deleted_at IS NOT NULL`, t, table.title, table.name)
+	}
+	if items == "" {
+		return nil, fmt.Errorf("unknown trash type %q", param.Type)
+	}
+
+	query := `
+		SELECT
+			type,
+			id,
+			coalesce(title, ''),
+			deleted_at
+		FROM (` + items + `) t
+		ORDER BY deleted_at desc
+		` + limit
+
+	rows, err := tr.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+	for rows.Next() {
+		var item repo.TrashItem
+		if err := rows.Scan(
+			&item.Type,
+			&item.Id,
+			&item.Title,
+			&item.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	queryCount := `SELECT count(1) FROM (` + items + `) t`
+	err = tr.db.QueryRow(queryCount).Scan(&result.Count)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (tr *trashRepo) Restore(itemType string, id int) error {
+	table, ok := trashTables[itemType]
+	if !ok {
+		return fmt.Errorf("unknown trash type %q", itemType)
+	}
+
+	set := "deleted_at=NULL"
+	if itemType == repo.TrashTypePost {
+		// let the sitemap pick the post up again
+		set += ", updated_at=now()"
+	}
+	res, err := tr.db.Exec(
+		"update "+table.name+" set "+set+" where id=$1 AND deleted_at IS NOT NULL",
+		id,
+	)
+	if err != nil {
+		// another user may have taken the email, username or phone number
+		return duplicate(err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Purge removes comments and likes together with the posts they belong to.
+// Users still referenced by a post are kept until the post is gone.
+func (tr *trashRepo) Purge(deletedBefore time.Time) (map[string]int, error) {
+	purges := []struct {
+		itemType string
+		query    string
+	}{
+		{repo.TrashTypeLike, `
+			DELETE FROM likes
+			WHERE deleted_at < $1
+				OR post_id IN (SELECT id FROM posts WHERE deleted_at < $1)`},
+		{repo.TrashTypeComment, `
+			DELETE FROM comments
+			WHERE deleted_at < $1
+				OR post_id IN (SELECT id FROM posts WHERE deleted_at < $1)`},
+		{repo.TrashTypePost, `DELETE FROM posts WHERE deleted_at < $1`},
+		{repo.TrashTypeCategory, `DELETE FROM categories WHERE deleted_at < $1`},
+		{repo.TrashTypeUser, `
+			DELETE FROM users u
+			WHERE deleted_at < $1
+				AND NOT EXISTS (SELECT 1 FROM posts p WHERE p.user_id=u.id)`},
+	}
+
+	tx, err := begin(tr.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := make(map[string]int, len(purges))
+	for _, p := range purges {
+		res, err := tx.Exec(p.query, deletedBefore)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		result[p.itemType] = int(rows)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -2,17 +2,19 @@ package postgres
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/samandar2605/post/storage/repo"
 )
 
 type userRepo struct {
-	db *sqlx.DB
+	db DB
 }
 
-func NewUser(db *sqlx.DB) repo.UserStorageI {
+func NewUser(db DB) repo.UserStorageI {
 	return &userRepo{db: db}
 }
 
@@ -24,12 +26,12 @@ func (ur *userRepo) Create(u *repo.User) (*repo.User, error) {
 			phone_number,
 			email,
 			gender,
-			user_name,
+			username,
 			password,
 			profile_image_url,
 			type
 		)values($1,$2,$3,$4,$5,$6,$7,$8,$9)
-		RETURNING id,created_at
+		RETURNING id,status,version,created_at
 	`
 
 	row := ur.db.QueryRow(
@@ -47,6 +49,8 @@ func (ur *userRepo) Create(u *repo.User) (*repo.User, error) {
 
 	if err := row.Scan(
 		&u.Id,
+		&u.Status,
+		&u.Version,
 		&u.CreatedAt,
 	); err != nil {
 		return nil, err
@@ -55,30 +59,34 @@ func (ur *userRepo) Create(u *repo.User) (*repo.User, error) {
 	return u, nil
 }
 
-func (ur *userRepo) Get(id int) (*repo.User, error) {
-	var user repo.User
-
-	query := `
-		SELECT 
+const userColumns = `
 			id,
 			first_name,
 			last_name,
-			phone_number,
+			coalesce(phone_number, ''),
 			email,
 			gender,
-			user_name,
+			username,
 			password,
-			profile_image_url,
-			type
+			coalesce(profile_image_url, ''),
+			type,
+			status,
+			status_reason,
+			status_expires_at,
+			version,
 			created_at
-		from users
-		where id=$1
-	`
-	row := ur.db.QueryRow(query, id)
+`
+
+func scanUser(row interface{ Scan(...interface{}) error }) (*repo.User, error) {
+	var (
+		user      repo.User
+		lastName  sql.NullString
+		expiresAt sql.NullTime
+	)
 	if err := row.Scan(
 		&user.Id,
 		&user.FirstName,
-		&user.LastName,
+		&lastName,
 		&user.PhoneNumber,
 		&user.Email,
 		&user.Gender,
@@ -86,14 +94,59 @@ func (ur *userRepo) Get(id int) (*repo.User, error) {
 		&user.Password,
 		&user.ProfileImageUrl,
 		&user.Type,
+		&user.Status,
+		&user.StatusReason,
+		&expiresAt,
+		&user.Version,
 		&user.CreatedAt,
 	); err != nil {
 		return nil, err
 	}
+	user.LastName = lastName.String
+	if expiresAt.Valid {
+		user.StatusExpiresAt = &expiresAt.Time
+	}
 
 	return &user, nil
 }
 
+func (ur *userRepo) Get(id int) (*repo.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		from users
+		where id=$1 AND deleted_at IS NULL
+	`
+	return scanUser(ur.db.QueryRow(query, id))
+}
+
+func (ur *userRepo) GetByIds(ids []int) (map[int]*repo.User, error) {
+	result := make(map[int]*repo.User, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT ` + userColumns + `
+		from users
+		where id = ANY($1) AND deleted_at IS NULL
+	`
+	rows, err := ur.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		result[user.Id] = user
+	}
+
+	return result, rows.Err()
+}
+
 func (ur *userRepo) GetAll(param repo.GetUserQuery) (*repo.GetAllUsersResult, error) {
 	result := repo.GetAllUsersResult{
 		Users: make([]*repo.User, 0),
@@ -102,59 +155,40 @@ func (ur *userRepo) GetAll(param repo.GetUserQuery) (*repo.GetAllUsersResult, er
 	offset := (param.Page - 1) * param.Limit
 
 	limit := fmt.Sprintf(" LIMIT %d OFFSET %d ", param.Limit, offset)
-	filter := ""
+	filter := " WHERE deleted_at IS NULL "
+	args := make([]interface{}, 0)
 	if param.Search != "" {
-		str := "%" + param.Search + "%"
+		args = append(args, "%"+param.Search+"%")
 		filter += fmt.Sprintf(` 
-			where first_name ILIKE '%s' OR last_name ILIKE '%s' OR email ILIKE '%s' 
-		OR username ILIKE '%s' OR phone_number ILIKE '%s'`, str, str, str, str, str)
+			AND (first_name ILIKE $%[1]d OR last_name ILIKE $%[1]d OR email ILIKE $%[1]d
+			OR username ILIKE $%[1]d OR phone_number ILIKE $%[1]d) `, len(args))
+	}
+	if param.HideReported {
+		filter += notHidden(repo.ReportTargetUser, "users.id")
 	}
 
 	query := `
-		SELECT 
-			id,
-			first_name,
-			last_name,
-			phone_number,
-			email,
-			gender,
-			user_name,
-			password,
-			profile_image_url,
-			type,
-			created_at
+		SELECT ` + userColumns + `
 		FROM users
 		` + filter + `
 		ORDER BY created_at desc
 		` + limit
 
-	rows, err := ur.db.Query(query)
+	rows, err := ur.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 
 	defer rows.Close()
 	for rows.Next() {
-		var usr repo.User
-		if err := rows.Scan(
-			&usr.Id,
-			&usr.FirstName,
-			&usr.LastName,
-			&usr.PhoneNumber,
-			&usr.Email,
-			&usr.Gender,
-			&usr.UserName,
-			&usr.Password,
-			&usr.ProfileImageUrl,
-			&usr.Type,
-			&usr.CreatedAt,
-		); err != nil {
+		usr, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		result.Users = append(result.Users, &usr)
+		result.Users = append(result.Users, usr)
 	}
 	queryCount := `SELECT count(1) FROM users ` + filter
-	err = ur.db.QueryRow(queryCount).Scan(&result.Count)
+	err = ur.db.QueryRow(queryCount, args...).Scan(&result.Count)
 	if err != nil {
 		return nil, err
 	}
@@ -169,13 +203,15 @@ func (ur *userRepo) Update(usr *repo.User) (*repo.User, error) {
 			phone_number=$3,
 			email=$4,
 			gender=$5,
-			user_name=$6,
+			username=$6,
 			password=$7,
 			profile_image_url=$8,
-			type=$9
-		where id=$10
+			type=$9,
+			version=version+1
+		where id=$10 AND deleted_at IS NULL AND version=$11
+		RETURNING version
 	`
-	_, err := ur.db.Exec(
+	err := ur.db.QueryRow(
 		query,
 		usr.FirstName,
 		usr.LastName,
@@ -187,16 +223,61 @@ func (ur *userRepo) Update(usr *repo.User) (*repo.User, error) {
 		usr.ProfileImageUrl,
 		usr.Type,
 		usr.Id,
-	)
-
+		usr.Version,
+	).Scan(&usr.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(ur.db, "users", usr.Id)
+	}
 	if err != nil {
 		return nil, err
 	}
 	return usr, nil
 }
 
-func (ur *userRepo) Delete(id int) error {
-	res, err := ur.db.Exec("delete from users where id=$1", id)
+func (ur *userRepo) Patch(id int, p *repo.UserPatch) (*repo.User, error) {
+	var set setClause
+	if p.FirstName != nil {
+		set.add("first_name", *p.FirstName)
+	}
+	if p.LastName != nil {
+		set.add("last_name", nullString(*p.LastName))
+	}
+	if p.PhoneNumber != nil {
+		set.add("phone_number", nullString(*p.PhoneNumber))
+	}
+	if p.Email != nil {
+		set.add("email", *p.Email)
+	}
+	if p.Gender != nil {
+		set.add("gender", *p.Gender)
+	}
+	if p.UserName != nil {
+		set.add("username", *p.UserName)
+	}
+	if p.Password != nil {
+		set.add("password", *p.Password)
+	}
+	if p.ProfileImageUrl != nil {
+		set.add("profile_image_url", nullString(*p.ProfileImageUrl))
+	}
+
+	query, args := set.query("users", id, p.Version, userColumns)
+	user, err := scanUser(ur.db.QueryRow(query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, updateMissed(ur.db, "users", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (ur *userRepo) Delete(id, version int) error {
+	res, err := ur.db.Exec(
+		"update users set deleted_at=now(), version=version+1 where id=$1 AND deleted_at IS NULL AND version=$2",
+		id,
+		version,
+	)
 	if err != nil {
 		return err
 	}
@@ -205,7 +286,148 @@ func (ur *userRepo) Delete(id int) error {
 		return err
 	}
 	if rows == 0 {
-		return sql.ErrNoRows
+		return updateMissed(ur.db, "users", id)
 	}
 	return nil
 }
+
+func (ur *userRepo) SetStatus(change *repo.UserStatusChange) error {
+	tx, err := begin(ur.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		update users set
+			status=$1,
+			status_reason=$2,
+			status_expires_at=$3,
+			version=version+1
+		where id=$4
+	`
+	res, err := tx.Exec(
+		query,
+		change.Status,
+		change.Reason,
+		change.ExpiresAt,
+		change.UserId,
+	)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	query = `
+		INSERT INTO user_status_history(
+			user_id,
+			status,
+			reason,
+			expires_at,
+			changed_by,
+			report_id
+		) values ($1,$2,$3,$4,$5,$6)
+		RETURNING id,created_at
+	`
+	err = tx.QueryRow(
+		query,
+		change.UserId,
+		change.Status,
+		change.Reason,
+		change.ExpiresAt,
+		nullInt(change.ChangedBy),
+		nullInt(change.ReportId),
+	).Scan(
+		&change.Id,
+		&change.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (ur *userRepo) GetStatusHistory(userId int) ([]*repo.UserStatusChange, error) {
+	query := `
+		SELECT
+			id,
+			user_id,
+			status,
+			reason,
+			expires_at,
+			changed_by,
+			report_id,
+			created_at
+		FROM user_status_history
+		WHERE user_id=$1
+		ORDER BY created_at desc, id desc
+	`
+	rows, err := ur.db.Query(query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make([]*repo.UserStatusChange, 0)
+	for rows.Next() {
+		var (
+			c         repo.UserStatusChange
+			expiresAt sql.NullTime
+			changedBy sql.NullInt64
+			reportId  sql.NullInt64
+		)
+		if err := rows.Scan(
+			&c.Id,
+			&c.UserId,
+			&c.Status,
+			&c.Reason,
+			&expiresAt,
+			&changedBy,
+			&reportId,
+			&c.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			c.ExpiresAt = &expiresAt.Time
+		}
+		c.ChangedBy = int(changedBy.Int64)
+		c.ReportId = int(reportId.Int64)
+		result = append(result, &c)
+	}
+
+	return result, rows.Err()
+}
+
+func (ur *userRepo) GetNextBanExpiry() (*time.Time, error) {
+	query := `
+		SELECT MIN(status_expires_at)
+		FROM users
+		WHERE status='banned' AND status_expires_at > now()
+	`
+	var expiresAt sql.NullTime
+	if err := ur.db.QueryRow(query).Scan(&expiresAt); err != nil {
+		return nil, err
+	}
+	if !expiresAt.Valid {
+		return nil, nil
+	}
+	return &expiresAt.Time, nil
+}
+
+// notBannedAuthor filters out rows whose author, referenced by column, is
+// currently banned.
+func notBannedAuthor(column string) string {
+	return ` AND NOT EXISTS (
+		SELECT 1 FROM users u
+		WHERE u.id=` + column + ` AND u.status='banned'
+			AND (u.status_expires_at IS NULL OR u.status_expires_at > now())
+	) `
+}
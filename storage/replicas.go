@@ -0,0 +1,30 @@
+package storage
+
+import "context"
+
+type sessionKey struct{}
+
+type readPrimaryKey struct{}
+
+// WithSession names the session the storage returned by WithContext(ctx)
+// works for, e.g. a user. Once a session wrote, its reads go to the
+// primary for a while so that it sees its writes on the next request
+// already, although replicas may not have them yet.
+func WithSession(ctx context.Context, session string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionFrom(ctx context.Context) string {
+	session, _ := ctx.Value(sessionKey{}).(string)
+	return session
+}
+
+// ReadPrimary makes the storage returned by WithContext(ctx) read from the
+// primary only, for reads that must not be stale.
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+func readPrimary(ctx context.Context) bool {
+	return ctx.Value(readPrimaryKey{}) != nil
+}
@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate    = "create"
+	AuditActionUpdate    = "update"
+	AuditActionDelete    = "delete"
+	AuditActionRestore   = "restore"
+	AuditActionModerate  = "moderate"
+	AuditActionSetStatus = "set_status"
+	AuditActionHide      = "hide"
+	AuditActionResolve   = "resolve"
+)
+
+// Entity types besides the ones that can be in the trash.
+const (
+	AuditEntityMedia   = "media"
+	AuditEntityReport  = "report"
+	AuditEntitySetting = "setting"
+)
+
+// AuditEntry records one change. Before is empty for creations, After for
+// deletions. ActorId is 0 for anonymous requests.
+type AuditEntry struct {
+	Id         int64
+	ActorId    int
+	Action     string
+	EntityType string
+	EntityId   string
+	Before     json.RawMessage
+	After      json.RawMessage
+	RequestId  string
+	Ip         string
+	CreatedAt  time.Time
+}
+
+type GetAuditQuery struct {
+	Page       int
+	Limit      int
+	ActorId    int
+	Action     string
+	EntityType string
+	EntityId   string
+	From       time.Time
+	To         time.Time
+}
+
+type GetAllAuditResult struct {
+	Entries []*AuditEntry
+	Count   int
+}
+
+// AuditStorageI is append-only, entries can not be changed once added.
+type AuditStorageI interface {
+	Add(e *AuditEntry) error
+	GetAll(param GetAuditQuery) (*GetAllAuditResult, error)
+}
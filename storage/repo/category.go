@@ -5,15 +5,32 @@ import "time"
 type Category struct {
 	Id        int
 	Title     string
+	Version   int
 	CreatedAt time.Time
 }
 
+// CategoryPatch changes the fields that are not nil.
+type CategoryPatch struct {
+	Version int
+	Title   *string
+}
+
 type CategoryStorageI interface {
 	Create(u *Category) (*Category, error)
 	Get(id int) (*Category, error)
+	// GetByIds returns the categories of ids by id, leaving out those that
+	// do not exist.
+	GetByIds(ids []int) (map[int]*Category, error)
 	GetAll(param GetCategoryQuery) (*GetAllCategoriesResult, error)
+	// Update returns ErrVersionConflict if the category changed since
+	// category.Version was read.
 	Update(category Category) (*Category, error)
-	Delete(id int) error
+	// Patch updates only the columns set in p, with the same version check
+	// as Update.
+	Patch(id int, p *CategoryPatch) (*Category, error)
+	// Delete moves the row to the trash, with the same version check as
+	// Update.
+	Delete(id, version int) error
 }
 
 type GetCategoryQuery struct {
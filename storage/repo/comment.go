@@ -4,11 +4,22 @@ import (
 	"time"
 )
 
+const (
+	CommentStatusPending  = "pending"
+	CommentStatusApproved = "approved"
+	CommentStatusRejected = "rejected"
+	CommentStatusSpam     = "spam"
+)
+
 type GetCommentQuery struct {
 	Page   int
 	Limit  int
 	PostId int
 	UserId int
+	// Status filters by moderation status, empty means any status.
+	Status string
+	// HideBannedAuthors leaves out comments of currently banned users.
+	HideBannedAuthors bool
 }
 
 type GetAllCommentsResult struct {
@@ -17,18 +28,40 @@ type GetAllCommentsResult struct {
 }
 
 type Comment struct {
-	Id          int       `json:"id" db:"id"`
-	PostId      int       `json:"post_id" db:"post_id"`
-	UserId      int       `json:"user_id" db:"user_id"`
-	Description string    `json:"description" db:"description"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	Id          int        `json:"id" db:"id"`
+	PostId      int        `json:"post_id" db:"post_id"`
+	UserId      int        `json:"user_id" db:"user_id"`
+	Description string     `json:"description" db:"description"`
+	Status      string     `json:"status" db:"status"`
+	ModeratedBy int        `json:"moderated_by" db:"moderated_by"`
+	ModeratedAt *time.Time `json:"moderated_at" db:"moderated_at"`
+	Version     int        `json:"version" db:"version"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// CommentPatch changes the fields that are not nil.
+type CommentPatch struct {
+	Version     int
+	Description *string
+	Status      *string
 }
 
 type CommentStorageI interface {
 	Create(comment *Comment) (*Comment, error)
 	Get(id int) (*Comment, error)
 	GetAll(param GetCommentQuery) (*GetAllCommentsResult, error)
+	// Update returns ErrVersionConflict if the comment changed since
+	// cr.Version was read. The author is never changed.
 	Update(cr *Comment) (*Comment, error)
-	Delete(id int) error
+	// Patch updates only the columns set in p, with the same version check
+	// as Update.
+	Patch(id int, p *CommentPatch) (*Comment, error)
+	// Delete moves the row to the trash, with the same version check as
+	// Update.
+	Delete(id, version int) error
+	// UpdateStatus moderates comments in bulk and returns how many of ids
+	// existed.
+	UpdateStatus(ids []int, status string, moderatorId int) (int, error)
+	CountByUser(userId int, status string) (int, error)
 }
@@ -0,0 +1,13 @@
+package repo
+
+// DuplicateError is returned when a write would give a row a value that
+// another row already has in a column that must be unique, e.g. when a user
+// is restored whose email was taken in the meantime.
+type DuplicateError struct {
+	// Field is the column, e.g. "email".
+	Field string
+}
+
+func (e *DuplicateError) Error() string {
+	return e.Field + " is already taken"
+}
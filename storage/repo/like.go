@@ -25,4 +25,6 @@ type LikeStorageI interface {
 	GetAll(param GetLikesQuery) (*GetAllLikesResult, error)
 	Update(usr *Like) (*Like, error)
 	Delete(id int) error
+	// CountByPosts counts the likes of each of postIds by status.
+	CountByPosts(postIds []int) (map[int]map[string]int, error)
 }
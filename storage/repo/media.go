@@ -0,0 +1,54 @@
+package repo
+
+import "time"
+
+const (
+	MediaStatusPending = "pending"
+	MediaStatusReady   = "ready"
+	MediaStatusFailed  = "failed"
+)
+
+type Media struct {
+	Id          int
+	ObjectKey   string
+	Url         string
+	ContentType string
+	Size        int64
+	UserId      int
+	Status      string
+	CreatedAt   time.Time
+}
+
+type MediaRendition struct {
+	Id          int
+	MediaId     int
+	Name        string
+	Format      string
+	ObjectKey   string
+	Url         string
+	ContentType string
+	Width       int
+	Height      int
+	CreatedAt   time.Time
+}
+
+type MediaStorageI interface {
+	Create(m *Media) (*Media, error)
+	Get(id int) (*Media, error)
+	GetByKey(key string) (*Media, error)
+	// KeyExists reports whether a blob key belongs to an upload or to one
+	// of its renditions.
+	KeyExists(key string) (bool, error)
+	// GetOrphans returns media created before the given time that is not
+	// referenced by any post image or user avatar.
+	GetOrphans(createdBefore time.Time, limit int) ([]*Media, error)
+	GetPending(limit int) ([]*Media, error)
+	UpdateStatus(id int, status string) error
+	Delete(id int) error
+
+	CreateRendition(r *MediaRendition) (*MediaRendition, error)
+	GetRenditions(mediaId int) ([]*MediaRendition, error)
+	// GetRenditionsByUrls returns the renditions of every upload whose url
+	// is in urls, keyed by that url.
+	GetRenditionsByUrls(urls []string) (map[string][]*MediaRendition, error)
+}
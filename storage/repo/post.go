@@ -2,10 +2,24 @@ package repo
 
 import "time"
 
+// Posts share the moderation statuses of comments.
+const (
+	PostStatusPending  = CommentStatusPending
+	PostStatusApproved = CommentStatusApproved
+	PostStatusRejected = CommentStatusRejected
+	PostStatusSpam     = CommentStatusSpam
+)
+
 type GetPostQuery struct {
-	Page  int
-	Limit int
-	Search string
+	Page       int
+	Limit      int
+	Search     string
+	CategoryId int
+	UserId     int
+	// Status filters by moderation status, empty means any status.
+	Status string
+	// HideBannedAuthors leaves out posts of currently banned users.
+	HideBannedAuthors bool
 }
 
 type GetAllPostResult struct {
@@ -13,24 +27,69 @@ type GetAllPostResult struct {
 	Count int
 }
 
+// Post.Description holds the Markdown source written by the author, the
+// other description fields are derived from it on every write. Version is
+// bumped on every write; Update only succeeds if it still matches.
 type Post struct {
-	Id          int
-	Title       string
-	Description string
-	ImageUrl    string
-	UserId      string
-	CategoryId  string
-	UpdatedAt   string
-	ViewsCount  string
-	CreatedAt   time.Time
+	Id              int
+	Title           string
+	Description     string
+	DescriptionHtml string
+	Toc             []TocEntry
+	ReadingTime     int
+	Excerpt         string
+	ImageUrl        string
+	UserId          string
+	CategoryId      string
+	UpdatedAt       time.Time
+	ViewsCount      string
+	Status          string
+	ModeratedBy     int
+	ModeratedAt     *time.Time
+	Version         int
+	CreatedAt       time.Time
+}
+
+// PostPatch changes the fields that are not nil. The fields derived from
+// the description are written together with Description.
+type PostPatch struct {
+	Version         int
+	Title           *string
+	Description     *string
+	DescriptionHtml string
+	Toc             []TocEntry
+	ReadingTime     int
+	Excerpt         string
+	ImageUrl        *string
+	CategoryId      *string
+}
+
+type TocEntry struct {
+	Level int    `json:"level"`
+	Title string `json:"title"`
+	Id    string `json:"id"`
 }
 
 type PostStorageI interface {
 	Create(p *Post) (*Post, error)
 	Get(id int) (*Post, error)
 	GetAll(param GetPostQuery) (*GetAllPostResult, error)
+	// Update returns ErrVersionConflict if the post changed since
+	// usr.Version was read.
 	Update(usr *Post) (*Post, error)
-	Delete(id int) error
+	// Patch updates only the columns set in p, with the same version check
+	// as Update.
+	Patch(id int, p *PostPatch) (*Post, error)
+	// Delete moves the row to the trash, with the same version check as
+	// Update.
+	Delete(id, version int) error
+	// UpdateStatus moderates posts in bulk and returns how many of ids
+	// existed.
+	UpdateStatus(ids []int, status string, moderatorId int) (int, error)
+	// GetUnrendered returns posts, deleted ones included, with a
+	// description that was never rendered, by id starting after afterId.
+	GetUnrendered(afterId, limit int) ([]*Post, error)
+	// SetRendered stores the fields derived from the description of p,
+	// unless the description changed meanwhile.
+	SetRendered(p *Post) error
 }
-
-
@@ -0,0 +1,100 @@
+package repo
+
+import "time"
+
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+)
+
+// A report is open until an admin resolves it; the other statuses record
+// how it was resolved.
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusRemoved   = "removed"
+	ReportStatusSuspended = "suspended"
+)
+
+// ReportReasons are the categories a reporter picks from.
+var ReportReasons = []string{
+	"spam",
+	"harassment",
+	"hate",
+	"violence",
+	"sexual",
+	"misinformation",
+	"other",
+}
+
+// Report groups all reports on one target. ReportCount is the number of
+// distinct reporters.
+type Report struct {
+	Id          int
+	TargetType  string
+	TargetId    int
+	Status      string
+	ReportCount int
+	Hidden      bool
+	// HiddenStatus is the moderation status the reported post or comment
+	// had before the report hid it.
+	HiddenStatus string
+	ResolvedBy   int
+	ResolvedAt   *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type ReportEntry struct {
+	Id         int
+	ReportId   int
+	ReporterId int
+	Reason     string
+	Details    string
+	CreatedAt  time.Time
+}
+
+// ReportAction is one step of the audit trail of a report. ActorId is 0 for
+// actions taken automatically.
+type ReportAction struct {
+	Id        int
+	ReportId  int
+	ActorId   int
+	Action    string
+	Note      string
+	CreatedAt time.Time
+}
+
+type GetReportsQuery struct {
+	Page       int
+	Limit      int
+	Status     string
+	TargetType string
+}
+
+type GetAllReportsResult struct {
+	Reports []*Report
+	Count   int
+}
+
+type ReportStorageI interface {
+	// Add files entry against the open report on the target, opening one
+	// if there is none. added is false when the reporter already reported
+	// the target, in which case nothing changes.
+	Add(targetType string, targetId int, entry *ReportEntry) (report *Report, added bool, err error)
+	Get(id int) (*Report, error)
+	GetAll(param GetReportsQuery) (*GetAllReportsResult, error)
+	GetEntries(reportId int) ([]*ReportEntry, error)
+	GetActions(reportId int) ([]*ReportAction, error)
+	// Hide marks the report's target as hidden and records it in the
+	// audit trail. previousStatus is the status the target had before.
+	Hide(id int, previousStatus string) error
+	// Resolve closes an open report with status and records it in the
+	// audit trail. It returns sql.ErrNoRows if the report is not open.
+	Resolve(id int, status string, actorId int, note string) error
+	// IsHidden reports whether an open report hides the target.
+	IsHidden(targetType string, targetId int) (bool, error)
+	// GetHidden returns which of targetIds are hidden by an open report.
+	GetHidden(targetType string, targetIds []int) (map[int]bool, error)
+}
@@ -0,0 +1,13 @@
+package repo
+
+// SettingStorageI keeps settings that can be changed at runtime as JSON
+// documents by key.
+type SettingStorageI interface {
+	// Get returns sql.ErrNoRows for keys that were never set.
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte) error
+}
+
+// ContentFilterSettingKey stores the rules of the content filter as
+// changed through the admin api.
+const ContentFilterSettingKey = "content_filter"
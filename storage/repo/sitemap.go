@@ -0,0 +1,25 @@
+package repo
+
+import "time"
+
+type SitemapEntry struct {
+	Id        int
+	UpdatedAt time.Time
+}
+
+// SitemapStorageI exposes the light weight queries the sitemap generator
+// needs instead of loading full posts.
+type SitemapStorageI interface {
+	GetPostMaxId() (int, error)
+	// GetPosts returns approved posts with fromId <= id < toId ordered by
+	// id. Like the other queries it leaves out posts of banned users.
+	GetPosts(fromId, toId int) ([]*SitemapEntry, error)
+	// GetChangedPostChunks returns the distinct (id-1)/chunkSize values of
+	// posts updated after since.
+	GetChangedPostChunks(since time.Time, chunkSize int) ([]int, error)
+	// GetCategories and GetAuthors return categories and authors that have
+	// at least one approved post, UpdatedAt being their most recent post
+	// update.
+	GetCategories() ([]*SitemapEntry, error)
+	GetAuthors() ([]*SitemapEntry, error)
+}
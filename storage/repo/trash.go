@@ -0,0 +1,52 @@
+package repo
+
+import "time"
+
+// Types of deleted items in the trash.
+const (
+	TrashTypePost     = "post"
+	TrashTypeComment  = "comment"
+	TrashTypeCategory = "category"
+	TrashTypeUser     = "user"
+	TrashTypeLike     = "like"
+)
+
+var TrashTypes = []string{
+	TrashTypePost,
+	TrashTypeComment,
+	TrashTypeCategory,
+	TrashTypeUser,
+	TrashTypeLike,
+}
+
+// TrashItem is a soft deleted row. Title is a short human readable label,
+// e.g. the post title or the username.
+type TrashItem struct {
+	Type      string
+	Id        int
+	Title     string
+	DeletedAt time.Time
+}
+
+type GetTrashQuery struct {
+	Page  int
+	Limit int
+	Type  string
+}
+
+type GetAllTrashResult struct {
+	Items []*TrashItem
+	Count int
+}
+
+// TrashStorageI works on the rows the other repositories soft deleted.
+type TrashStorageI interface {
+	GetAll(param GetTrashQuery) (*GetAllTrashResult, error)
+	// Restore returns sql.ErrNoRows if the item is not in the trash, e.g.
+	// because it was purged, and a *DuplicateError if a restored user
+	// clashes with one signed up since.
+	Restore(itemType string, id int) error
+	// Purge permanently removes items deleted before deletedBefore and
+	// returns how many rows of each type were removed.
+	Purge(deletedBefore time.Time) (map[string]int, error)
+}
@@ -2,32 +2,100 @@ package repo
 
 import "time"
 
+const (
+	UserStatusActive      = "active"
+	UserStatusSuspended   = "suspended"
+	UserStatusBanned      = "banned"
+	UserStatusDeactivated = "deactivated"
+)
+
+// User.StatusExpiresAt is when Status lapses back to active, nil if it does
+// not.
 type User struct {
-	Id              int       `db:"id"`
-	FirstName       string    `db:"first_name"`
-	LastName        string    `db:"last_name"`
-	PhoneNumber     string    `db:"phone_number"`
-	Email           string    `db:"email"`
-	Gender          string    `db:"gender"`
-	UserName        string    `db:"user_name"`
-	Password        string    `db:"password"`
-	ProfileImageUrl string    `db:"profile_image_url"`
-	Type            string    `db:"type"`
-	CreatedAt       time.Time `db:"created_at"`
+	Id              int        `db:"id"`
+	FirstName       string     `db:"first_name"`
+	LastName        string     `db:"last_name"`
+	PhoneNumber     string     `db:"phone_number"`
+	Email           string     `db:"email"`
+	Gender          string     `db:"gender"`
+	UserName        string     `db:"username"`
+	Password        string     `db:"password"`
+	ProfileImageUrl string     `db:"profile_image_url"`
+	Type            string     `db:"type"`
+	Status          string     `db:"status"`
+	StatusReason    string     `db:"status_reason"`
+	StatusExpiresAt *time.Time `db:"status_expires_at"`
+	Version         int        `db:"version"`
+	CreatedAt       time.Time  `db:"created_at"`
+}
+
+// CurrentStatus is Status, or active once it expired.
+func (u *User) CurrentStatus() string {
+	if u.StatusExpiresAt != nil && !u.StatusExpiresAt.After(time.Now()) {
+		return UserStatusActive
+	}
+	return u.Status
+}
+
+// UserStatusChange is one entry of a user's status history. ReportId links
+// changes that resolved a report.
+type UserStatusChange struct {
+	Id        int
+	UserId    int
+	Status    string
+	Reason    string
+	ExpiresAt *time.Time
+	ChangedBy int
+	ReportId  int
+	CreatedAt time.Time
+}
+
+// UserPatch changes the fields that are not nil. Empty LastName,
+// PhoneNumber and ProfileImageUrl clear them.
+type UserPatch struct {
+	Version         int
+	FirstName       *string
+	LastName        *string
+	PhoneNumber     *string
+	Email           *string
+	Gender          *string
+	UserName        *string
+	Password        *string
+	ProfileImageUrl *string
 }
 
 type UserStorageI interface {
 	Create(u *User) (*User, error)
 	Get(id int) (*User, error)
+	// GetByIds returns the users of ids by id, leaving out those that do
+	// not exist.
+	GetByIds(ids []int) (map[int]*User, error)
 	GetAll(param GetUserQuery) (*GetAllUsersResult, error)
+	// Update returns ErrVersionConflict if the user changed since
+	// usr.Version was read.
 	Update(usr *User) (*User, error)
-	Delete(id int)error
+	// Patch updates only the columns set in p, with the same version check
+	// as Update.
+	Patch(id int, p *UserPatch) (*User, error)
+	// Delete moves the row to the trash, with the same version check as
+	// Update.
+	Delete(id, version int) error
+	// SetStatus changes the status of change.UserId and appends change to
+	// the history. It returns sql.ErrNoRows for unknown users.
+	SetStatus(change *UserStatusChange) error
+	// GetStatusHistory returns the status changes of a user, newest first.
+	GetStatusHistory(userId int) ([]*UserStatusChange, error)
+	// GetNextBanExpiry returns when the first of the timed bans still in
+	// force ends, nil when there is none.
+	GetNextBanExpiry() (*time.Time, error)
 }
 
 type GetUserQuery struct {
-	Page  int
-	Limit int
+	Page   int
+	Limit  int
 	Search string
+	// HideReported leaves out users hidden by an open report.
+	HideReported bool
 }
 
 type GetAllUsersResult struct {
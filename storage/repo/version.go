@@ -0,0 +1,8 @@
+package repo
+
+import "errors"
+
+// ErrVersionConflict is returned by the Update, Patch and Delete methods
+// when the row changed since the caller read it, i.e. its Version no longer
+// matches.
+var ErrVersionConflict = errors.New("version conflict")
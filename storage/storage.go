@@ -1,6 +1,8 @@
 package storage
 
 import (
+	"context"
+
 	"github.com/jmoiron/sqlx"
 	"github.com/samandar2605/post/storage/postgres"
 	"github.com/samandar2605/post/storage/repo"
@@ -12,24 +14,134 @@ type StorageI interface {
 	User() repo.UserStorageI
 	Post() repo.PostStorageI
 	Like() repo.LikeStorageI
+	Media() repo.MediaStorageI
+	Sitemap() repo.SitemapStorageI
+	Setting() repo.SettingStorageI
+	Report() repo.ReportStorageI
+	Trash() repo.TrashStorageI
+	Audit() repo.AuditStorageI
+
+	// WithTx runs fn with a StorageI whose repositories share one
+	// transaction. It is committed when fn returns nil and rolled back
+	// otherwise. Nested calls join the outer transaction.
+	WithTx(fn func(strg StorageI) error) error
+
+	// WithContext returns a StorageI whose calls belong to ctx: repository
+	// calls and their statements are traced as children of its span.
+	WithContext(ctx context.Context) StorageI
 }
 
 type storagePg struct {
+	// db is nil for the storage handed to WithTx callbacks
+	db *sqlx.DB
+	// conn runs the statements of the repositories, db, a session of the
+	// replicas or a transaction, and scope is set for storages returned by
+	// WithContext.
+	conn  postgres.DB
+	scope *spanScope
+	// replicas is set when reads may go to replicas, session is the DB
+	// of the session of the storage then.
+	replicas     *postgres.Replicas
+	session      *postgres.Session
 	categoryRepo repo.CategoryStorageI
 	commentRepo  repo.CommentStorageI
 	userRepo     repo.UserStorageI
 	postRepo     repo.PostStorageI
 	likeRepo	repo.LikeStorageI
+	mediaRepo    repo.MediaStorageI
+	sitemapRepo  repo.SitemapStorageI
+	settingRepo  repo.SettingStorageI
+	reportRepo   repo.ReportStorageI
+	trashRepo    repo.TrashStorageI
+	auditRepo    repo.AuditStorageI
 }
 
-func NewStoragePg(db *sqlx.DB) StorageI {
+// NewStoragePg runs writes on primary and spreads reads over replicas, see
+// postgres.Replicas. Without replicas everything runs on primary.
+func NewStoragePg(primary *sqlx.DB, replicas ...*sqlx.DB) StorageI {
+	if len(replicas) == 0 {
+		s := newStoragePg(primary)
+		s.db = primary
+		return s
+	}
+	return NewReplicatedStoragePg(postgres.NewReplicas(primary, replicas, postgres.ReplicaOptions{}))
+}
+
+// NewReplicatedStoragePg is NewStoragePg with the replicas set up by the
+// caller. Storages returned by WithContext keep the reads of a session on
+// the primary for a while after it wrote, see WithSession.
+func NewReplicatedStoragePg(replicas *postgres.Replicas) StorageI {
+	session := replicas.Session("")
+	s := newStoragePg(session)
+	s.db = replicas.Primary()
+	s.replicas = replicas
+	s.session = session
+	return s
+}
+
+func newStoragePg(db postgres.DB) *storagePg {
 	return &storagePg{
+		conn:         db,
 		categoryRepo: postgres.NewCategory(db),
 		commentRepo:  postgres.NewComment(db),
 		userRepo:     postgres.NewUser(db),
 		postRepo:     postgres.NewPost(db),
-		likeRepo: postgres.NewLike(db),
+		likeRepo:     postgres.NewLike(db),
+		mediaRepo:    postgres.NewMedia(db),
+		sitemapRepo:  postgres.NewSitemap(db),
+		settingRepo:  postgres.NewSetting(db),
+		reportRepo:   postgres.NewReport(db),
+		trashRepo:    postgres.NewTrash(db),
+		auditRepo:    postgres.NewAudit(db),
+	}
+}
+
+func (s *storagePg) WithTx(fn func(strg StorageI) error) error {
+	if s.db == nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var conn postgres.DB = tx
+	if s.scope != nil {
+		conn = postgres.Traced(tx, s.scope.context)
+	}
+	txStrg := newStoragePg(conn)
+	txStrg.conn = tx
+	txStrg.scope = s.scope
+	if err := fn(txStrg); err != nil {
+		return err
+	}
+	if s.session != nil {
+		s.session.Wrote()
 	}
+	return tx.Commit()
+}
+
+func (s *storagePg) WithContext(ctx context.Context) StorageI {
+	conn, session := s.conn, s.session
+	if s.replicas != nil {
+		if readPrimary(ctx) {
+			conn, session = s.db, nil
+		} else {
+			session = s.replicas.Session(sessionFrom(ctx))
+			conn = session
+		}
+	}
+
+	scope := &spanScope{ctx: ctx}
+	strg := newStoragePg(postgres.Traced(conn, scope.context))
+	strg.db = s.db
+	strg.conn = conn
+	strg.scope = scope
+	strg.replicas = s.replicas
+	strg.session = session
+	return WithObserver(strg, scope)
 }
 
 func (s *storagePg) Category() repo.CategoryStorageI {
@@ -51,4 +163,28 @@ func (s *storagePg) Post() repo.PostStorageI {
 
 func (s *storagePg) Like() repo.LikeStorageI {
 	return s.likeRepo
-}
\ No newline at end of file
+}
+
+func (s *storagePg) Media() repo.MediaStorageI {
+	return s.mediaRepo
+}
+
+func (s *storagePg) Sitemap() repo.SitemapStorageI {
+	return s.sitemapRepo
+}
+
+func (s *storagePg) Setting() repo.SettingStorageI {
+	return s.settingRepo
+}
+
+func (s *storagePg) Report() repo.ReportStorageI {
+	return s.reportRepo
+}
+
+func (s *storagePg) Trash() repo.TrashStorageI {
+	return s.trashRepo
+}
+
+func (s *storagePg) Audit() repo.AuditStorageI {
+	return s.auditRepo
+}
@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/samandar2605/post/storage")
+
+// spanScope traces the repository calls of a storage returned by
+// WithContext. Repositories take no context, so the scope keeps the context
+// of the call in progress for the statements it runs to be its children.
+type spanScope struct {
+	mu  sync.Mutex
+	ctx context.Context
+}
+
+func (s *spanScope) context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
+
+// ObserveCall starts the span of a repository call, it implements Observer.
+func (s *spanScope) ObserveCall(repository, method string) func(err error) {
+	s.mu.Lock()
+	parent := s.ctx
+	ctx, span := tracer.Start(parent, repository+"."+method,
+		trace.WithAttributes(
+			attribute.String("repository", repository),
+			attribute.String("method", method),
+		),
+	)
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	return func(err error) {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+
+		s.mu.Lock()
+		s.ctx = parent
+		s.mu.Unlock()
+	}
+}
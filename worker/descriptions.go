@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/samandar2605/post/pkg/markdown"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+// RenderDescription fills the fields of p derived from its Markdown
+// description.
+func RenderDescription(p *repo.Post) error {
+	doc, err := markdown.Render(p.Description)
+	if err != nil {
+		return err
+	}
+
+	p.DescriptionHtml = doc.Html
+	p.ReadingTime = doc.ReadingTime
+	p.Excerpt = doc.Excerpt
+	p.Toc = make([]repo.TocEntry, 0, len(doc.Toc))
+	for _, e := range doc.Toc {
+		p.Toc = append(p.Toc, repo.TocEntry{
+			Level: e.Level,
+			Title: e.Title,
+			Id:    e.Id,
+		})
+	}
+	return nil
+}
+
+// RenderDescriptions renders the descriptions of posts written before they
+// were rendered on write, batch posts at a time, and returns how many it
+// rendered.
+func RenderDescriptions(ctx context.Context, strg storage.StorageI, batch int) (int, error) {
+	var rendered, afterId int
+	for {
+		if err := ctx.Err(); err != nil {
+			return rendered, err
+		}
+
+		posts, err := strg.Post().GetUnrendered(afterId, batch)
+		if err != nil {
+			return rendered, err
+		}
+		if len(posts) == 0 {
+			return rendered, nil
+		}
+
+		for _, p := range posts {
+			if err := RenderDescription(p); err != nil {
+				return rendered, err
+			}
+			if err := strg.Post().SetRendered(p); err != nil {
+				return rendered, err
+			}
+			rendered++
+		}
+		afterId = posts[len(posts)-1].Id
+	}
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Group runs the background workers and keeps track of which of them are
+// still running.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]bool
+}
+
+func NewGroup(ctx context.Context) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{
+		ctx:     ctx,
+		cancel:  cancel,
+		running: make(map[string]bool),
+	}
+}
+
+// Go runs fn in the background under name until it returns, fn must
+// return once ctx is cancelled.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	g.mu.Lock()
+	g.running[name] = true
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer func() {
+			g.mu.Lock()
+			g.running[name] = false
+			g.mu.Unlock()
+		}()
+		fn(g.ctx)
+	}()
+}
+
+// Check fails when a worker stopped, it has the signature of a health
+// check.
+func (g *Group) Check(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var stopped []string
+	for name, running := range g.running {
+		if !running {
+			stopped = append(stopped, name)
+		}
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		return fmt.Errorf("workers not running: %s", strings.Join(stopped, ", "))
+	}
+	return nil
+}
+
+// Stop cancels the workers and waits for them to return, or for ctx to be
+// done.
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/samandar2605/post/pkg/blob"
+	"github.com/samandar2605/post/pkg/imageproc"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type ImageProcessorOptions struct {
+	Storage storage.StorageI
+	Blob    blob.Store
+	// BaseUrl is prepended to "/media/<key>" to build rendition urls.
+	BaseUrl string
+	Sizes   []imageproc.Size
+	// Formats lists output formats by name in order of preference,
+	// imageproc.DefaultFormats when empty. Names without a registered
+	// encoder are skipped.
+	Formats []string
+	Quality int
+	Workers int
+}
+
+// ImageProcessor generates the configured renditions of uploaded images
+// on a pool of background goroutines.
+type ImageProcessor struct {
+	opt     ImageProcessorOptions
+	formats []*imageproc.Format
+	jobs    chan int
+	wg      sync.WaitGroup
+}
+
+func NewImageProcessor(opt ImageProcessorOptions) *ImageProcessor {
+	ip := &ImageProcessor{
+		opt:  opt,
+		jobs: make(chan int, 256),
+	}
+	if ip.opt.Workers < 1 {
+		ip.opt.Workers = 1
+	}
+
+	names := opt.Formats
+	if len(names) == 0 {
+		names = imageproc.DefaultFormats
+	}
+	for _, name := range names {
+		f, ok := imageproc.LookupFormat(name)
+		if !ok {
+			slog.Warn("image processor: no encoder registered with imageproc.RegisterFormat, skipping format", "format", name)
+			continue
+		}
+		ip.formats = append(ip.formats, f)
+	}
+	if len(ip.formats) == 0 {
+		f, _ := imageproc.LookupFormat("jpeg")
+		ip.formats = append(ip.formats, f)
+	}
+
+	return ip
+}
+
+// Start launches the workers and queues uploads that were still pending
+// when the process last stopped. Workers exit when ctx is cancelled.
+func (ip *ImageProcessor) Start(ctx context.Context) {
+	for i := 0; i < ip.opt.Workers; i++ {
+		ip.wg.Add(1)
+		go func() {
+			defer ip.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case id := <-ip.jobs:
+					if err := ip.Process(ctx, id); err != nil {
+						slog.Error("image processor: processing failed", "media_id", id, "error", err)
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		pending, err := ip.opt.Storage.Media().GetPending(cap(ip.jobs))
+		if err != nil {
+			slog.Error("image processor: failed to load pending media", "error", err)
+			return
+		}
+		for _, m := range pending {
+			ip.Enqueue(m.Id)
+		}
+	}()
+}
+
+// Wait blocks until every worker has returned.
+func (ip *ImageProcessor) Wait() {
+	ip.wg.Wait()
+}
+
+// Enqueue schedules an upload for processing. When the queue is full the
+// job is dropped; the upload stays pending and is picked up on next start.
+func (ip *ImageProcessor) Enqueue(mediaId int) {
+	select {
+	case ip.jobs <- mediaId:
+	default:
+		slog.Warn("image processor: queue full, media left pending", "media_id", mediaId)
+	}
+}
+
+// Process renders every size in every format for one upload.
+func (ip *ImageProcessor) Process(ctx context.Context, mediaId int) error {
+	m, err := ip.opt.Storage.Media().Get(mediaId)
+	if err != nil {
+		return err
+	}
+
+	err = ip.process(ctx, m)
+	status := repo.MediaStatusReady
+	if err != nil {
+		status = repo.MediaStatusFailed
+	}
+	if serr := ip.opt.Storage.Media().UpdateStatus(m.Id, status); serr != nil && err == nil {
+		err = serr
+	}
+
+	return err
+}
+
+func (ip *ImageProcessor) process(ctx context.Context, m *repo.Media) error {
+	rc, _, err := ip.opt.Blob.Get(ctx, m.ObjectKey)
+	if err != nil {
+		return err
+	}
+	img, err := imageproc.Decode(rc)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	base := strings.TrimSuffix(m.ObjectKey, path.Ext(m.ObjectKey))
+	for _, size := range ip.opt.Sizes {
+		for _, format := range ip.formats {
+			data, dim, err := imageproc.Render(img, size, format, ip.opt.Quality)
+			if err != nil {
+				return fmt.Errorf("render %s: %w", size.Name, err)
+			}
+
+			key := base + "_" + size.Name + format.Ext
+			err = ip.opt.Blob.Put(ctx, key, bytes.NewReader(data), int64(len(data)), format.ContentType)
+			if err != nil {
+				return err
+			}
+
+			_, err = ip.opt.Storage.Media().CreateRendition(&repo.MediaRendition{
+				MediaId:     m.Id,
+				Name:        size.Name,
+				Format:      format.Name,
+				ObjectKey:   key,
+				Url:         strings.TrimRight(ip.opt.BaseUrl, "/") + "/media/" + key,
+				ContentType: format.ContentType,
+				Width:       dim.X,
+				Height:      dim.Y,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
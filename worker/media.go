@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/samandar2605/post/pkg/blob"
+	"github.com/samandar2605/post/storage"
+)
+
+const orphanBatchSize = 100
+
+type MediaCleaner struct {
+	strg     storage.StorageI
+	blob     blob.Store
+	ttl      time.Duration
+	interval time.Duration
+}
+
+func NewMediaCleaner(strg storage.StorageI, store blob.Store, ttl, interval time.Duration) *MediaCleaner {
+	return &MediaCleaner{
+		strg:     strg,
+		blob:     store,
+		ttl:      ttl,
+		interval: interval,
+	}
+}
+
+// Run removes unreferenced uploads every interval until ctx is cancelled.
+func (mc *MediaCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(mc.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := mc.Clean(ctx); err != nil {
+			slog.Error("media cleanup failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Clean deletes media rows (and their files) that no post or user refers to
+// anymore, then files in the store that never got a media row at all, e.g.
+// because the upload request failed half way.
+func (mc *MediaCleaner) Clean(ctx context.Context) error {
+	cutoff := time.Now().Add(-mc.ttl)
+
+	for {
+		orphans, err := mc.strg.Media().GetOrphans(cutoff, orphanBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range orphans {
+			renditions, err := mc.strg.Media().GetRenditions(m.Id)
+			if err != nil {
+				return err
+			}
+
+			keys := []string{m.ObjectKey}
+			for _, r := range renditions {
+				keys = append(keys, r.ObjectKey)
+			}
+			for _, key := range keys {
+				err := mc.blob.Delete(ctx, key)
+				if err != nil && !errors.Is(err, blob.ErrNotFound) {
+					return err
+				}
+			}
+			if err := mc.strg.Media().Delete(m.Id); err != nil {
+				return err
+			}
+			slog.Info("media cleanup: removed orphan", "key", m.ObjectKey)
+		}
+
+		if len(orphans) < orphanBatchSize {
+			break
+		}
+	}
+
+	objects, err := mc.blob.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		if obj.ModifiedAt.After(cutoff) {
+			continue
+		}
+
+		exists, err := mc.strg.Media().KeyExists(obj.Key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		if err := mc.blob.Delete(ctx, obj.Key); err != nil && !errors.Is(err, blob.ErrNotFound) {
+			return err
+		}
+		slog.Info("media cleanup: removed untracked file", "key", obj.Key)
+	}
+
+	return nil
+}
@@ -0,0 +1,224 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samandar2605/post/pkg/feed"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+)
+
+type SitemapOptions struct {
+	Storage storage.StorageI
+	// SiteUrl is the public base url every location is built on.
+	SiteUrl   string
+	ChunkSize int
+	// RefreshInterval is how often changed posts are picked up,
+	// RebuildInterval how often everything is regenerated from scratch so
+	// deleted posts disappear too.
+	RefreshInterval time.Duration
+	RebuildInterval time.Duration
+}
+
+type sitemapFile struct {
+	body    []byte
+	lastMod time.Time
+}
+
+// Sitemap keeps the generated sitemap files in memory. Requests are served
+// from the cache only; the database is queried by Run in the background
+// and, for posts, only for the chunks that changed since the last refresh.
+type Sitemap struct {
+	opt SitemapOptions
+
+	mu          sync.RWMutex
+	files       map[string]*sitemapFile
+	index       []byte
+	lastRefresh time.Time
+	lastRebuild time.Time
+}
+
+func NewSitemap(opt SitemapOptions) *Sitemap {
+	if opt.ChunkSize < 1 || opt.ChunkSize > feed.MaxSitemapUrls {
+		opt.ChunkSize = feed.MaxSitemapUrls
+	}
+	opt.SiteUrl = strings.TrimRight(opt.SiteUrl, "/")
+
+	return &Sitemap{
+		opt:   opt,
+		files: make(map[string]*sitemapFile),
+	}
+}
+
+// Run refreshes the sitemap until ctx is cancelled.
+func (s *Sitemap) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opt.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(); err != nil {
+			slog.Error("sitemap refresh failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Index returns the sitemap index, nil until the first refresh finished.
+func (s *Sitemap) Index() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.index
+}
+
+// File returns one sitemap of the index by name, e.g. "posts-1.xml".
+func (s *Sitemap) File(name string) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.files[name]
+	if !ok {
+		return nil, false
+	}
+	return f.body, true
+}
+
+func (s *Sitemap) Refresh() error {
+	started := time.Now()
+	rebuild := s.lastRebuild.IsZero() || started.Sub(s.lastRebuild) >= s.opt.RebuildInterval
+
+	maxId, err := s.opt.Storage.Sitemap().GetPostMaxId()
+	if err != nil {
+		return err
+	}
+	chunks := (maxId + s.opt.ChunkSize - 1) / s.opt.ChunkSize
+
+	var changed []int
+	if rebuild {
+		for i := 0; i < chunks; i++ {
+			changed = append(changed, i)
+		}
+	} else {
+		changed, err = s.opt.Storage.Sitemap().GetChangedPostChunks(s.lastRefresh, s.opt.ChunkSize)
+		if err != nil {
+			return err
+		}
+		// chunks appearing since the last refresh have no file yet
+		for i := 0; i < chunks; i++ {
+			if _, ok := s.File(postChunkName(i)); !ok {
+				changed = append(changed, i)
+			}
+		}
+	}
+
+	files := make(map[string]*sitemapFile)
+	for _, chunk := range changed {
+		from := chunk*s.opt.ChunkSize + 1
+		entries, err := s.opt.Storage.Sitemap().GetPosts(from, from+s.opt.ChunkSize)
+		if err != nil {
+			return err
+		}
+		f, err := s.render(entries, "/posts/")
+		if err != nil {
+			return err
+		}
+		files[postChunkName(chunk)] = f
+	}
+
+	categories, err := s.opt.Storage.Sitemap().GetCategories()
+	if err != nil {
+		return err
+	}
+	if files["categories.xml"], err = s.render(categories, "/categories/"); err != nil {
+		return err
+	}
+
+	authors, err := s.opt.Storage.Sitemap().GetAuthors()
+	if err != nil {
+		return err
+	}
+	if files["authors.xml"], err = s.render(authors, "/authors/"); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rebuild {
+		s.files = files
+		s.lastRebuild = started
+	} else {
+		for name, f := range files {
+			s.files[name] = f
+		}
+	}
+	// a little overlap so posts updated while we were querying are not lost
+	s.lastRefresh = started.Add(-time.Minute)
+
+	return s.buildIndex(chunks)
+}
+
+func (s *Sitemap) render(entries []*repo.SitemapEntry, path string) (*sitemapFile, error) {
+	var (
+		urls    = make([]feed.SitemapUrl, 0, len(entries))
+		lastMod time.Time
+	)
+	for _, e := range entries {
+		urls = append(urls, feed.SitemapUrl{
+			Loc:     fmt.Sprintf("%s%s%d", s.opt.SiteUrl, path, e.Id),
+			LastMod: e.UpdatedAt,
+		})
+		if e.UpdatedAt.After(lastMod) {
+			lastMod = e.UpdatedAt
+		}
+	}
+
+	body, err := feed.Sitemap(urls)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sitemapFile{body: body, lastMod: lastMod}, nil
+}
+
+// buildIndex must be called with s.mu held.
+func (s *Sitemap) buildIndex(chunks int) error {
+	names := make([]string, 0, chunks+2)
+	for i := 0; i < chunks; i++ {
+		names = append(names, postChunkName(i))
+	}
+	names = append(names, "categories.xml", "authors.xml")
+
+	sitemaps := make([]feed.SitemapUrl, 0, len(names))
+	for _, name := range names {
+		f, ok := s.files[name]
+		if !ok {
+			continue
+		}
+		sitemaps = append(sitemaps, feed.SitemapUrl{
+			Loc:     s.opt.SiteUrl + "/sitemaps/" + name,
+			LastMod: f.lastMod,
+		})
+	}
+
+	index, err := feed.SitemapIndex(sitemaps)
+	if err != nil {
+		return err
+	}
+	s.index = index
+
+	return nil
+}
+
+func postChunkName(chunk int) string {
+	return fmt.Sprintf("posts-%d.xml", chunk+1)
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/samandar2605/post/pkg/feed"
+	"github.com/samandar2605/post/storage"
+	"github.com/samandar2605/post/storage/repo"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStorage struct {
+	storage.StorageI
+	sitemap *fakeSitemapStore
+}
+
+func (s *fakeStorage) Sitemap() repo.SitemapStorageI {
+	return s.sitemap
+}
+
+// fakeSitemapStore has one approved post for every id up to maxId, all
+// updated at updated. GetPosts records the fromId of every call.
+type fakeSitemapStore struct {
+	maxId   int
+	updated time.Time
+	changed []int
+	since   time.Time
+	queried []int
+}
+
+func (f *fakeSitemapStore) GetPostMaxId() (int, error) {
+	return f.maxId, nil
+}
+
+func (f *fakeSitemapStore) GetPosts(fromId, toId int) ([]*repo.SitemapEntry, error) {
+	f.queried = append(f.queried, fromId)
+	var entries []*repo.SitemapEntry
+	for id := fromId; id < toId && id <= f.maxId; id++ {
+		entries = append(entries, &repo.SitemapEntry{Id: id, UpdatedAt: f.updated})
+	}
+	return entries, nil
+}
+
+func (f *fakeSitemapStore) GetChangedPostChunks(since time.Time, chunkSize int) ([]int, error) {
+	f.since = since
+	return f.changed, nil
+}
+
+func (f *fakeSitemapStore) GetCategories() ([]*repo.SitemapEntry, error) {
+	return []*repo.SitemapEntry{{Id: 1, UpdatedAt: f.updated}}, nil
+}
+
+func (f *fakeSitemapStore) GetAuthors() ([]*repo.SitemapEntry, error) {
+	return []*repo.SitemapEntry{{Id: 1, UpdatedAt: f.updated}}, nil
+}
+
+type testUrlset struct {
+	Urls []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+}
+
+type testIndex struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+func newTestSitemap(t *testing.T, store *fakeSitemapStore, chunkSize int) *Sitemap {
+	t.Helper()
+	return NewSitemap(SitemapOptions{
+		Storage:         &fakeStorage{sitemap: store},
+		SiteUrl:         "https://example.com/",
+		ChunkSize:       chunkSize,
+		RefreshInterval: time.Minute,
+		RebuildInterval: time.Hour,
+	})
+}
+
+func sitemapUrls(t *testing.T, s *Sitemap, name string) []string {
+	t.Helper()
+	body, ok := s.File(name)
+	require.True(t, ok, name)
+	var doc testUrlset
+	require.NoError(t, xml.Unmarshal(body, &doc))
+	locs := make([]string, 0, len(doc.Urls))
+	for _, u := range doc.Urls {
+		locs = append(locs, u.Loc)
+	}
+	return locs
+}
+
+func indexLocs(t *testing.T, s *Sitemap) []string {
+	t.Helper()
+	var doc testIndex
+	require.NoError(t, xml.Unmarshal(s.Index(), &doc))
+	locs := make([]string, 0, len(doc.Sitemaps))
+	for _, sm := range doc.Sitemaps {
+		locs = append(locs, sm.Loc)
+	}
+	return locs
+}
+
+func TestSitemapProtocolLimit(t *testing.T) {
+	for _, chunkSize := range []int{0, feed.MaxSitemapUrls + 1} {
+		s := newTestSitemap(t, &fakeSitemapStore{}, chunkSize)
+		require.Equal(t, feed.MaxSitemapUrls, s.opt.ChunkSize)
+	}
+
+	store := &fakeSitemapStore{maxId: 2*feed.MaxSitemapUrls + 1, updated: time.Now()}
+	s := newTestSitemap(t, store, 0)
+	require.NoError(t, s.Refresh())
+
+	require.Equal(t, []int{1, feed.MaxSitemapUrls + 1, 2*feed.MaxSitemapUrls + 1}, store.queried)
+	first := sitemapUrls(t, s, "posts-1.xml")
+	require.Len(t, first, feed.MaxSitemapUrls)
+	require.Equal(t, "https://example.com/posts/1", first[0])
+	require.Equal(t, "https://example.com/posts/50000", first[len(first)-1])
+	require.Len(t, sitemapUrls(t, s, "posts-2.xml"), feed.MaxSitemapUrls)
+	require.Equal(t, []string{"https://example.com/posts/100001"}, sitemapUrls(t, s, "posts-3.xml"))
+}
+
+func TestSitemapIndex(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestSitemap(t, &fakeSitemapStore{maxId: 25, updated: updated}, 10)
+	require.Nil(t, s.Index())
+
+	require.NoError(t, s.Refresh())
+	require.Equal(t, []string{
+		"https://example.com/sitemaps/posts-1.xml",
+		"https://example.com/sitemaps/posts-2.xml",
+		"https://example.com/sitemaps/posts-3.xml",
+		"https://example.com/sitemaps/categories.xml",
+		"https://example.com/sitemaps/authors.xml",
+	}, indexLocs(t, s))
+	require.Contains(t, string(s.Index()), "<lastmod>2024-03-01T12:00:00Z</lastmod>")
+
+	require.Equal(t, []string{"https://example.com/categories/1"}, sitemapUrls(t, s, "categories.xml"))
+	require.Equal(t, []string{"https://example.com/authors/1"}, sitemapUrls(t, s, "authors.xml"))
+	_, ok := s.File("posts-4.xml")
+	require.False(t, ok)
+}
+
+func TestSitemapIncrementalRefresh(t *testing.T) {
+	store := &fakeSitemapStore{maxId: 25, updated: time.Now()}
+	s := newTestSitemap(t, store, 10)
+	require.NoError(t, s.Refresh())
+	require.Equal(t, []int{1, 11, 21}, store.queried)
+	lastRefresh := s.lastRefresh
+
+	// only the changed chunk and the chunk that appeared are queried again
+	store.queried = nil
+	store.changed = []int{1}
+	store.maxId = 35
+	require.NoError(t, s.Refresh())
+	require.Equal(t, lastRefresh, store.since)
+	require.Equal(t, []int{11, 31}, store.queried)
+	require.Len(t, indexLocs(t, s), 6)
+	require.Len(t, sitemapUrls(t, s, "posts-4.xml"), 5)
+	require.Len(t, sitemapUrls(t, s, "posts-1.xml"), 10)
+
+	// a rebuild regenerates every chunk and drops those that are gone
+	store.queried = nil
+	store.changed = nil
+	store.maxId = 15
+	s.lastRebuild = s.lastRebuild.Add(-time.Hour)
+	require.NoError(t, s.Refresh())
+	require.Equal(t, []int{1, 11}, store.queried)
+	require.Len(t, indexLocs(t, s), 4)
+	_, ok := s.File("posts-3.xml")
+	require.False(t, ok)
+}
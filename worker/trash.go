@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/samandar2605/post/storage"
+)
+
+type TrashPurger struct {
+	strg      storage.StorageI
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashPurger(strg storage.StorageI, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{
+		strg:      strg,
+		retention: retention,
+		interval:  interval,
+	}
+}
+
+// Run purges expired trash every interval until ctx is cancelled.
+func (tp *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(tp.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := tp.Purge(); err != nil {
+			slog.Error("trash purge failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge permanently removes everything that has been in the trash for
+// longer than the retention.
+func (tp *TrashPurger) Purge() error {
+	removed, err := tp.strg.Trash().Purge(time.Now().Add(-tp.retention))
+	if err != nil {
+		return err
+	}
+	for itemType, n := range removed {
+		if n > 0 {
+			slog.Info("trash purge: removed items", "type", itemType, "count", n)
+		}
+	}
+	return nil
+}